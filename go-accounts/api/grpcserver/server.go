@@ -0,0 +1,231 @@
+// Package grpcserver serves the Accounts gRPC service next to the REST API,
+// for the other services to resolve tokens and users.
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/lolmourne/go-accounts/api/accountspb"
+	authClient "github.com/lolmourne/go-accounts/client/userauth"
+	"github.com/lolmourne/go-accounts/model"
+	"github.com/lolmourne/go-accounts/resource/acc"
+	"github.com/lolmourne/go-accounts/usecase/profile"
+	"github.com/lolmourne/go-accounts/usecase/userauth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const maxBatchUsers = 100
+
+var errInternal = status.Error(codes.Internal, "Internal Server Error")
+
+type Server struct {
+	accountspb.UnimplementedAccountsServer
+	userAuth userauth.UsecaseItf
+	profile  profile.IUsecase
+	dbRsc    acc.DBItf
+}
+
+func NewServer(userAuth userauth.UsecaseItf, profile profile.IUsecase, dbRsc acc.DBItf) *Server {
+	return &Server{
+		userAuth: userAuth,
+		profile:  profile,
+		dbRsc:    dbRsc,
+	}
+}
+
+// ValidateToken is the /user/info of gRPC, it checks no scope so services
+// can resolve any token.
+func (s *Server) ValidateToken(ctx context.Context, req *accountspb.ValidateTokenRequest) (*accountspb.User, error) {
+	claims, err := s.validate(req.GetAccessToken())
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.dbRsc.GetUserByUserID(claims.UserID)
+	if err != nil {
+		log.Println(err)
+		return nil, errInternal
+	}
+	if user.UserID == 0 {
+		return nil, status.Error(codes.Unauthenticated, "Cannot validate session")
+	}
+
+	resp := userToProto(user.Public())
+	resp.Scopes = claims.Scopes
+	return resp, nil
+}
+
+func (s *Server) GetUser(ctx context.Context, req *accountspb.GetUserRequest) (*accountspb.User, error) {
+	if req.GetUserId() < 1 {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+
+	user, err := s.dbRsc.GetUserByUserID(req.GetUserId())
+	if err != nil {
+		log.Println(err)
+		return nil, errInternal
+	}
+	if user.UserID == 0 {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	return userToProto(user.Public()), nil
+}
+
+func (s *Server) GetUsers(ctx context.Context, req *accountspb.GetUsersRequest) (*accountspb.GetUsersResponse, error) {
+	if len(req.GetUserIds()) > maxBatchUsers {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("at most %d users can be requested at once", maxBatchUsers))
+	}
+
+	seen := make(map[int64]bool, len(req.GetUserIds()))
+	userIDs := make([]int64, 0, len(req.GetUserIds()))
+	for _, userID := range req.GetUserIds() {
+		if userID < 1 || seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		userIDs = append(userIDs, userID)
+	}
+
+	users, err := s.dbRsc.GetUsersByIDs(userIDs)
+	if err != nil {
+		log.Println(err)
+		return nil, errInternal
+	}
+
+	byID := make(map[int64]model.User, len(users))
+	for _, user := range users {
+		byID[user.UserID] = user.Public()
+	}
+
+	resp := &accountspb.GetUsersResponse{}
+	for _, userID := range userIDs {
+		if user, ok := byID[userID]; ok {
+			resp.Users = append(resp.Users, userToProto(user))
+		}
+	}
+	return resp, nil
+}
+
+func (s *Server) SearchUsers(ctx context.Context, req *accountspb.SearchUsersRequest) (*accountspb.SearchUsersResponse, error) {
+	claims, err := s.authenticate(ctx, model.ScopeRead)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := s.profile.SearchUsers(claims.UserID, req.GetQuery(), req.GetCursor(), int(req.GetLimit()))
+	if err == profile.ErrInvalidSearchQuery || err == profile.ErrInvalidCursor {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, errInternal
+	}
+
+	resp := &accountspb.SearchUsersResponse{
+		NextCursor: page.NextCursor,
+	}
+	for _, user := range page.Users {
+		resp.Users = append(resp.Users, userToProto(user))
+	}
+	return resp, nil
+}
+
+func (s *Server) GetBlockedUsers(ctx context.Context, req *accountspb.GetBlockedUsersRequest) (*accountspb.GetBlockedUsersResponse, error) {
+	claims, err := s.authenticate(ctx, model.ScopeRead)
+	if err != nil {
+		return nil, err
+	}
+
+	blocked, err := s.profile.GetBlockedUsers(claims.UserID)
+	if err != nil {
+		return nil, errInternal
+	}
+
+	resp := &accountspb.GetBlockedUsersResponse{}
+	for _, b := range blocked {
+		resp.BlockedUsers = append(resp.BlockedUsers, &accountspb.BlockedUser{
+			User:      userToProto(b.User),
+			BlockedAt: timestamppb.New(b.BlockedAt),
+		})
+	}
+	return resp, nil
+}
+
+func (s *Server) IsBlocked(ctx context.Context, req *accountspb.IsBlockedRequest) (*accountspb.IsBlockedResponse, error) {
+	claims, err := s.authenticate(ctx, model.ScopeRead)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetUserId() < 1 {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+
+	blocked, err := s.profile.IsBlocked(claims.UserID, req.GetUserId())
+	if err != nil {
+		return nil, errInternal
+	}
+	return &accountspb.IsBlockedResponse{Blocked: blocked}, nil
+}
+
+// authenticate validates the access token in the metadata of ctx like the
+// validateSession middleware: personal access tokens need scope.
+func (s *Server) authenticate(ctx context.Context, scope string) (model.AccessClaims, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	accessToken := md.Get(authClient.AccessTokenMetadata)
+	if len(accessToken) < 1 {
+		return model.AccessClaims{}, status.Error(codes.Unauthenticated, "No access token provided")
+	}
+
+	claims, err := s.validate(accessToken[0])
+	if err != nil {
+		return model.AccessClaims{}, err
+	}
+	if claims.Scopes != nil && !model.HasScope(claims.Scopes, scope) {
+		return model.AccessClaims{}, status.Error(codes.PermissionDenied, "Token lacks the "+scope+" scope")
+	}
+	return claims, nil
+}
+
+func (s *Server) validate(accessToken string) (model.AccessClaims, error) {
+	if accessToken == "" {
+		return model.AccessClaims{}, status.Error(codes.Unauthenticated, "No access token provided")
+	}
+
+	claims, err := s.userAuth.ValidateSession(accessToken)
+	if err == userauth.ErrInvalidAccessToken {
+		return model.AccessClaims{}, status.Error(codes.Unauthenticated, "Cannot validate session")
+	}
+	if err != nil {
+		return model.AccessClaims{}, errInternal
+	}
+	return claims, nil
+}
+
+func userToProto(user model.User) *accountspb.User {
+	resp := &accountspb.User{
+		UserId:             user.UserID,
+		Username:           user.Username,
+		CreatedAt:          timestamppb.New(user.CreatedAt),
+		ProfilePic:         user.ProfilePic,
+		ProfilePicVariants: user.ProfilePicVariants,
+		DisplayName:        user.DisplayName,
+		Bio:                user.Bio,
+		Timezone:           user.Timezone,
+		Role:               user.Role,
+		Bot:                user.Bot,
+	}
+	if user.Status != nil {
+		resp.Status = &accountspb.UserStatus{
+			Text:  user.Status.Text,
+			Emoji: user.Status.Emoji,
+		}
+		if user.Status.ExpiresAt != nil {
+			resp.Status.ExpiresAt = timestamppb.New(*user.Status.ExpiresAt)
+		}
+	}
+	return resp
+}
@@ -1,39 +1,63 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	_ "net/http/pprof"
+	_ "time/tzdata"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	"github.com/lolmourne/go-accounts/api/accountspb"
+	"github.com/lolmourne/go-accounts/api/grpcserver"
+	authClient "github.com/lolmourne/go-accounts/client/userauth"
 	"github.com/lolmourne/go-accounts/model"
 	"github.com/lolmourne/go-accounts/resource/acc"
+	"github.com/lolmourne/go-accounts/resource/audit"
+	"github.com/lolmourne/go-accounts/resource/blob"
+	"github.com/lolmourne/go-accounts/resource/events"
+	"github.com/lolmourne/go-accounts/resource/export"
+	"github.com/lolmourne/go-accounts/resource/mailer"
 	"github.com/lolmourne/go-accounts/resource/monitoring"
-	"github.com/lolmourne/go-accounts/resource/s3"
+	"github.com/lolmourne/go-accounts/resource/presence"
+	"github.com/lolmourne/go-accounts/resource/ratelimit"
+	"github.com/lolmourne/go-accounts/usecase/contact"
 	"github.com/lolmourne/go-accounts/usecase/userauth"
 
 	"github.com/lolmourne/go-accounts/usecase/profile"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
 )
 
 var db *sqlx.DB
 var dbResource acc.DBItf
 var userAuthUsecase userauth.UsecaseItf
 var userProfielUsecase profile.IUsecase
+var contactUsecase contact.IUsecase
 var addr = flag.String("listen-address", ":7171", "The address to listen on for HTTP requests.")
 var prometheusMonitoring monitoring.IMonitoring
+var loginGuard ratelimit.Guard
+var blobStore blob.Store
+var clientIPs *ratelimit.IPResolver
+
+const maxBatchUsers = 100
 
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
@@ -52,8 +76,10 @@ func main() {
 		log.Fatal(err)
 	}
 
-	s3Res := s3.NewS3Resource(cfg)
-	userProfielUsecase = profile.NewUsecase(s3Res)
+	blobStore, err = blob.NewStore(cfg)
+	if err != nil {
+		log.Fatal("Cannot init file storage ", err)
+	}
 
 	dbConStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", cfg.DB.Address, cfg.DB.Port, cfg.DB.User, cfg.DB.Password, cfg.DB.DBName)
 
@@ -69,12 +95,60 @@ func main() {
 	})
 
 	dbRsc := acc.NewDBResource(dbInit)
-	dbRsc = acc.NewRedisResource(rdb, dbRsc)
+	dbRsc = acc.NewRedisResource(rdb, dbRsc, time.Duration(cfg.JWT.AccessTokenTTL)*time.Second)
 
 	dbResource = dbRsc
+	groupchatHost := cfg.Services.GroupchatHost
+	if groupchatHost == "" {
+		groupchatHost = "http://localhost:8080"
+	}
+	websocketHost := cfg.Services.WebsocketHost
+	if websocketHost == "" {
+		websocketHost = "http://localhost:90"
+	}
+	publisher := events.NewRedisPublisher(rdb)
+	userProfielUsecase = profile.NewUsecase(blobStore, dbRsc, []export.Source{
+		export.NewHTTPSource("groupchat", groupchatHost, time.Duration(30)*time.Second),
+		export.NewHTTPSource("messages", websocketHost, time.Duration(30)*time.Second),
+	}, publisher)
+	contactUsecase = contact.NewUsecase(dbRsc, publisher, presence.NewRedisPresence(rdb, presence.ConnectionTTL))
 	db = dbInit
 
-	userAuthUsecase = userauth.NewUsecase(dbRsc, cfg.JWT.SignKey)
+	passwordHasher := userauth.NewPasswordHasher(cfg.Password)
+	jwtKeys, err := userauth.NewKeySet(cfg.JWT)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	userAuthUsecase = userauth.NewUsecase(dbRsc, passwordHasher, jwtKeys, mailer.NewMailer(cfg.Mail), publisher, audit.NewDBResource(dbInit), cfg)
+	go purgeDeletedAccounts()
+	go retryPendingPurges()
+	go confirmPurges(rdb)
+
+	prometheusMonitoring = monitoring.NewPrometheusMonitoring()
+
+	loginGuard = ratelimit.NewRedisGuard(rdb, "login", ratelimit.GuardCfg{
+		MaxFailures:   cfg.RateLimit.MaxFailures,
+		FailureWindow: time.Duration(cfg.RateLimit.FailureWindow) * time.Second,
+		BaseLockout:   time.Duration(cfg.RateLimit.BaseLockout) * time.Second,
+		MaxLockout:    time.Duration(cfg.RateLimit.MaxLockout) * time.Second,
+	}, prometheusMonitoring.CountLockout)
+
+	loginPerMinute := cfg.RateLimit.LoginPerMinute
+	if loginPerMinute <= 0 {
+		loginPerMinute = 30
+	}
+	registerPerMinute := cfg.RateLimit.RegisterPerMinute
+	if registerPerMinute <= 0 {
+		registerPerMinute = 10
+	}
+	clientIPs, err = ratelimit.NewIPResolver(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	loginLimit := ratelimit.Middleware(ratelimit.NewRedisLimiter(rdb, "login", loginPerMinute, time.Minute), clientIPs.Key)
+	registerLimit := ratelimit.Middleware(ratelimit.NewRedisLimiter(rdb, "register", registerPerMinute, time.Minute), clientIPs.Key)
+	forgotLimit := ratelimit.Middleware(ratelimit.NewRedisLimiter(rdb, "password_forgot", 5, time.Minute), clientIPs.Key)
+	usernameLimit := ratelimit.Middleware(ratelimit.NewRedisLimiter(rdb, "username_available", 60, time.Minute), clientIPs.Key)
 
 	corsOpts := cors.Config{
 		AllowAllOrigins:  true,
@@ -85,15 +159,73 @@ func main() {
 	cors := cors.New(corsOpts)
 	r := gin.Default()
 	r.Use(cors)
-	r.POST("/register", register)
-	r.POST("/login", login)
+	r.GET("/.well-known/jwks.json", getJWKS)
+	r.POST("/register", registerLimit, register)
+	r.POST("/login", loginLimit, login)
+	r.POST("/login/2fa", loginLimit, loginTwoFactor)
+	r.POST("/token/refresh", refreshToken)
+	r.POST("/logout", validateSession(authClient.RequireSession(logout)))
+	r.GET("/sessions", validateSession(authClient.RequireSession(getSessions)))
+	r.DELETE("/sessions/:session_id", validateSession(authClient.RequireSession(deleteSession)))
 	r.GET("/usr/:user_id", getUser)
+	r.POST("/users/batch", getUsersBatch)
+	r.GET("/users/search", validateSession(searchUsers))
 	r.GET("/profile/:username", getProfile)
 	r.PUT("/profile", validateSession(updateProfile))
-	r.PUT("/password", validateSession(changePassword))
-	r.PUT("/username", validateSession(changeUsername))
-	r.GET("/user/info", validateSession(getUserInfo))
+	r.PATCH("/profile", validateSession(patchProfile))
+	r.PUT("/password", validateSession(authClient.RequireSession(changePassword)))
+	r.PUT("/username", validateSession(authClient.RequireSession(changeUsername)))
+	r.GET("/username/available", usernameLimit, checkUsername)
+	r.GET("/user/info", authenticate(false, getUserInfo))
 	r.POST("/upload", validateSession(uploadFile))
+	if blob.Driver(cfg) == blob.DriverLocal {
+		r.GET("/files/*key", getFile)
+	}
+	r.PUT("/email", validateSession(authClient.RequireSession(changeEmail)))
+	r.POST("/email/verify", verifyEmail)
+	r.POST("/password/forgot", forgotLimit, forgotPassword)
+	r.POST("/password/reset", resetPassword)
+	r.POST("/2fa/enroll", validateSession(authClient.RequireSession(enrollTwoFactor)))
+	r.POST("/2fa/confirm", validateSession(authClient.RequireSession(confirmTwoFactor)))
+	r.POST("/2fa/disable", validateSession(authClient.RequireSession(disableTwoFactor)))
+	r.POST("/me/deactivate", validateSession(authClient.RequireSession(deactivateAccount)))
+	r.DELETE("/me", validateSession(authClient.RequireSession(deleteAccount)))
+	r.POST("/reactivate", loginLimit, reactivateAccount)
+	r.GET("/me/export", validateSession(authClient.RequireSession(exportData)))
+	r.GET("/blocks", validateSession(getBlockedUsers))
+	r.POST("/blocks", validateSession(blockUser))
+	r.GET("/blocks/:user_id", validateSession(getBlockStatus))
+	r.DELETE("/blocks/:user_id", validateSession(unblockUser))
+	r.GET("/contacts", validateSession(getContacts))
+	r.DELETE("/contacts/:user_id", validateSession(removeContact))
+	r.GET("/contacts/:user_id/mutual", validateSession(getMutualContacts))
+	r.GET("/contact-requests", validateSession(getContactRequests))
+	r.POST("/contact-requests", validateSession(sendContactRequest))
+	r.POST("/contact-requests/:user_id/accept", validateSession(acceptContactRequest))
+	r.POST("/contact-requests/:user_id/decline", validateSession(declineContactRequest))
+	r.DELETE("/contact-requests/:user_id", validateSession(cancelContactRequest))
+	r.GET("/admin/users", validateSession(authClient.RequireSession(authClient.RequireRole(model.RoleAdmin, adminListUsers))))
+	r.PUT("/admin/users/:user_id/role", validateSession(authClient.RequireSession(authClient.RequireRole(model.RoleAdmin, adminChangeRole))))
+	r.POST("/admin/users/:user_id/suspend", validateSession(authClient.RequireSession(authClient.RequireRole(model.RoleAdmin, adminSuspendUser))))
+	r.DELETE("/admin/users/:user_id/suspend", validateSession(authClient.RequireSession(authClient.RequireRole(model.RoleAdmin, adminUnsuspendUser))))
+	r.POST("/admin/users/:user_id/password-reset", validateSession(authClient.RequireSession(authClient.RequireRole(model.RoleAdmin, adminForcePasswordReset))))
+	r.GET("/admin/audit", validateSession(authClient.RequireSession(authClient.RequireRole(model.RoleAdmin, adminGetAuditLog))))
+	r.GET("/me/activity", validateSession(authClient.RequireSession(getActivity)))
+	r.GET("/oidc/providers", getOIDCProviders)
+	r.GET("/oidc/:provider/login", startOIDCLogin)
+	r.POST("/oidc/:provider/link", validateSession(authClient.RequireSession(startOIDCLink)))
+	r.GET("/oidc/:provider/callback", loginLimit, finishOIDC)
+	r.GET("/me/identities", validateSession(authClient.RequireSession(getIdentities)))
+	r.DELETE("/me/identities/:provider", validateSession(authClient.RequireSession(unlinkIdentity)))
+	r.GET("/me/tokens", validateSession(authClient.RequireSession(getAccessTokens)))
+	r.POST("/me/tokens", validateSession(authClient.RequireSession(createAccessToken)))
+	r.DELETE("/me/tokens/:token_id", validateSession(authClient.RequireSession(revokeAccessToken)))
+	r.GET("/bots", validateSession(authClient.RequireSession(getBots)))
+	r.POST("/bots", validateSession(authClient.RequireSession(createBot)))
+	r.DELETE("/bots/:user_id", validateSession(authClient.RequireSession(deleteBot)))
+	r.GET("/bots/:user_id/tokens", validateSession(authClient.RequireSession(getAccessTokens)))
+	r.POST("/bots/:user_id/tokens", validateSession(authClient.RequireSession(createAccessToken)))
+	r.DELETE("/bots/:user_id/tokens/:token_id", validateSession(authClient.RequireSession(revokeAccessToken)))
 
 	http.Handle("/metrics", promhttp.Handler())
 	go func() {
@@ -102,13 +234,35 @@ func main() {
 	go func() {
 		log.Println(http.ListenAndServe("localhost:6060", nil))
 	}()
-
-	prometheusMonitoring = monitoring.NewPrometheusMonitoring()
+	go serveGRPC(cfg.GRPC)
 
 	r.Run(":7070")
 }
 
+func serveGRPC(cfg model.GRPCCfg) {
+	address := cfg.Address
+	if address == "" {
+		address = ":7272"
+	}
+
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	server := grpc.NewServer()
+	accountspb.RegisterAccountsServer(server, grpcserver.NewServer(userAuthUsecase, userProfielUsecase, dbResource))
+	log.Fatal(server.Serve(lis))
+}
+
 func validateSession(handlerFunc gin.HandlerFunc) gin.HandlerFunc {
+	return authenticate(true, handlerFunc)
+}
+
+// authenticate accepts session and personal access tokens. With checkScope
+// a personal access token needs read for GET requests and write for the
+// rest; /user/info skips it so other services can resolve any token.
+func authenticate(checkScope bool, handlerFunc gin.HandlerFunc) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		accessToken := c.Request.Header["X-Access-Token"]
 
@@ -119,18 +273,40 @@ func validateSession(handlerFunc gin.HandlerFunc) gin.HandlerFunc {
 			return
 		}
 
-		userID, err := userAuthUsecase.ValidateSession(accessToken[0])
-		if err != nil {
-			c.JSON(400, StandardAPIResponse{
+		claims, err := userAuthUsecase.ValidateSession(accessToken[0])
+		if err == userauth.ErrInvalidAccessToken {
+			c.JSON(401, StandardAPIResponse{
 				Err: "Cannot validate session",
 			})
 			return
 		}
-		c.Set("uid", userID)
+		if err != nil {
+			c.JSON(500, StandardAPIResponse{
+				Err: err.Error(),
+			})
+			return
+		}
+		if claims.Scopes != nil {
+			if checkScope && !model.HasScope(claims.Scopes, model.ScopeForMethod(c.Request.Method)) {
+				c.JSON(403, StandardAPIResponse{
+					Err: "Token lacks the " + model.ScopeForMethod(c.Request.Method) + " scope",
+				})
+				return
+			}
+			c.Set("scopes", claims.Scopes)
+		}
+		c.Set("uid", claims.UserID)
+		c.Set("sid", claims.SessionID)
+		c.Set("role", claims.Role)
 		handlerFunc(c)
 	}
 }
 
+func getJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(200, userAuthUsecase.JWKS())
+}
+
 func getUserInfo(c *gin.Context) {
 	userID := c.GetInt64("uid")
 	if userID < 1 {
@@ -148,58 +324,105 @@ func getUserInfo(c *gin.Context) {
 		return
 	}
 
+	scopes, _ := c.Get("scopes")
+	scopeList, _ := scopes.([]string)
 	c.JSON(200, StandardAPIResponse{
-		Err:  "",
-		Data: user,
+		Err: "",
+		Data: userInfo{
+			User:   user,
+			Scopes: scopeList,
+		},
 	})
 }
 
 func uploadFile(c *gin.Context) {
-	file, _, err := c.Request.FormFile("file")
+	// the multipart envelope adds a little on top of the file itself
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, profile.MaxAvatarBytes+64<<10)
+
+	reader, err := c.Request.MultipartReader()
 	if err != nil {
-		log.Println(err)
-		c.JSON(500, StandardAPIResponse{
-			Err: "Internal Server Error",
+		c.JSON(400, StandardAPIResponse{
+			Err: "expected a multipart upload",
 		})
 		return
 	}
 
-	fileb, err := ioutil.ReadAll(file)
-	if err != nil {
-		log.Println(err)
-		c.JSON(500, StandardAPIResponse{
-			Err: "Internal Server Error",
+	var file io.Reader
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+		if part.FormName() == "file" {
+			file = part
+			break
+		}
+	}
+	if file == nil {
+		c.JSON(400, StandardAPIResponse{
+			Err: "file is required",
 		})
 		return
 	}
 
+	filePath, err := userProfielUsecase.UploadFile(file)
 	if err != nil {
-		c.JSON(500, StandardAPIResponse{
-			Err: "Internal Server Error",
+		status := 400
+		var maxBytesErr *http.MaxBytesError
+		if err == profile.ErrAvatarTooLarge || errors.As(err, &maxBytesErr) {
+			status = http.StatusRequestEntityTooLarge
+			err = profile.ErrAvatarTooLarge
+		}
+		c.JSON(status, StandardAPIResponse{
+			Err: err.Error(),
 		})
 		return
 	}
+	c.JSON(200, StandardAPIResponse{
+		Message: filePath,
+		Data:    model.AvatarVariantURLs(filePath),
+	})
+}
 
-	filePath, err := userProfielUsecase.UploadFile(fileb)
+func getFile(c *gin.Context) {
+	data, mime, err := blobStore.Get(strings.TrimPrefix(c.Param("key"), "/"))
+	if err == blob.ErrNotFound {
+		c.JSON(http.StatusNotFound, StandardAPIResponse{
+			Err: "file not found",
+		})
+		return
+	}
 	if err != nil {
+		log.Println(err)
 		c.JSON(500, StandardAPIResponse{
-			Err: err.Error(),
+			Err: "Internal Server Error",
 		})
 		return
 	}
-	c.JSON(200, StandardAPIResponse{
-		Message: filePath,
-	})
+
+	if mime == "" {
+		mime = "application/octet-stream"
+	}
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(200, mime, data)
 }
 
 func register(c *gin.Context) {
 	username := c.Request.FormValue("username")
 	password := c.Request.FormValue("password")
 	confirmPassword := c.Request.FormValue("confirm_password")
+	email := c.Request.FormValue("email")
 
-	err := userAuthUsecase.Register(username, password, confirmPassword)
+	err := userAuthUsecase.Register(username, password, confirmPassword, email)
 	if err != nil {
-		c.JSON(400, StandardAPIResponse{
+		status := usernameErrStatus(err)
+		switch err {
+		case userauth.ErrPasswordMismatch, userauth.ErrInvalidEmail:
+			status = 400
+		case userauth.ErrEmailTaken:
+			status = http.StatusConflict
+		}
+		c.JSON(status, StandardAPIResponse{
 			Err:     err.Error(),
 			Message: "Failed",
 		})
@@ -217,105 +440,169 @@ func login(c *gin.Context) {
 	username := c.Request.FormValue("username")
 	password := c.Request.FormValue("password")
 
-	user, err := userAuthUsecase.Login(username, password)
+	subjects := []ratelimit.Subject{
+		{Scope: "username", Value: strings.ToLower(username)},
+		{Scope: "ip", Value: clientIPs.ClientIP(c.Request)},
+	}
+	if guardLockedOut(c, subjects...) {
+		processTime := time.Since(startTime).Milliseconds()
+		prometheusMonitoring.CountLogin("/login", 429, "locked out", float64(processTime))
+		return
+	}
+
+	userToken, err := userAuthUsecase.Login(username, password, clientInfo(c))
 	if err != nil {
 		processTime := time.Since(startTime).Milliseconds()
+		if err == userauth.ErrInvalidCredentials {
+			guardFail(c, subjects...)
+		}
 
-		prometheusMonitoring.CountLogin("/login", 400, err.Error(), float64(processTime))
-		c.JSON(400, StandardAPIResponse{
+		status := 400
+		switch err {
+		case userauth.ErrAccountDeactivated, userauth.ErrAccountSuspended, userauth.ErrPasswordResetRequired:
+			status = http.StatusForbidden
+		}
+
+		prometheusMonitoring.CountLogin("/login", status, err.Error(), float64(processTime))
+		c.JSON(status, StandardAPIResponse{
 			Err:     err.Error(),
 			Message: "Failed",
 		})
 
 		return
 	}
+
+	err = loginGuard.Reset(c.Request.Context(), subjects[0])
+	if err != nil {
+		log.Println(err)
+	}
+
 	processTime := time.Since(startTime).Milliseconds()
 	prometheusMonitoring.CountLogin("/login", 200, "nil", float64(processTime))
 	c.JSON(200, StandardAPIResponse{
-		Data: user,
+		Data: userToken,
 	})
 }
 
-func getUser(c *gin.Context) {
-	userIDStr := c.Param("user_id")
+func loginTwoFactor(c *gin.Context) {
+	challengeToken := c.Request.FormValue("challenge_token")
+	code := c.Request.FormValue("code")
 
-	userID, err := strconv.ParseInt(userIDStr, 10, 64)
-	if err != nil {
-		c.JSON(500, StandardAPIResponse{
-			Err: "Internal Server Error",
-		})
+	// codes are counted per user too, a new challenge does not reset them
+	subjects := []ratelimit.Subject{
+		{Scope: "ip", Value: clientIPs.ClientIP(c.Request)},
+	}
+	userID, err := userAuthUsecase.ChallengeUserID(challengeToken)
+	if err == nil {
+		subjects = append(subjects, ratelimit.Subject{Scope: "2fa_user", Value: strconv.FormatInt(userID, 10)})
+	}
+	if guardLockedOut(c, subjects...) {
 		return
 	}
 
-	if userID < 1 {
-		c.JSON(401, StandardAPIResponse{
-			Err: "Unauthorized",
+	userToken, err := userAuthUsecase.LoginTwoFactor(challengeToken, code, clientInfo(c))
+	if err == userauth.ErrInvalidTOTPCode {
+		guardFail(c, subjects...)
+	}
+	if err != nil {
+		status := 401
+		if err == userauth.ErrAccountSuspended || err == userauth.ErrPasswordResetRequired {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, StandardAPIResponse{
+			Err:     err.Error(),
+			Message: "Failed",
 		})
 		return
 	}
 
-	user, err := dbResource.GetUserByUserID(userID)
+	err = loginGuard.Reset(c.Request.Context(), subjects[1:]...)
 	if err != nil {
-		c.JSON(400, StandardAPIResponse{
-			Err: "Unauthorized",
-		})
-		return
+		log.Println(err)
 	}
 
-	if user.UserID == 0 {
-		c.JSON(http.StatusNotFound, StandardAPIResponse{
-			Err: "user not found",
+	c.JSON(200, StandardAPIResponse{
+		Data: userToken,
+	})
+}
+
+func enrollTwoFactor(c *gin.Context) {
+	userID := c.GetInt64("uid")
+
+	enrollment, err := userAuthUsecase.EnrollTOTP(userID)
+	if err != nil {
+		c.JSON(400, StandardAPIResponse{
+			Err: err.Error(),
 		})
 		return
 	}
 
-	user.Salt = ""
-	user.Password = ""
-
 	c.JSON(200, StandardAPIResponse{
 		Err:  "null",
-		Data: user,
+		Data: enrollment,
 	})
 }
 
-func getProfile(c *gin.Context) {
-	username := c.Param("username")
+func confirmTwoFactor(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	code := c.Request.FormValue("code")
 
-	user, err := dbResource.GetUserByUserName(username)
+	recoveryCodes, err := userAuthUsecase.ConfirmTOTP(userID, code)
 	if err != nil {
 		c.JSON(400, StandardAPIResponse{
-			Err: "Unauthorized",
+			Err: err.Error(),
 		})
 		return
 	}
 
-	if user.UserID == 0 {
-		c.JSON(http.StatusNotFound, StandardAPIResponse{
-			Err: "user not found",
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "Two factor authentication enabled",
+		Data:    recoveryCodes,
+	})
+}
+
+func disableTwoFactor(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	password := c.Request.FormValue("password")
+
+	err := userAuthUsecase.DisableTOTP(userID, password, c.GetString("sid"), clientInfo(c))
+	if err != nil {
+		c.JSON(400, StandardAPIResponse{
+			Err: err.Error(),
 		})
 		return
 	}
 
-	user.Password = ""
-	user.Salt = ""
-
 	c.JSON(200, StandardAPIResponse{
-		Err:  "null",
-		Data: user,
+		Err:     "null",
+		Message: "Two factor authentication disabled",
 	})
 }
 
-func updateProfile(c *gin.Context) {
+func deactivateAccount(c *gin.Context) {
 	userID := c.GetInt64("uid")
-	if userID < 1 {
+	password := c.Request.FormValue("password")
+
+	err := userAuthUsecase.DeactivateAccount(userID, password, c.GetString("sid"))
+	if err != nil {
 		c.JSON(400, StandardAPIResponse{
-			Err: "no user founds",
+			Err: err.Error(),
 		})
 		return
 	}
 
-	profilepic := c.Request.FormValue("profile_pic")
-	err := dbResource.UpdateUserProfpic(userID, profilepic)
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "Account deactivated, sign in through /reactivate to come back",
+	})
+}
+
+func deleteAccount(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	password := c.Request.FormValue("password")
+
+	deleteAfter, err := userAuthUsecase.DeleteAccount(userID, password, c.GetString("sid"))
 	if err != nil {
 		c.JSON(400, StandardAPIResponse{
 			Err: err.Error(),
@@ -323,24 +610,142 @@ func updateProfile(c *gin.Context) {
 		return
 	}
 
-	newToken, err := userAuthUsecase.GenerateJWT(userID, profilepic)
-
-	c.JSON(201, StandardAPIResponse{
+	c.JSON(202, StandardAPIResponse{
 		Err:     "null",
-		Message: newToken,
+		Message: "Account scheduled for deletion, reactivate it before then to cancel",
+		Data: gin.H{
+			"delete_after": deleteAfter,
+		},
 	})
 }
 
-func changeUsername(c *gin.Context) {
+func reactivateAccount(c *gin.Context) {
+	username := c.Request.FormValue("username")
+	password := c.Request.FormValue("password")
+
+	subjects := []ratelimit.Subject{
+		{Scope: "username", Value: strings.ToLower(username)},
+		{Scope: "ip", Value: clientIPs.ClientIP(c.Request)},
+	}
+	if guardLockedOut(c, subjects...) {
+		return
+	}
+
+	userToken, err := userAuthUsecase.ReactivateAccount(username, password, clientInfo(c))
+	if err != nil {
+		if err == userauth.ErrInvalidCredentials {
+			guardFail(c, subjects...)
+		}
+		status := 400
+		if err == userauth.ErrAccountSuspended || err == userauth.ErrPasswordResetRequired {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, StandardAPIResponse{
+			Err:     err.Error(),
+			Message: "Failed",
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Data: userToken,
+	})
+}
+
+func exportData(c *gin.Context) {
 	userID := c.GetInt64("uid")
-	if userID < 1 {
-		c.JSON(400, StandardAPIResponse{
-			Err: "no user founds",
+	accessToken := c.Request.Header.Get("X-Access-Token")
+
+	var buf bytes.Buffer
+	err := userProfielUsecase.ExportData(userID, accessToken, &buf)
+	if err != nil {
+		c.JSON(500, StandardAPIResponse{
+			Err: err.Error(),
 		})
 		return
 	}
-	username := c.Request.FormValue("username")
-	err := userAuthUsecase.ChangeUsername(userID, username)
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="export-%d.zip"`, userID))
+	c.Data(200, "application/zip", buf.Bytes())
+}
+
+// purgeDeletedAccounts anonymizes accounts whose deletion grace period is
+// over, every hour.
+func purgeDeletedAccounts() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for ; true; <-ticker.C {
+		purged, err := userAuthUsecase.PurgeDeletedAccounts()
+		if err != nil {
+			log.Println("purge deleted accounts:", err)
+		}
+		for _, user := range purged {
+			userProfielUsecase.DeleteAvatar(user.ProfilePic)
+		}
+		if len(purged) > 0 {
+			log.Println("purged", len(purged), "deleted accounts")
+		}
+	}
+}
+
+// retryPendingPurges publishes deletions again until every service has
+// confirmed them, every few minutes.
+func retryPendingPurges() {
+	ticker := time.NewTicker(time.Duration(5) * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		retried, err := userAuthUsecase.RetryPendingPurges()
+		if err != nil {
+			log.Println("retry pending purges:", err)
+		}
+		if retried > 0 {
+			log.Println("published", retried, "unconfirmed account deletions again")
+		}
+	}
+}
+
+// confirmPurges records the AccountPurgedEvents the services send once
+// they scrubbed a deleted account.
+func confirmPurges(rdb *redis.Client) {
+	sub := rdb.Subscribe(context.Background(), authClient.AccountPurgedChannel)
+	defer sub.Close()
+
+	for msg := range sub.Channel() {
+		var event authClient.AccountPurgedEvent
+		err := json.Unmarshal([]byte(msg.Payload), &event)
+		if err != nil || event.UserID < 1 {
+			log.Println("invalid account purged event", msg.Payload)
+			continue
+		}
+
+		err = userAuthUsecase.ConfirmPurge(event.UserID, event.Service)
+		if err != nil {
+			log.Println("cannot confirm purge of user", event.UserID, "by", event.Service, err)
+		}
+	}
+}
+
+func refreshToken(c *gin.Context) {
+	token := c.Request.FormValue("refresh_token")
+
+	userToken, err := userAuthUsecase.RefreshToken(token, clientInfo(c))
+	if err != nil {
+		c.JSON(401, StandardAPIResponse{
+			Err:     err.Error(),
+			Message: "Failed",
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Data: userToken,
+	})
+}
+
+func logout(c *gin.Context) {
+	err := userAuthUsecase.Logout(c.GetString("sid"))
 	if err != nil {
 		c.JSON(400, StandardAPIResponse{
 			Err: err.Error(),
@@ -348,31 +753,1256 @@ func changeUsername(c *gin.Context) {
 		return
 	}
 
-	c.JSON(201, StandardAPIResponse{
+	c.JSON(200, StandardAPIResponse{
 		Err:     "null",
-		Message: "Success update profile picture",
+		Message: "Success logout",
 	})
 }
 
-func changePassword(c *gin.Context) {
+func getSessions(c *gin.Context) {
 	userID := c.GetInt64("uid")
 
-	oldpass := c.Request.FormValue("old_password")
-	newpass := c.Request.FormValue("new_password")
-	confirmpass := c.Request.FormValue("confirm_password")
-
-	err := userAuthUsecase.ChangePassword(userID, oldpass, newpass, confirmpass)
+	sessions, err := userAuthUsecase.GetSessions(userID, c.GetString("sid"))
 	if err != nil {
-		c.JSON(400, StandardAPIResponse{
+		c.JSON(500, StandardAPIResponse{
 			Err: err.Error(),
 		})
 		return
 	}
 
-	c.JSON(201, StandardAPIResponse{
-		Err:     "null",
-		Message: "Success update password",
-	})
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: sessions,
+	})
+}
+
+func deleteSession(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	sessionID := c.Param("session_id")
+
+	err := userAuthUsecase.RevokeSession(userID, sessionID)
+	if err == userauth.ErrSessionNotFound {
+		c.JSON(http.StatusNotFound, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(500, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "Success revoke session",
+	})
+}
+
+func getUser(c *gin.Context) {
+	userIDStr := c.Param("user_id")
+
+	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	if err != nil {
+		c.JSON(500, StandardAPIResponse{
+			Err: "Internal Server Error",
+		})
+		return
+	}
+
+	if userID < 1 {
+		c.JSON(401, StandardAPIResponse{
+			Err: "Unauthorized",
+		})
+		return
+	}
+
+	user, err := dbResource.GetUserByUserID(userID)
+	if err != nil {
+		c.JSON(400, StandardAPIResponse{
+			Err: "Unauthorized",
+		})
+		return
+	}
+
+	if user.UserID == 0 {
+		c.JSON(http.StatusNotFound, StandardAPIResponse{
+			Err: "user not found",
+		})
+		return
+	}
+
+	user = user.Public()
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: user,
+	})
+}
+
+func getUsersBatch(c *gin.Context) {
+	var req struct {
+		UserIDs []int64 `json:"user_ids"`
+	}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(400, StandardAPIResponse{
+			Err: "user_ids must be a list of user ids",
+		})
+		return
+	}
+
+	if len(req.UserIDs) > maxBatchUsers {
+		c.JSON(400, StandardAPIResponse{
+			Err: fmt.Sprintf("at most %d users can be requested at once", maxBatchUsers),
+		})
+		return
+	}
+
+	seen := make(map[int64]bool, len(req.UserIDs))
+	userIDs := make([]int64, 0, len(req.UserIDs))
+	for _, userID := range req.UserIDs {
+		if userID < 1 || seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		userIDs = append(userIDs, userID)
+	}
+
+	users, err := dbResource.GetUsersByIDs(userIDs)
+	if err != nil {
+		log.Println(err)
+		c.JSON(500, StandardAPIResponse{
+			Err: "Internal Server Error",
+		})
+		return
+	}
+
+	byID := make(map[int64]model.User, len(users))
+	for _, user := range users {
+		byID[user.UserID] = user.Public()
+	}
+
+	// unknown ids are left out, the rest keep the order they were asked in
+	result := make([]model.User, 0, len(users))
+	for _, userID := range userIDs {
+		if user, ok := byID[userID]; ok {
+			result = append(result, user)
+		}
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: result,
+	})
+}
+
+func searchUsers(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	page, err := userProfielUsecase.SearchUsers(c.GetInt64("uid"), c.Query("q"), c.Query("cursor"), limit)
+	if err != nil {
+		status := 400
+		if err != profile.ErrInvalidSearchQuery && err != profile.ErrInvalidCursor {
+			status = 500
+		}
+		c.JSON(status, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: page,
+	})
+}
+
+func getBlockedUsers(c *gin.Context) {
+	blocked, err := userProfielUsecase.GetBlockedUsers(c.GetInt64("uid"))
+	if err != nil {
+		c.JSON(500, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: blocked,
+	})
+}
+
+func blockUser(c *gin.Context) {
+	blockedUserID, err := strconv.ParseInt(c.Request.FormValue("user_id"), 10, 64)
+	if err != nil || blockedUserID < 1 {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid user id",
+		})
+		return
+	}
+
+	err = userProfielUsecase.BlockUser(c.GetInt64("uid"), blockedUserID)
+	if err != nil {
+		status := 500
+		switch err {
+		case profile.ErrBlockSelf:
+			status = 400
+		case profile.ErrUserNotFound:
+			status = http.StatusNotFound
+		}
+		c.JSON(status, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "User blocked",
+	})
+}
+
+func unblockUser(c *gin.Context) {
+	blockedUserID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || blockedUserID < 1 {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid user id",
+		})
+		return
+	}
+
+	err = userProfielUsecase.UnblockUser(c.GetInt64("uid"), blockedUserID)
+	if err != nil {
+		c.JSON(500, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "User unblocked",
+	})
+}
+
+// getBlockStatus tells whether the caller and user_id blocked each other,
+// in either direction. Services use it to refuse contact between them.
+func getBlockStatus(c *gin.Context) {
+	otherUserID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || otherUserID < 1 {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid user id",
+		})
+		return
+	}
+
+	blocked, err := userProfielUsecase.IsBlocked(c.GetInt64("uid"), otherUserID)
+	if err != nil {
+		c.JSON(500, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err: "null",
+		Data: gin.H{
+			"blocked": blocked,
+		},
+	})
+}
+
+func adminListUsers(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	page, err := userAuthUsecase.ListUsers(c.Query("role"), c.Query("cursor"), limit)
+	if err != nil {
+		c.JSON(adminErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: page,
+	})
+}
+
+func adminChangeRole(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || userID < 1 {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid user id",
+		})
+		return
+	}
+
+	role := c.Request.FormValue("role")
+	err = userAuthUsecase.ChangeRole(c.GetInt64("uid"), userID, role, clientInfo(c))
+	if err != nil {
+		c.JSON(adminErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "Role changed to " + role,
+	})
+}
+
+func adminSuspendUser(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || userID < 1 {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid user id",
+		})
+		return
+	}
+
+	err = userAuthUsecase.SuspendUser(c.GetInt64("uid"), userID, clientInfo(c))
+	if err != nil {
+		c.JSON(adminErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "Account suspended",
+	})
+}
+
+func adminUnsuspendUser(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || userID < 1 {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid user id",
+		})
+		return
+	}
+
+	err = userAuthUsecase.UnsuspendUser(c.GetInt64("uid"), userID, clientInfo(c))
+	if err != nil {
+		c.JSON(adminErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "Suspension lifted",
+	})
+}
+
+func adminForcePasswordReset(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || userID < 1 {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid user id",
+		})
+		return
+	}
+
+	err = userAuthUsecase.ForcePasswordReset(c.GetInt64("uid"), userID, clientInfo(c))
+	if err != nil {
+		c.JSON(adminErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "User signed out and asked to reset the password",
+	})
+}
+
+// adminGetAuditLog searches the audit log. Every filter is optional; since
+// and until are RFC 3339 times.
+func adminGetAuditLog(c *gin.Context) {
+	filter := model.AuditFilter{
+		Action:    c.Query("action"),
+		IPAddress: c.Query("ip"),
+	}
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+
+	var err error
+	for param, dst := range map[string]*int64{"user_id": &filter.UserID, "actor_id": &filter.ActorID} {
+		if c.Query(param) == "" {
+			continue
+		}
+		*dst, err = strconv.ParseInt(c.Query(param), 10, 64)
+		if err != nil {
+			c.JSON(400, StandardAPIResponse{
+				Err: "invalid " + param,
+			})
+			return
+		}
+	}
+	for param, dst := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if c.Query(param) == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, c.Query(param))
+		if err != nil {
+			c.JSON(400, StandardAPIResponse{
+				Err: param + " must be an RFC 3339 time",
+			})
+			return
+		}
+		*dst = &t
+	}
+
+	page, err := userAuthUsecase.GetAuditLog(filter, c.Query("cursor"))
+	if err != nil {
+		c.JSON(adminErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: page,
+	})
+}
+
+func getActivity(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	page, err := userAuthUsecase.GetActivity(c.GetInt64("uid"), c.Query("cursor"), limit)
+	if err != nil {
+		c.JSON(adminErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: page,
+	})
+}
+
+func adminErrStatus(err error) int {
+	switch err {
+	case userauth.ErrInvalidRole, userauth.ErrInvalidCursor, userauth.ErrSelfAdminAction:
+		return 400
+	case userauth.ErrUserNotFound:
+		return http.StatusNotFound
+	}
+	return 500
+}
+
+func getProfile(c *gin.Context) {
+	username := c.Param("username")
+
+	user, err := dbResource.GetUserByUserName(username)
+	if err != nil {
+		c.JSON(400, StandardAPIResponse{
+			Err: "Unauthorized",
+		})
+		return
+	}
+
+	// deactivated and deleted accounts have no public profile
+	if user.UserID == 0 || user.DeactivatedAt != nil || user.Deleted {
+		c.JSON(http.StatusNotFound, StandardAPIResponse{
+			Err: "user not found",
+		})
+		return
+	}
+
+	user = user.Public()
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: user,
+	})
+}
+
+func updateProfile(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID < 1 {
+		c.JSON(400, StandardAPIResponse{
+			Err: "no user founds",
+		})
+		return
+	}
+
+	profilepic := c.Request.FormValue("profile_pic")
+	err := userAuthUsecase.ChangeProfilePic(userID, profilepic, clientInfo(c))
+	if err != nil {
+		c.JSON(400, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	user, err := dbResource.GetUserByUserID(userID)
+	if err != nil {
+		c.JSON(500, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+	user.ProfilePic = profilepic
+
+	// personal access tokens cannot be traded for a session's token
+	if _, ok := c.Get("scopes"); ok {
+		c.JSON(201, StandardAPIResponse{
+			Err:     "null",
+			Message: "Profile updated",
+		})
+		return
+	}
+
+	newToken, err := userAuthUsecase.GenerateJWT(user, c.GetString("sid"))
+	if err != nil {
+		c.JSON(500, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(201, StandardAPIResponse{
+		Err:     "null",
+		Message: newToken,
+	})
+}
+
+// patchProfile updates only the fields present in the JSON body.
+func patchProfile(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID < 1 {
+		c.JSON(400, StandardAPIResponse{
+			Err: "no user founds",
+		})
+		return
+	}
+
+	var update model.ProfileUpdate
+	err := c.ShouldBindJSON(&update)
+	if err != nil {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid profile update",
+		})
+		return
+	}
+
+	user, err := userProfielUsecase.UpdateProfile(userID, update)
+	if err != nil {
+		c.JSON(400, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	user.Salt = ""
+	user.Password = ""
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: user,
+	})
+}
+
+func changeUsername(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID < 1 {
+		c.JSON(400, StandardAPIResponse{
+			Err: "no user founds",
+		})
+		return
+	}
+	username := c.Request.FormValue("username")
+	err := userAuthUsecase.ChangeUsername(userID, username, clientInfo(c))
+	if err != nil {
+		c.JSON(usernameErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(201, StandardAPIResponse{
+		Err:     "null",
+		Message: "Success update username",
+	})
+}
+
+func checkUsername(c *gin.Context) {
+	err := userAuthUsecase.CheckUsername(c.Query("username"))
+	if err != nil && usernameErrStatus(err) == 500 {
+		c.JSON(500, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	reason := ""
+	if err != nil {
+		reason = err.Error()
+	}
+	c.JSON(200, StandardAPIResponse{
+		Err: "null",
+		Data: gin.H{
+			"available": err == nil,
+			"reason":    reason,
+		},
+	})
+}
+
+// usernameErrStatus maps username policy errors to HTTP statuses, anything
+// else is internal.
+func usernameErrStatus(err error) int {
+	switch err {
+	case userauth.ErrUsernameInvalid, userauth.ErrUsernameUnchanged:
+		return 400
+	case userauth.ErrUsernameTaken, userauth.ErrUsernameReserved:
+		return http.StatusConflict
+	case userauth.ErrUsernameCooldown:
+		return http.StatusTooManyRequests
+	}
+	return 500
+}
+
+func changePassword(c *gin.Context) {
+	userID := c.GetInt64("uid")
+
+	oldpass := c.Request.FormValue("old_password")
+	newpass := c.Request.FormValue("new_password")
+	confirmpass := c.Request.FormValue("confirm_password")
+
+	err := userAuthUsecase.ChangePassword(userID, oldpass, newpass, confirmpass, clientInfo(c))
+	if err != nil {
+		c.JSON(400, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(201, StandardAPIResponse{
+		Err:     "null",
+		Message: "Success update password",
+	})
+}
+
+// guardLockedOut responds with 429 when any of the subjects is locked out.
+func guardLockedOut(c *gin.Context, subjects ...ratelimit.Subject) bool {
+	retryAfter, err := loginGuard.Check(c.Request.Context(), subjects...)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	if retryAfter <= 0 {
+		return false
+	}
+
+	ratelimit.TooManyRequests(c, retryAfter)
+	return true
+}
+
+func guardFail(c *gin.Context, subjects ...ratelimit.Subject) {
+	_, err := loginGuard.Fail(c.Request.Context(), subjects...)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func clientInfo(c *gin.Context) model.ClientInfo {
+	return model.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: clientIPs.ClientIP(c.Request),
+	}
+}
+
+func changeEmail(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	password := c.Request.FormValue("password")
+	email := c.Request.FormValue("email")
+
+	err := userAuthUsecase.ChangeEmail(userID, password, c.GetString("sid"), email, clientInfo(c))
+	if err == userauth.ErrEmailTaken {
+		c.JSON(http.StatusConflict, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(400, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "Verification email sent",
+	})
+}
+
+func verifyEmail(c *gin.Context) {
+	token := c.Request.FormValue("token")
+
+	err := userAuthUsecase.VerifyEmail(token)
+	if err != nil {
+		c.JSON(400, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "Email verified",
+	})
+}
+
+func forgotPassword(c *gin.Context) {
+	email := c.Request.FormValue("email")
+
+	err := userAuthUsecase.ForgotPassword(email)
+	if err != nil {
+		c.JSON(400, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "If the address belongs to a verified account a reset link was sent",
+	})
+}
+
+func resetPassword(c *gin.Context) {
+	token := c.Request.FormValue("token")
+	newpass := c.Request.FormValue("new_password")
+	confirmpass := c.Request.FormValue("confirm_password")
+
+	err := userAuthUsecase.ResetPassword(token, newpass, confirmpass, clientInfo(c))
+	if err != nil {
+		c.JSON(400, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "Success reset password",
+	})
+}
+
+// oidcFlowCookie carries the signed flow token from the start of an OIDC
+// sign in to the callback, tying the callback to the browser that began it.
+const oidcFlowCookie = "oidc_flow"
+
+func getOIDCProviders(c *gin.Context) {
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: userAuthUsecase.OIDCProviders(),
+	})
+}
+
+func startOIDCLogin(c *gin.Context) {
+	authURL, flowToken, err := userAuthUsecase.StartOIDC(c.Param("provider"), 0)
+	if err != nil {
+		c.JSON(oidcErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	setOIDCFlowCookie(c, flowToken, int(10*time.Minute/time.Second))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// startOIDCLink starts linking a provider to the signed in account. The
+// client has to open authorization_url in the browser that received the
+// flow cookie.
+func startOIDCLink(c *gin.Context) {
+	authURL, flowToken, err := userAuthUsecase.StartOIDC(c.Param("provider"), c.GetInt64("uid"))
+	if err != nil {
+		c.JSON(oidcErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	setOIDCFlowCookie(c, flowToken, int(10*time.Minute/time.Second))
+	c.JSON(200, StandardAPIResponse{
+		Err: "null",
+		Data: map[string]string{
+			"authorization_url": authURL,
+		},
+	})
+}
+
+func finishOIDC(c *gin.Context) {
+	flowToken, _ := c.Cookie(oidcFlowCookie)
+	setOIDCFlowCookie(c, "", -1)
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		c.JSON(400, StandardAPIResponse{
+			Err:     providerErr,
+			Message: c.Query("error_description"),
+		})
+		return
+	}
+
+	result, err := userAuthUsecase.FinishOIDC(c.Param("provider"), c.Query("code"), c.Query("state"), flowToken, clientInfo(c))
+	if err != nil {
+		c.JSON(oidcErrStatus(err), StandardAPIResponse{
+			Err:     err.Error(),
+			Message: "Failed",
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: result,
+	})
+}
+
+func setOIDCFlowCookie(c *gin.Context, value string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookie, value, maxAge, "/oidc", "", secure, true)
+}
+
+func getIdentities(c *gin.Context) {
+	identities, err := userAuthUsecase.GetIdentities(c.GetInt64("uid"))
+	if err != nil {
+		c.JSON(500, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: identities,
+	})
+}
+
+func unlinkIdentity(c *gin.Context) {
+	err := userAuthUsecase.UnlinkIdentity(c.GetInt64("uid"), c.Param("provider"), clientInfo(c))
+	if err != nil {
+		c.JSON(oidcErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "Success unlink identity",
+	})
+}
+
+func oidcErrStatus(err error) int {
+	switch err {
+	case userauth.ErrUnknownProvider, userauth.ErrIdentityNotFound:
+		return http.StatusNotFound
+	case userauth.ErrInvalidOIDCFlow, userauth.ErrOIDCExchange:
+		return 401
+	case userauth.ErrOIDCSignupDisabled, userauth.ErrAccountDeactivated, userauth.ErrAccountSuspended, userauth.ErrPasswordResetRequired:
+		return http.StatusForbidden
+	case userauth.ErrOIDCEmailTaken, userauth.ErrIdentityLinked, userauth.ErrProviderLinked, userauth.ErrLastLoginMethod:
+		return http.StatusConflict
+	case userauth.ErrInvalidCredentials:
+		return 400
+	}
+	return 500
+}
+
+type accessTokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// ExpiresInDays 0 creates a token that does not expire.
+	ExpiresInDays int `json:"expires_in_days"`
+}
+
+// tokenSubject is the account whose tokens a request manages, the caller or
+// the bot in the path.
+func tokenSubject(c *gin.Context) (int64, bool) {
+	if c.Param("user_id") == "" {
+		return c.GetInt64("uid"), true
+	}
+	botID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	return botID, err == nil
+}
+
+func getAccessTokens(c *gin.Context) {
+	userID, ok := tokenSubject(c)
+	if !ok {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid user_id",
+		})
+		return
+	}
+
+	tokens, err := userAuthUsecase.GetAccessTokens(c.GetInt64("uid"), userID)
+	if err != nil {
+		c.JSON(accessTokenErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: tokens,
+	})
+}
+
+func createAccessToken(c *gin.Context) {
+	userID, ok := tokenSubject(c)
+	if !ok {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid user_id",
+		})
+		return
+	}
+
+	var req accessTokenRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid request body",
+		})
+		return
+	}
+
+	expiresIn := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	token, err := userAuthUsecase.CreateAccessToken(c.GetInt64("uid"), userID, req.Name, req.Scopes, expiresIn, clientInfo(c))
+	if err != nil {
+		c.JSON(accessTokenErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, StandardAPIResponse{
+		Err:     "null",
+		Message: "Store the token now, it is not shown again",
+		Data:    token,
+	})
+}
+
+func revokeAccessToken(c *gin.Context) {
+	userID, ok := tokenSubject(c)
+	if !ok {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid user_id",
+		})
+		return
+	}
+
+	err := userAuthUsecase.RevokeAccessToken(c.GetInt64("uid"), userID, c.Param("token_id"), clientInfo(c))
+	if err != nil {
+		c.JSON(accessTokenErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "Success revoke token",
+	})
+}
+
+func getBots(c *gin.Context) {
+	bots, err := userAuthUsecase.GetBots(c.GetInt64("uid"))
+	if err != nil {
+		c.JSON(500, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: bots,
+	})
+}
+
+func createBot(c *gin.Context) {
+	username := c.Request.FormValue("username")
+	displayName := c.Request.FormValue("display_name")
+
+	bot, err := userAuthUsecase.CreateBot(c.GetInt64("uid"), username, displayName, clientInfo(c))
+	if err != nil {
+		status := accessTokenErrStatus(err)
+		if status == 500 {
+			status = usernameErrStatus(err)
+		}
+		c.JSON(status, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, StandardAPIResponse{
+		Err:  "null",
+		Data: bot,
+	})
+}
+
+func deleteBot(c *gin.Context) {
+	botID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid user_id",
+		})
+		return
+	}
+
+	err = userAuthUsecase.DeleteBot(c.GetInt64("uid"), botID, clientInfo(c))
+	if err != nil {
+		c.JSON(accessTokenErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "Success delete bot",
+	})
+}
+
+func accessTokenErrStatus(err error) int {
+	switch err {
+	case userauth.ErrInvalidTokenName, userauth.ErrInvalidScope, userauth.ErrInvalidTokenExpiry, userauth.ErrInvalidBotDisplayName:
+		return 400
+	case userauth.ErrTooManyTokens, userauth.ErrTooManyBots:
+		return http.StatusConflict
+	case userauth.ErrTokenNotFound, userauth.ErrBotNotFound:
+		return http.StatusNotFound
+	}
+	return 500
+}
+
+// getContacts lists the contacts of the caller with their presence in the
+// chat, online is left out when it cannot be loaded.
+func getContacts(c *gin.Context) {
+	contacts, err := contactUsecase.GetContacts(c.GetInt64("uid"))
+	if err != nil {
+		c.JSON(500, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: contacts,
+	})
+}
+
+func removeContact(c *gin.Context) {
+	contactUserID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || contactUserID < 1 {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid user id",
+		})
+		return
+	}
+
+	err = contactUsecase.RemoveContact(c.GetInt64("uid"), contactUserID)
+	if err != nil {
+		c.JSON(contactErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "Contact removed",
+	})
+}
+
+func getMutualContacts(c *gin.Context) {
+	otherUserID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || otherUserID < 1 {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid user id",
+		})
+		return
+	}
+
+	users, err := contactUsecase.GetMutualContacts(c.GetInt64("uid"), otherUserID)
+	if err != nil {
+		c.JSON(contactErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: users,
+	})
+}
+
+// getContactRequests lists the pending requests sent to the caller, or sent
+// by it with direction=outgoing.
+func getContactRequests(c *gin.Context) {
+	direction := c.DefaultQuery("direction", "incoming")
+	if direction != "incoming" && direction != "outgoing" {
+		c.JSON(400, StandardAPIResponse{
+			Err: "direction must be incoming or outgoing",
+		})
+		return
+	}
+
+	requests, err := contactUsecase.GetRequests(c.GetInt64("uid"), direction == "outgoing")
+	if err != nil {
+		c.JSON(500, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: requests,
+	})
+}
+
+// sendContactRequest asks user_id to become a contact. When user_id already
+// asked the caller, the two become contacts right away.
+func sendContactRequest(c *gin.Context) {
+	otherUserID, err := strconv.ParseInt(c.Request.FormValue("user_id"), 10, 64)
+	if err != nil || otherUserID < 1 {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid user id",
+		})
+		return
+	}
+
+	accepted, err := contactUsecase.SendRequest(c.GetInt64("uid"), otherUserID)
+	if err != nil {
+		c.JSON(contactErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	if accepted {
+		c.JSON(200, StandardAPIResponse{
+			Err:     "null",
+			Message: "Contact added",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, StandardAPIResponse{
+		Err:     "null",
+		Message: "Contact request sent",
+	})
+}
+
+func acceptContactRequest(c *gin.Context) {
+	requesterID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || requesterID < 1 {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid user id",
+		})
+		return
+	}
+
+	err = contactUsecase.AcceptRequest(c.GetInt64("uid"), requesterID)
+	if err != nil {
+		c.JSON(contactErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "Contact added",
+	})
+}
+
+func declineContactRequest(c *gin.Context) {
+	requesterID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || requesterID < 1 {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid user id",
+		})
+		return
+	}
+
+	err = contactUsecase.DeclineRequest(c.GetInt64("uid"), requesterID)
+	if err != nil {
+		c.JSON(contactErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "Contact request declined",
+	})
+}
+
+func cancelContactRequest(c *gin.Context) {
+	addresseeID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || addresseeID < 1 {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid user id",
+		})
+		return
+	}
+
+	err = contactUsecase.CancelRequest(c.GetInt64("uid"), addresseeID)
+	if err != nil {
+		c.JSON(contactErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:     "null",
+		Message: "Contact request cancelled",
+	})
+}
+
+func contactErrStatus(err error) int {
+	switch err {
+	case contact.ErrContactSelf:
+		return 400
+	case contact.ErrContactUnavailable:
+		return http.StatusForbidden
+	case contact.ErrAlreadyContact, contact.ErrTooManyContacts, contact.ErrTooManyContactRequests:
+		return http.StatusConflict
+	case contact.ErrUserNotFound, contact.ErrContactRequestNotFound, contact.ErrContactNotFound:
+		return http.StatusNotFound
+	}
+	return 500
+}
+
+// userInfo is the /user/info response, Scopes tells services which scopes a
+// personal access token carries.
+type userInfo struct {
+	model.User
+	Scopes []string `json:"scopes,omitempty"`
 }
 
 type StandardAPIResponse struct {
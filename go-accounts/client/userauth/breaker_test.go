@@ -0,0 +1,60 @@
+package userauth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	// steps: "allow" and "deny" check allow, "ok" and "fail" record a call,
+	// "release" ends a probe and "cool" lets the cooldown pass
+	tests := []struct {
+		name  string
+		steps string
+	}{
+		{"closed", "allow allow allow"},
+		{"opens at threshold", "fail fail allow fail deny deny"},
+		{"success resets failures", "fail fail ok fail fail allow fail deny"},
+		{"stays open during cooldown", "fail fail fail deny"},
+		{"one probe after cooldown", "fail fail fail cool allow deny"},
+		{"probe success closes", "fail fail fail cool allow ok allow allow fail allow"},
+		{"probe failure reopens", "fail fail fail cool allow fail deny cool allow"},
+		{"released probe", "fail fail fail cool allow release allow deny"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBreaker(3, time.Minute)
+			for i, step := range strings.Fields(tt.steps) {
+				switch step {
+				case "allow", "deny":
+					if got := b.allow(); got != (step == "allow") {
+						t.Fatalf("step %d: allow = %v, want %v", i, got, step == "allow")
+					}
+				case "ok", "fail":
+					b.record(step == "ok")
+				case "release":
+					b.release()
+				case "cool":
+					b.openedAt = b.openedAt.Add(-time.Minute)
+				default:
+					t.Fatalf("unknown step %q", step)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b := newBreaker(-1, time.Minute)
+	if b != nil {
+		t.Fatal("negative threshold returned a breaker")
+	}
+	for i := 0; i < 10; i++ {
+		b.record(false)
+	}
+	b.release()
+	if !b.allow() {
+		t.Error("disabled breaker refused a call")
+	}
+}
@@ -1,96 +1,201 @@
 package userauth
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"log"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 )
 
-func (u *AuthClient) GetUserInfo(accessToken string) *User {
-	client := &http.Client{
-		Timeout: u.timeout,
+// maxResponseBytes bounds the responses read from accounts.
+const maxResponseBytes = 4 << 20
+
+func (u *AuthClient) GetUserInfo(ctx context.Context, accessToken string) (*User, error) {
+	var user User
+	err := u.do(ctx, http.MethodGet, "/user/info", accessToken, nil, &user)
+	if err != nil {
+		return nil, err
 	}
+	return &user, nil
+}
 
-	req, err := http.NewRequest("GET", u.host+"/user/info", nil)
+func (u *AuthClient) GetUserByID(ctx context.Context, userID int64) (*User, error) {
+	var user User
+	err := u.do(ctx, http.MethodGet, fmt.Sprintf("/usr/%d", userID), "", nil, &user)
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	req.Header.Set("X-Access-Token", accessToken)
+	return &user, nil
+}
 
-	respRaw, err := client.Do(req)
+// GetUsersByIDs resolves many users in one request. Unknown users are left
+// out of the result.
+func (u *AuthClient) GetUsersByIDs(ctx context.Context, userIDs []int64) ([]User, error) {
+	reqBody, err := json.Marshal(map[string][]int64{
+		"user_ids": userIDs,
+	})
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	defer respRaw.Body.Close()
 
+	var users []User
+	err = u.do(ctx, http.MethodPost, "/users/batch", "", reqBody, &users)
 	if err != nil {
-		return nil
+		return nil, err
 	}
+	return users, nil
+}
 
-	if respRaw.StatusCode != 200 {
-		return nil
+// SearchUsers runs a directory search on behalf of the owner of
+// accessToken. cursor is the NextCursor of the previous page, empty for the
+// first one.
+func (u *AuthClient) SearchUsers(ctx context.Context, accessToken, query, cursor string, limit int) (*UserPage, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
 	}
 
-	respByte, err := ioutil.ReadAll(respRaw.Body)
+	var page UserPage
+	err := u.do(ctx, http.MethodGet, "/users/search?"+params.Encode(), accessToken, nil, &page)
 	if err != nil {
-		log.Print(err)
-		return nil
+		return nil, err
 	}
+	return &page, nil
+}
 
-	var resp struct {
-		Err  string `json:"err"`
-		Data User   `json:"data"`
+// GetBlockedUsers returns the users blocked by the owner of accessToken.
+func (u *AuthClient) GetBlockedUsers(ctx context.Context, accessToken string) ([]BlockedUser, error) {
+	var blocked []BlockedUser
+	err := u.do(ctx, http.MethodGet, "/blocks", accessToken, nil, &blocked)
+	if err != nil {
+		return nil, err
 	}
+	return blocked, nil
+}
 
-	err = json.Unmarshal(respByte, &resp)
+// IsBlocked reports whether the owner of accessToken and userID blocked each
+// other in either direction. Callers should refuse contact when it fails.
+func (u *AuthClient) IsBlocked(ctx context.Context, accessToken string, userID int64) (bool, error) {
+	var status struct {
+		Blocked bool `json:"blocked"`
+	}
+	err := u.do(ctx, http.MethodGet, fmt.Sprintf("/blocks/%d", userID), accessToken, nil, &status)
 	if err != nil {
-		return nil
+		return false, err
 	}
+	return status.Blocked, nil
+}
+
+// do sends a request and decodes the data of the response into out. Every
+// call made through the client is idempotent, so it is retried.
+func (u *AuthClient) do(ctx context.Context, method, path, accessToken string, body []byte, out interface{}) error {
+	return retry(ctx, u.maxRetries, u.retryBackoff, u.breaker, func() error {
+		return u.doOnce(ctx, method, path, accessToken, body, out)
+	})
+}
 
-	return &resp.Data
+// retry runs call through the circuit breaker and retries it with
+// exponential backoff as long as it fails with ErrUnavailable.
+func retry(ctx context.Context, maxRetries int, retryBackoff time.Duration, b *breaker, call func() error) error {
+	var err error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			err = sleepContext(ctx, backoff(retryBackoff, attempt))
+			if err != nil {
+				return err
+			}
+		}
+
+		if !b.allow() {
+			return fmt.Errorf("%w: circuit open", ErrUnavailable)
+		}
+
+		err = call()
+		if ctx.Err() != nil {
+			b.release()
+			return ctx.Err()
+		}
+		b.record(!errors.Is(err, ErrUnavailable))
+		if !errors.Is(err, ErrUnavailable) {
+			return err
+		}
+	}
+	return err
 }
 
-func (u *AuthClient) GetUserByID(userID int64) *User {
-	client := &http.Client{
-		Timeout: u.timeout,
+func (u *AuthClient) doOnce(ctx context.Context, method, path, accessToken string, body []byte, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
 	}
 
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/usr/%d", u.host, userID), nil)
+	req, err := http.NewRequestWithContext(ctx, method, u.host+path, reqBody)
 	if err != nil {
-		return nil
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if accessToken != "" {
+		req.Header.Set("X-Access-Token", accessToken)
 	}
 
-	respRaw, err := client.Do(req)
+	respRaw, err := u.httpClient.Do(req)
 	if err != nil {
-		return nil
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
 	}
 	defer respRaw.Body.Close()
 
+	respByte, err := ioutil.ReadAll(io.LimitReader(respRaw.Body, maxResponseBytes))
 	if err != nil {
-		return nil
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
 	}
 
-	if respRaw.StatusCode != 200 {
-		return nil
+	resp := struct {
+		Err  string      `json:"err"`
+		Data interface{} `json:"data"`
+	}{
+		Data: out,
 	}
+	decodeErr := json.Unmarshal(respByte, &resp)
 
-	respByte, err := ioutil.ReadAll(respRaw.Body)
+	err = statusErr(respRaw.StatusCode, resp.Err)
 	if err != nil {
-		log.Print(err)
-		return nil
+		return err
 	}
-
-	var resp struct {
-		Err  string `json:"err"`
-		Data User   `json:"data"`
+	if decodeErr != nil {
+		return fmt.Errorf("accounts: decode %s: %v", path, decodeErr)
 	}
+	return nil
+}
 
-	err = json.Unmarshal(respByte, &resp)
-	if err != nil {
+// backoff doubles the wait for every attempt and adds up to 50% jitter so
+// clients do not retry in lockstep.
+func backoff(base time.Duration, attempt int) time.Duration {
+	wait := base << uint(attempt-1)
+	return wait + time.Duration(rand.Int63n(int64(wait)/2+1))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
 		return nil
 	}
-
-	return &resp.Data
 }
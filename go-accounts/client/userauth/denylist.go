@@ -0,0 +1,59 @@
+package userauth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type SessionStatus int
+
+const (
+	// SessionUnknown means the denylist has no entry for the session, only
+	// go-accounts can tell whether it is still active.
+	SessionUnknown SessionStatus = iota
+	SessionActive
+	SessionRevoked
+)
+
+// SessionDenylistKey is the redis key go-accounts keeps the state of a
+// session under: "1" once it is revoked, "0" while it is known to be active.
+func SessionDenylistKey(sessionID string) string {
+	return fmt.Sprintf("session_denylist:%s", sessionID)
+}
+
+type DenylistItf interface {
+	SessionStatus(ctx context.Context, sessionID string) (SessionStatus, error)
+}
+
+// RedisDenylist reads the session denylist go-accounts writes, it has to
+// share its redis.
+type RedisDenylist struct {
+	rdb *redis.Client
+}
+
+func NewRedisDenylist(rdb *redis.Client) DenylistItf {
+	return &RedisDenylist{
+		rdb: rdb,
+	}
+}
+
+func (d *RedisDenylist) SessionStatus(ctx context.Context, sessionID string) (SessionStatus, error) {
+	if sessionID == "" {
+		return SessionUnknown, nil
+	}
+
+	val, err := d.rdb.Get(ctx, SessionDenylistKey(sessionID)).Result()
+	if err == redis.Nil {
+		return SessionUnknown, nil
+	}
+	if err != nil {
+		return SessionUnknown, err
+	}
+
+	if val == "1" {
+		return SessionRevoked, nil
+	}
+	return SessionActive, nil
+}
@@ -0,0 +1,32 @@
+package userauth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrUnauthorized means the access token was rejected.
+	ErrUnauthorized = errors.New("accounts: unauthorized")
+	ErrNotFound     = errors.New("accounts: not found")
+	// ErrUnavailable means accounts could not answer: it is unreachable,
+	// failing, overloaded or the circuit breaker is open. Callers decide
+	// whether to fail open or closed.
+	ErrUnavailable = errors.New("accounts: unavailable")
+)
+
+// statusErr maps a response status to the client errors, nil for success.
+func statusErr(status int, message string) error {
+	switch {
+	case status >= 200 && status < 300:
+		return nil
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return fmt.Errorf("%w: %s", ErrUnauthorized, message)
+	case status == http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrNotFound, message)
+	case status == http.StatusTooManyRequests || status >= 500:
+		return fmt.Errorf("%w: status %d: %s", ErrUnavailable, status, message)
+	}
+	return fmt.Errorf("accounts: status %d: %s", status, message)
+}
@@ -0,0 +1,61 @@
+package userauth
+
+// AccountDeletedChannel is the Redis pubsub channel go-accounts publishes an
+// AccountDeletedEvent on once an account has been anonymized. Services that
+// keep data about users subscribe to it and scrub their own stores.
+const AccountDeletedChannel = "accounts:deleted"
+
+type AccountDeletedEvent struct {
+	UserID int64 `json:"user_id"`
+}
+
+// AccountPurgedChannel carries an AccountPurgedEvent once a service has
+// scrubbed a deleted account. go-accounts publishes the AccountDeletedEvent
+// again until every service in PurgeServices confirmed, so handlers of
+// AccountDeletedChannel must be idempotent.
+const AccountPurgedChannel = "accounts:purged"
+
+const (
+	ServiceGroupchat = "groupchat"
+	ServiceWebsocket = "websocket"
+)
+
+// PurgeServices lists the services that keep data about users and must
+// confirm every account deletion.
+var PurgeServices = []string{ServiceGroupchat, ServiceWebsocket}
+
+type AccountPurgedEvent struct {
+	UserID  int64  `json:"user_id"`
+	Service string `json:"service"`
+}
+
+// BlockChangedChannel carries a BlockChangedEvent whenever a user blocks or
+// unblocks someone, so services holding block lists in memory stay current.
+const BlockChangedChannel = "accounts:blocks"
+
+type BlockChangedEvent struct {
+	UserID        int64 `json:"user_id"`
+	BlockedUserID int64 `json:"blocked_user_id"`
+	Blocked       bool  `json:"blocked"`
+}
+
+// ContactChangedChannel carries a ContactChangedEvent whenever a contact
+// request is sent, accepted, declined or cancelled and when a contact is
+// removed. The websocket service forwards it to both users.
+const ContactChangedChannel = "accounts:contacts"
+
+const (
+	ContactRequested = "requested"
+	ContactAccepted  = "accepted"
+	ContactDeclined  = "declined"
+	ContactCancelled = "cancelled"
+	ContactRemoved   = "removed"
+)
+
+// ContactChangedEvent tells that UserID changed its relation with
+// ContactUserID, Type is one of the Contact* constants.
+type ContactChangedEvent struct {
+	Type          string `json:"type"`
+	UserID        int64  `json:"user_id"`
+	ContactUserID int64  `json:"contact_user_id"`
+}
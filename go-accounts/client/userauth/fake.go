@@ -0,0 +1,190 @@
+package userauth
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeClient is an in-memory ClientItf for tests of the services using
+// accounts. It answers like the real client, including its errors:
+//
+//	fake := userauth.NewFakeClient()
+//	fake.AddUser(userauth.User{UserID: 1, Username: "alice"}, "alice-token")
+//	fake.SetErr(userauth.ErrUnavailable) // simulate an outage
+type FakeClient struct {
+	mu      sync.RWMutex
+	users   map[int64]User
+	tokens  map[string]int64
+	blocked map[int64]map[int64]time.Time
+	err     error
+}
+
+func NewFakeClient() *FakeClient {
+	return &FakeClient{
+		users:   make(map[int64]User),
+		tokens:  make(map[string]int64),
+		blocked: make(map[int64]map[int64]time.Time),
+	}
+}
+
+// AddUser stores user, accessTokens sign in as it.
+func (f *FakeClient) AddUser(user User, accessTokens ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.users[user.UserID] = user
+	for _, token := range accessTokens {
+		f.tokens[token] = user.UserID
+	}
+}
+
+// Block makes userID block blockedUserID.
+func (f *FakeClient) Block(userID, blockedUserID int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.blocked[userID] == nil {
+		f.blocked[userID] = make(map[int64]time.Time)
+	}
+	f.blocked[userID][blockedUserID] = time.Now()
+}
+
+func (f *FakeClient) Unblock(userID, blockedUserID int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.blocked[userID], blockedUserID)
+}
+
+// SetErr makes every call fail with err until it is reset with nil.
+func (f *FakeClient) SetErr(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func (f *FakeClient) GetUserInfo(ctx context.Context, accessToken string) (*User, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	userID, err := f.authenticate(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	user := f.users[userID]
+	return &user, nil
+}
+
+func (f *FakeClient) GetUserByID(ctx context.Context, userID int64) (*User, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	err := f.check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, ok := f.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (f *FakeClient) GetUsersByIDs(ctx context.Context, userIDs []int64) ([]User, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	err := f.check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var users []User
+	for _, userID := range userIDs {
+		if user, ok := f.users[userID]; ok {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+// SearchUsers matches query as a case insensitive prefix of usernames and
+// display names, in user id order. It does not paginate.
+func (f *FakeClient) SearchUsers(ctx context.Context, accessToken, query, cursor string, limit int) (*UserPage, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	viewerID, err := f.authenticate(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	page := &UserPage{}
+	for _, user := range f.users {
+		if f.isBlocked(viewerID, user.UserID) {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(user.Username), query) || strings.HasPrefix(strings.ToLower(user.DisplayName), query) {
+			page.Users = append(page.Users, user)
+		}
+	}
+	sort.Slice(page.Users, func(i, j int) bool {
+		return page.Users[i].UserID < page.Users[j].UserID
+	})
+	if limit > 0 && len(page.Users) > limit {
+		page.Users = page.Users[:limit]
+	}
+	return page, nil
+}
+
+func (f *FakeClient) GetBlockedUsers(ctx context.Context, accessToken string) ([]BlockedUser, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	userID, err := f.authenticate(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocked []BlockedUser
+	for blockedUserID, blockedAt := range f.blocked[userID] {
+		blocked = append(blocked, BlockedUser{
+			User:      f.users[blockedUserID],
+			BlockedAt: blockedAt,
+		})
+	}
+	sort.Slice(blocked, func(i, j int) bool {
+		return blocked[i].BlockedAt.After(blocked[j].BlockedAt)
+	})
+	return blocked, nil
+}
+
+func (f *FakeClient) IsBlocked(ctx context.Context, accessToken string, userID int64) (bool, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	viewerID, err := f.authenticate(ctx, accessToken)
+	if err != nil {
+		return false, err
+	}
+	return f.isBlocked(viewerID, userID), nil
+}
+
+func (f *FakeClient) check(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return f.err
+}
+
+func (f *FakeClient) authenticate(ctx context.Context, accessToken string) (int64, error) {
+	err := f.check(ctx)
+	if err != nil {
+		return 0, err
+	}
+	userID, ok := f.tokens[accessToken]
+	if !ok {
+		return 0, ErrUnauthorized
+	}
+	return userID, nil
+}
+
+func (f *FakeClient) isBlocked(userID, otherUserID int64) bool {
+	_, blocked := f.blocked[userID][otherUserID]
+	_, blockedBy := f.blocked[otherUserID][userID]
+	return blocked || blockedBy
+}
@@ -0,0 +1,154 @@
+package userauth
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestFake() *FakeClient {
+	fake := NewFakeClient()
+	fake.AddUser(User{UserID: 1, Username: "alice", DisplayName: "Alice"}, "alice-token")
+	fake.AddUser(User{UserID: 2, Username: "bob", DisplayName: "Bobby"}, "bob-token", "bob-pat")
+	fake.AddUser(User{UserID: 3, Username: "bobcat", DisplayName: "Cat"})
+	fake.AddUser(User{UserID: 4, Username: "carol", DisplayName: "Bo"})
+	return fake
+}
+
+func TestFakeClientErrors(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// GetUserInfo and IsBlocked fail on the token, GetUserByID on the user
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		setErr       error
+		token        string
+		userID       int64
+		wantTokenErr error
+		wantUserErr  error
+	}{
+		{"valid", context.Background(), nil, "alice-token", 2, nil, nil},
+		{"unknown token", context.Background(), nil, "nope", 2, ErrUnauthorized, nil},
+		{"unknown user", context.Background(), nil, "alice-token", 99, nil, ErrNotFound},
+		{"outage", context.Background(), ErrUnavailable, "alice-token", 2, ErrUnavailable, ErrUnavailable},
+		{"cancelled", cancelled, nil, "alice-token", 2, context.Canceled, context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newTestFake()
+			fake.SetErr(tt.setErr)
+
+			_, err := fake.GetUserInfo(tt.ctx, tt.token)
+			if !errors.Is(err, tt.wantTokenErr) {
+				t.Errorf("GetUserInfo err = %v, want %v", err, tt.wantTokenErr)
+			}
+			_, err = fake.IsBlocked(tt.ctx, tt.token, tt.userID)
+			if !errors.Is(err, tt.wantTokenErr) {
+				t.Errorf("IsBlocked err = %v, want %v", err, tt.wantTokenErr)
+			}
+			_, err = fake.GetUserByID(tt.ctx, tt.userID)
+			if !errors.Is(err, tt.wantUserErr) {
+				t.Errorf("GetUserByID err = %v, want %v", err, tt.wantUserErr)
+			}
+		})
+	}
+}
+
+func TestFakeClientUsers(t *testing.T) {
+	fake := newTestFake()
+
+	user, err := fake.GetUserInfo(context.Background(), "bob-pat")
+	if err != nil || user.UserID != 2 {
+		t.Fatalf("GetUserInfo = %v, %v; want bob", user, err)
+	}
+
+	users, err := fake.GetUsersByIDs(context.Background(), []int64{4, 99, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].UserID != 4 || users[1].UserID != 1 {
+		t.Errorf("GetUsersByIDs = %v, want carol and alice", users)
+	}
+}
+
+func TestFakeClientSearchUsers(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		query   string
+		limit   int
+		blocked [2]int64
+		want    []int64
+	}{
+		{"username and display name prefix", "alice-token", "bo", 0, [2]int64{}, []int64{2, 3, 4}},
+		{"case insensitive", "alice-token", "BOB", 0, [2]int64{}, []int64{2, 3}},
+		{"limit", "alice-token", "bo", 2, [2]int64{}, []int64{2, 3}},
+		{"no match", "alice-token", "zed", 0, [2]int64{}, nil},
+		{"hides users the viewer blocked", "alice-token", "bo", 0, [2]int64{1, 3}, []int64{2, 4}},
+		{"hides users who blocked the viewer", "alice-token", "bo", 0, [2]int64{2, 1}, []int64{3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newTestFake()
+			if tt.blocked[0] != 0 {
+				fake.Block(tt.blocked[0], tt.blocked[1])
+			}
+
+			page, err := fake.SearchUsers(context.Background(), tt.token, tt.query, "", tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int64
+			for _, user := range page.Users {
+				got = append(got, user.UserID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SearchUsers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFakeClientBlocks(t *testing.T) {
+	fake := newTestFake()
+	fake.Block(1, 2)
+	fake.Block(1, 3)
+	// newest first, set explicitly as both blocks may share a timestamp
+	now := time.Now()
+	fake.blocked[1][2] = now.Add(-time.Hour)
+	fake.blocked[1][3] = now
+
+	blocked, err := fake.GetBlockedUsers(context.Background(), "alice-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocked) != 2 || blocked[0].User.UserID != 3 || blocked[1].User.UserID != 2 {
+		t.Errorf("GetBlockedUsers = %v, want bobcat then bob", blocked)
+	}
+
+	tests := []struct {
+		token  string
+		userID int64
+		want   bool
+	}{
+		{"alice-token", 2, true},
+		{"bob-token", 1, true},
+		{"bob-token", 3, false},
+		{"alice-token", 4, false},
+	}
+	for _, tt := range tests {
+		got, err := fake.IsBlocked(context.Background(), tt.token, tt.userID)
+		if err != nil || got != tt.want {
+			t.Errorf("IsBlocked(%s, %d) = %v, %v; want %v", tt.token, tt.userID, got, err, tt.want)
+		}
+	}
+
+	fake.Unblock(1, 2)
+	if got, _ := fake.IsBlocked(context.Background(), "bob-token", 1); got {
+		t.Error("still blocked after Unblock")
+	}
+}
@@ -0,0 +1,225 @@
+package userauth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lolmourne/go-accounts/api/accountspb"
+	"github.com/lolmourne/go-accounts/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// AccessTokenMetadata carries the access token of gRPC calls made on behalf
+// of a user.
+const AccessTokenMetadata = "x-access-token"
+
+// GRPCClient is ClientItf over the Accounts gRPC service. It retries and
+// trips its circuit breaker like the HTTP client.
+type GRPCClient struct {
+	conn         *grpc.ClientConn
+	accounts     accountspb.AccountsClient
+	timeout      time.Duration
+	maxRetries   int
+	retryBackoff time.Duration
+	breaker      *breaker
+}
+
+// NewGRPCClient dials cfg.GRPCAddress, localhost:7272 by default. The
+// connection is established in the background, calls fail with
+// ErrUnavailable until it is up.
+func NewGRPCClient(cfg ClientConfig) (*GRPCClient, error) {
+	cfg = cfg.withDefaults()
+	if cfg.GRPCAddress == "" {
+		cfg.GRPCAddress = "localhost:7272"
+	}
+
+	conn, err := grpc.Dial(cfg.GRPCAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &GRPCClient{
+		conn:         conn,
+		accounts:     accountspb.NewAccountsClient(conn),
+		timeout:      cfg.Timeout,
+		maxRetries:   cfg.MaxRetries,
+		retryBackoff: cfg.RetryBackoff,
+		breaker:      newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+	}, nil
+}
+
+func (g *GRPCClient) Close() error {
+	return g.conn.Close()
+}
+
+func (g *GRPCClient) GetUserInfo(ctx context.Context, accessToken string) (*User, error) {
+	var resp *accountspb.User
+	err := g.call(ctx, "", func(ctx context.Context) (err error) {
+		resp, err = g.accounts.ValidateToken(ctx, &accountspb.ValidateTokenRequest{AccessToken: accessToken})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	user := userFromProto(resp)
+	return &user, nil
+}
+
+func (g *GRPCClient) GetUserByID(ctx context.Context, userID int64) (*User, error) {
+	var resp *accountspb.User
+	err := g.call(ctx, "", func(ctx context.Context) (err error) {
+		resp, err = g.accounts.GetUser(ctx, &accountspb.GetUserRequest{UserId: userID})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	user := userFromProto(resp)
+	return &user, nil
+}
+
+func (g *GRPCClient) GetUsersByIDs(ctx context.Context, userIDs []int64) ([]User, error) {
+	var resp *accountspb.GetUsersResponse
+	err := g.call(ctx, "", func(ctx context.Context) (err error) {
+		resp, err = g.accounts.GetUsers(ctx, &accountspb.GetUsersRequest{UserIds: userIDs})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return usersFromProto(resp.GetUsers()), nil
+}
+
+func (g *GRPCClient) SearchUsers(ctx context.Context, accessToken, query, cursor string, limit int) (*UserPage, error) {
+	var resp *accountspb.SearchUsersResponse
+	err := g.call(ctx, accessToken, func(ctx context.Context) (err error) {
+		resp, err = g.accounts.SearchUsers(ctx, &accountspb.SearchUsersRequest{
+			Query:  query,
+			Cursor: cursor,
+			Limit:  int32(limit),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &UserPage{
+		Users:      usersFromProto(resp.GetUsers()),
+		NextCursor: resp.GetNextCursor(),
+	}, nil
+}
+
+func (g *GRPCClient) GetBlockedUsers(ctx context.Context, accessToken string) ([]BlockedUser, error) {
+	var resp *accountspb.GetBlockedUsersResponse
+	err := g.call(ctx, accessToken, func(ctx context.Context) (err error) {
+		resp, err = g.accounts.GetBlockedUsers(ctx, &accountspb.GetBlockedUsersRequest{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	blocked := make([]BlockedUser, 0, len(resp.GetBlockedUsers()))
+	for _, b := range resp.GetBlockedUsers() {
+		blocked = append(blocked, BlockedUser{
+			User:      userFromProto(b.GetUser()),
+			BlockedAt: timeFromProto(b.GetBlockedAt()),
+		})
+	}
+	return blocked, nil
+}
+
+func (g *GRPCClient) IsBlocked(ctx context.Context, accessToken string, userID int64) (bool, error) {
+	var resp *accountspb.IsBlockedResponse
+	err := g.call(ctx, accessToken, func(ctx context.Context) (err error) {
+		resp, err = g.accounts.IsBlocked(ctx, &accountspb.IsBlockedRequest{UserId: userID})
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return resp.GetBlocked(), nil
+}
+
+// call runs rpc with the access token in the metadata, bounding every
+// attempt by the client timeout.
+func (g *GRPCClient) call(ctx context.Context, accessToken string, rpc func(ctx context.Context) error) error {
+	if accessToken != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, AccessTokenMetadata, accessToken)
+	}
+
+	return retry(ctx, g.maxRetries, g.retryBackoff, g.breaker, func() error {
+		attemptCtx, cancel := context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+		return grpcErr(rpc(attemptCtx))
+	})
+}
+
+// grpcErr maps a status to the client errors like statusErr does for HTTP.
+func grpcErr(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	switch st.Code() {
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return fmt.Errorf("%w: %s", ErrUnauthorized, st.Message())
+	case codes.NotFound:
+		return fmt.Errorf("%w: %s", ErrNotFound, st.Message())
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Internal, codes.Unknown:
+		return fmt.Errorf("%w: %s: %s", ErrUnavailable, st.Code(), st.Message())
+	}
+	return fmt.Errorf("accounts: %s: %s", st.Code(), st.Message())
+}
+
+func userFromProto(u *accountspb.User) User {
+	user := User{
+		UserID:             u.GetUserId(),
+		Username:           u.GetUsername(),
+		CreatedAt:          timeFromProto(u.GetCreatedAt()),
+		ProfilePic:         u.GetProfilePic(),
+		ProfilePicVariants: u.GetProfilePicVariants(),
+		DisplayName:        u.GetDisplayName(),
+		Bio:                u.GetBio(),
+		Timezone:           u.GetTimezone(),
+		Role:               u.GetRole(),
+		Bot:                u.GetBot(),
+		Scopes:             u.GetScopes(),
+	}
+	if s := u.GetStatus(); s != nil {
+		user.Status = &model.UserStatus{
+			Text:  s.GetText(),
+			Emoji: s.GetEmoji(),
+		}
+		if s.GetExpiresAt() != nil {
+			expiresAt := timeFromProto(s.GetExpiresAt())
+			user.Status.ExpiresAt = &expiresAt
+		}
+	}
+	return user
+}
+
+func usersFromProto(users []*accountspb.User) []User {
+	result := make([]User, 0, len(users))
+	for _, u := range users {
+		result = append(result, userFromProto(u))
+	}
+	return result
+}
+
+func timeFromProto(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
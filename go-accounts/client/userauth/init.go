@@ -1,12 +1,51 @@
 package userauth
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
 	"time"
+
+	"github.com/lolmourne/go-accounts/model"
 )
 
+// PersonalAccessTokenPrefix starts every personal access token, it tells
+// them apart from JWT access tokens.
+const PersonalAccessTokenPrefix = "gcp_"
+
 type AuthClient struct {
-	host    string
-	timeout time.Duration
+	host         string
+	httpClient   *http.Client
+	maxRetries   int
+	retryBackoff time.Duration
+	breaker      *breaker
+}
+
+const (
+	TransportHTTP = "http"
+	TransportGRPC = "grpc"
+)
+
+// ClientConfig tunes the client. Zero values take the defaults of
+// NewClient.
+type ClientConfig struct {
+	// Transport is TransportHTTP, the default, or TransportGRPC. The HTTP
+	// client talks to Host, the gRPC client dials GRPCAddress.
+	Transport   string
+	Host        string
+	GRPCAddress string
+	// Timeout bounds every attempt, the context bounds the whole call.
+	Timeout time.Duration
+	// MaxRetries is how often idempotent calls are retried when accounts is
+	// unavailable, -1 disables retries.
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// The circuit opens after BreakerThreshold consecutive failures and
+	// lets a probe request through after BreakerCooldown. -1 disables it.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
 }
 
 type User struct {
@@ -14,16 +53,124 @@ type User struct {
 	Username   string    `json:"username"`
 	CreatedAt  time.Time `json:"created_at"`
 	ProfilePic string    `json:"profile_pic"`
+	// ProfilePicVariants maps a square size in pixels to a resized avatar.
+	ProfilePicVariants map[string]string `json:"profile_pic_variants,omitempty"`
+	DisplayName        string            `json:"display_name"`
+	Bio                string            `json:"bio"`
+	Timezone           string            `json:"timezone"`
+	Status             *model.UserStatus `json:"status"`
+	Role               string            `json:"role"`
+	Bot                bool              `json:"bot"`
+	// Scopes is set when the user was loaded with a personal access token.
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// Allows reports whether the credential the user was loaded with grants
+// scope, one of model.ScopeRead, model.ScopeWrite and model.ScopeChat.
+// Interactive sessions grant every scope.
+func (u *User) Allows(scope string) bool {
+	return u.Scopes == nil || model.HasScope(u.Scopes, scope)
 }
 
+type UserPage struct {
+	Users      []User `json:"users"`
+	NextCursor string `json:"next_cursor"`
+}
+
+type BlockedUser struct {
+	User      User      `json:"user"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
+
+// ClientItf talks to go-accounts. Failures are reported with ErrUnauthorized,
+// ErrNotFound and ErrUnavailable, match them with errors.Is.
 type ClientItf interface {
-	GetUserInfo(accessToken string) *User
-	GetUserByID(userID int64) *User
+	GetUserInfo(ctx context.Context, accessToken string) (*User, error)
+	GetUserByID(ctx context.Context, userID int64) (*User, error)
+	// GetUsersByIDs leaves unknown users out of the result.
+	GetUsersByIDs(ctx context.Context, userIDs []int64) ([]User, error)
+	SearchUsers(ctx context.Context, accessToken, query, cursor string, limit int) (*UserPage, error)
+	GetBlockedUsers(ctx context.Context, accessToken string) ([]BlockedUser, error)
+	IsBlocked(ctx context.Context, accessToken string, userID int64) (bool, error)
+}
+
+const (
+	defaultClientTimeout    = 5 * time.Second
+	defaultMaxRetries       = 2
+	defaultRetryBackoff     = 100 * time.Millisecond
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 10 * time.Second
+)
+
+// transport is shared by every client so connections to accounts are
+// pooled across them.
+var transport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   3 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	MaxIdleConns:          100,
+	MaxIdleConnsPerHost:   50,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   3 * time.Second,
+	ExpectContinueTimeout: time.Second,
 }
 
 func NewClient(host string, timeout time.Duration) ClientItf {
+	return NewClientWithConfig(ClientConfig{
+		Host:    host,
+		Timeout: timeout,
+	})
+}
+
+func NewClientWithConfig(cfg ClientConfig) ClientItf {
+	cfg = cfg.withDefaults()
 	return &AuthClient{
-		host:    host,
-		timeout: timeout,
+		host: strings.TrimRight(cfg.Host, "/"),
+		httpClient: &http.Client{
+			Transport: transport,
+			Timeout:   cfg.Timeout,
+		},
+		maxRetries:   cfg.MaxRetries,
+		retryBackoff: cfg.RetryBackoff,
+		breaker:      newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+	}
+}
+
+// NewClientFromConfig returns the client for cfg.Transport.
+func NewClientFromConfig(cfg ClientConfig) (ClientItf, error) {
+	switch cfg.Transport {
+	case "", TransportHTTP:
+		return NewClientWithConfig(cfg), nil
+	case TransportGRPC:
+		client, err := NewGRPCClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+	return nil, fmt.Errorf("accounts: unknown transport %q", cfg.Transport)
+}
+
+func (cfg ClientConfig) withDefaults() ClientConfig {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultClientTimeout
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = defaultMaxRetries
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultRetryBackoff
+	}
+	if cfg.BreakerThreshold == 0 {
+		cfg.BreakerThreshold = defaultBreakerThreshold
+	}
+	if cfg.BreakerCooldown <= 0 {
+		cfg.BreakerCooldown = defaultBreakerCooldown
 	}
+	return cfg
 }
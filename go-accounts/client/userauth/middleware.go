@@ -0,0 +1,43 @@
+package userauth
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lolmourne/go-accounts/model"
+)
+
+// RequireRole only lets callers holding at least role, one of model.RoleUser,
+// model.RoleModerator and model.RoleAdmin, through to handlerFunc. It reads
+// the "role" the service's session validation stored on the context, so it
+// goes inside it:
+//
+//	r.GET("/admin/users", validateSession(userauth.RequireRole(model.RoleAdmin, listUsers)))
+func RequireRole(role string, handlerFunc gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !model.HasRole(c.GetString("role"), role) {
+			c.AbortWithStatusJSON(403, gin.H{
+				"err":     "Forbidden",
+				"message": "this action needs the " + role + " role",
+				"data":    nil,
+			})
+			return
+		}
+		handlerFunc(c)
+	}
+}
+
+// RequireSession refuses personal access tokens, for account management
+// that needs the user at the keyboard. Like RequireRole it goes inside the
+// session validation, which stores "scopes" only for personal access tokens.
+func RequireSession(handlerFunc gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("scopes"); ok {
+			c.AbortWithStatusJSON(403, gin.H{
+				"err":     "Forbidden",
+				"message": "this action is not available to personal access tokens",
+				"data":    nil,
+			})
+			return
+		}
+		handlerFunc(c)
+	}
+}
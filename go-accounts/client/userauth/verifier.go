@@ -0,0 +1,244 @@
+package userauth
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/lolmourne/go-accounts/model"
+)
+
+// minRefetchInterval limits how often an unknown kid triggers a JWKS fetch.
+const minRefetchInterval = 30 * time.Second
+
+var ErrInvalidToken = errors.New("invalid token")
+
+type Claims struct {
+	UserID      int64
+	Username    string
+	DisplayName string
+	ProfilePic  string
+	Role        string
+	SessionID   string
+	ExpiresAt   time.Time
+}
+
+type VerifierItf interface {
+	Verify(accessToken string) (*Claims, error)
+}
+
+// Verifier validates access tokens offline against the keys published by
+// go-accounts. Keys are cached and refreshed every refreshInterval, or
+// earlier when a token references an unknown kid.
+//
+// The signature says nothing about revocation, LocalClient checks the
+// session denylist on top of it.
+type Verifier struct {
+	jwksURL         string
+	httpClient      *http.Client
+	refreshInterval time.Duration
+
+	mu        sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	algs      map[string]string
+	fetchedAt time.Time
+}
+
+func NewVerifier(host string, timeout time.Duration, refreshInterval time.Duration) VerifierItf {
+	return &Verifier{
+		jwksURL: host + "/.well-known/jwks.json",
+		httpClient: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+		},
+		refreshInterval: refreshInterval,
+		keys:            make(map[string]crypto.PublicKey),
+		algs:            make(map[string]string),
+	}
+}
+
+func (v *Verifier) Verify(accessToken string) (*Claims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(accessToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, alg, err := v.key(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != alg {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key, nil
+	})
+	if err != nil {
+		// keys that cannot be fetched say nothing about the token
+		if vErr, ok := err.(*jwt.ValidationError); ok && errors.Is(vErr.Inner, ErrUnavailable) {
+			return nil, vErr.Inner
+		}
+		return nil, ErrInvalidToken
+	}
+
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) || claims["typ"] != "access" {
+		return nil, ErrInvalidToken
+	}
+
+	userID, _ := claims["user_id"].(float64)
+	exp, _ := claims["exp"].(float64)
+	username, _ := claims["username"].(string)
+	displayName, _ := claims["display_name"].(string)
+	profilePic, _ := claims["profile_pic"].(string)
+	sid, _ := claims["sid"].(string)
+	if sid == "" {
+		return nil, ErrInvalidToken
+	}
+	role, _ := claims["role"].(string)
+	if role == "" {
+		role = model.RoleUser
+	}
+
+	return &Claims{
+		UserID:      int64(userID),
+		Username:    username,
+		DisplayName: displayName,
+		ProfilePic:  profilePic,
+		Role:        role,
+		SessionID:   sid,
+		ExpiresAt:   time.Unix(int64(exp), 0),
+	}, nil
+}
+
+func (v *Verifier) key(kid string) (crypto.PublicKey, string, error) {
+	v.mu.RLock()
+	key, ok := v.keys[kid]
+	alg := v.algs[kid]
+	stale := time.Since(v.fetchedAt) > v.refreshInterval
+	recent := time.Since(v.fetchedAt) < minRefetchInterval
+	v.mu.RUnlock()
+
+	if ok && !stale {
+		return key, alg, nil
+	}
+	if !ok && recent {
+		return nil, "", fmt.Errorf("unknown kid %q", kid)
+	}
+
+	err := v.refresh()
+	if err != nil {
+		log.Println(err)
+		// keep serving cached keys while go-accounts is unreachable
+		if ok {
+			return key, alg, nil
+		}
+		return nil, "", fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	key, ok = v.keys[kid]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown kid %q", kid)
+	}
+	return key, v.algs[kid], nil
+}
+
+func (v *Verifier) refresh() error {
+	resp, err := v.httpClient.Get(v.jwksURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("fetch jwks: status %d", resp.StatusCode)
+	}
+
+	var jwks JWKS
+	err = json.NewDecoder(resp.Body).Decode(&jwks)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	algs := make(map[string]string, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		pub, err := jwk.PublicKey()
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		keys[jwk.Kid] = pub
+		algs[jwk.Kid] = jwk.Alg
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.algs = algs
+	v.fetchedAt = time.Now()
+	v.mu.Unlock()
+
+	return nil
+}
+
+// LocalClient answers GetUserInfo from the access token itself when the
+// denylist knows its session is active, and delegates everything else to
+// next. Revoked sessions, demoted users included, are refused as soon as
+// go-accounts denylists them.
+type LocalClient struct {
+	ClientItf
+	verifier VerifierItf
+	denylist DenylistItf
+}
+
+func NewLocalClient(verifier VerifierItf, denylist DenylistItf, next ClientItf) ClientItf {
+	return &LocalClient{
+		ClientItf: next,
+		verifier:  verifier,
+		denylist:  denylist,
+	}
+}
+
+func (l *LocalClient) GetUserInfo(ctx context.Context, accessToken string) (*User, error) {
+	// personal access tokens can only be checked by go-accounts
+	if strings.HasPrefix(accessToken, PersonalAccessTokenPrefix) {
+		return l.ClientItf.GetUserInfo(ctx, accessToken)
+	}
+
+	claims, err := l.verifier.Verify(accessToken)
+	if err == ErrInvalidToken {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthorized, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := l.denylist.SessionStatus(ctx, claims.SessionID)
+	if err != nil {
+		log.Println(err)
+	}
+	switch status {
+	case SessionRevoked:
+		return nil, fmt.Errorf("%w: session revoked", ErrUnauthorized)
+	case SessionUnknown:
+		// go-accounts checks postgres and denylists the answer for the
+		// next call
+		return l.ClientItf.GetUserInfo(ctx, accessToken)
+	}
+
+	return &User{
+		UserID:      claims.UserID,
+		Username:    claims.Username,
+		DisplayName: claims.DisplayName,
+		ProfilePic:  claims.ProfilePic,
+		Role:        claims.Role,
+
+		ProfilePicVariants: model.AvatarVariantURLs(claims.ProfilePic),
+	}, nil
+}
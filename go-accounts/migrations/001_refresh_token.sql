@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS refresh_token (
+	token_hash  VARCHAR(64) PRIMARY KEY,
+	family_id   VARCHAR(36) NOT NULL,
+	user_id     BIGINT NOT NULL REFERENCES account (user_id) ON DELETE CASCADE,
+	created_at  TIMESTAMP NOT NULL,
+	expires_at  TIMESTAMP NOT NULL,
+	used_at     TIMESTAMP NULL,
+	revoked_at  TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS refresh_token_family_id_idx ON refresh_token (family_id);
+CREATE INDEX IF NOT EXISTS refresh_token_user_id_idx ON refresh_token (user_id);
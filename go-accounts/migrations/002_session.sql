@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS session (
+	session_id    VARCHAR(36) PRIMARY KEY,
+	user_id       BIGINT NOT NULL REFERENCES account (user_id) ON DELETE CASCADE,
+	user_agent    TEXT NOT NULL DEFAULT '',
+	ip_address    VARCHAR(45) NOT NULL DEFAULT '',
+	created_at    TIMESTAMP NOT NULL,
+	last_seen_at  TIMESTAMP NOT NULL,
+	revoked_at    TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS session_user_id_idx ON session (user_id);
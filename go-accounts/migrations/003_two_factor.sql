@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS account_totp (
+	user_id         BIGINT PRIMARY KEY REFERENCES account (user_id) ON DELETE CASCADE,
+	secret          VARCHAR(64) NOT NULL,
+	enabled         BOOLEAN NOT NULL DEFAULT false,
+	last_used_step  BIGINT NOT NULL DEFAULT 0,
+	created_at      TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS recovery_code (
+	user_id     BIGINT NOT NULL REFERENCES account (user_id) ON DELETE CASCADE,
+	code_hash   VARCHAR(64) NOT NULL,
+	created_at  TIMESTAMP NOT NULL,
+	used_at     TIMESTAMP NULL,
+	PRIMARY KEY (user_id, code_hash)
+);
@@ -0,0 +1,17 @@
+-- email stays nullable until every existing account has provided one
+ALTER TABLE account ADD COLUMN IF NOT EXISTS email VARCHAR(254) NULL;
+ALTER TABLE account ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS account_email_unique_idx ON account (lower(email)) WHERE email IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS account_token (
+	token_hash  VARCHAR(64) PRIMARY KEY,
+	user_id     BIGINT NOT NULL REFERENCES account (user_id) ON DELETE CASCADE,
+	purpose     VARCHAR(32) NOT NULL,
+	email       VARCHAR(254) NOT NULL,
+	created_at  TIMESTAMP NOT NULL,
+	expires_at  TIMESTAMP NOT NULL,
+	used_at     TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS account_token_user_id_idx ON account_token (user_id);
@@ -0,0 +1,6 @@
+ALTER TABLE account ADD COLUMN IF NOT EXISTS display_name VARCHAR(64) NULL;
+ALTER TABLE account ADD COLUMN IF NOT EXISTS bio VARCHAR(512) NULL;
+ALTER TABLE account ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NULL;
+ALTER TABLE account ADD COLUMN IF NOT EXISTS status_text VARCHAR(256) NULL;
+ALTER TABLE account ADD COLUMN IF NOT EXISTS status_emoji VARCHAR(32) NULL;
+ALTER TABLE account ADD COLUMN IF NOT EXISTS status_expires_at TIMESTAMP NULL;
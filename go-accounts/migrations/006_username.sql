@@ -0,0 +1,13 @@
+-- resolve any usernames differing only by case before running this
+CREATE UNIQUE INDEX IF NOT EXISTS account_username_lower_idx ON account (lower(username));
+
+ALTER TABLE account ADD COLUMN IF NOT EXISTS username_changed_at TIMESTAMP NULL;
+
+CREATE TABLE IF NOT EXISTS username_history (
+	id          BIGSERIAL PRIMARY KEY,
+	user_id     BIGINT NOT NULL REFERENCES account (user_id) ON DELETE CASCADE,
+	username    VARCHAR(64) NOT NULL,
+	changed_at  TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS username_history_username_idx ON username_history (lower(username), changed_at);
@@ -0,0 +1,9 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- prefix lookups
+CREATE INDEX IF NOT EXISTS account_username_prefix_idx ON account (lower(username) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS account_display_name_prefix_idx ON account (lower(COALESCE(display_name, '')) text_pattern_ops);
+
+-- fuzzy lookups through the % operator
+CREATE INDEX IF NOT EXISTS account_username_trgm_idx ON account USING GIN (lower(username) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS account_display_name_trgm_idx ON account USING GIN (lower(COALESCE(display_name, '')) gin_trgm_ops);
@@ -0,0 +1,6 @@
+ALTER TABLE account ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMP NULL;
+-- set when the user asked for deletion, the account is anonymized after it
+ALTER TABLE account ADD COLUMN IF NOT EXISTS delete_after TIMESTAMP NULL;
+ALTER TABLE account ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP NULL;
+
+CREATE INDEX IF NOT EXISTS account_delete_after_idx ON account (delete_after) WHERE deleted_at IS NULL;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS user_block (
+	user_id          BIGINT NOT NULL REFERENCES account (user_id) ON DELETE CASCADE,
+	blocked_user_id  BIGINT NOT NULL REFERENCES account (user_id) ON DELETE CASCADE,
+	created_at       TIMESTAMP NOT NULL,
+	PRIMARY KEY (user_id, blocked_user_id)
+);
+
+CREATE INDEX IF NOT EXISTS user_block_blocked_user_id_idx ON user_block (blocked_user_id);
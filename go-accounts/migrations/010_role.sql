@@ -0,0 +1,9 @@
+ALTER TABLE account ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'user';
+ALTER TABLE account ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMP NULL;
+-- set by an admin, the user has to go through /password/reset before signing in again
+ALTER TABLE account ADD COLUMN IF NOT EXISTS password_reset_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS account_role_idx ON account (role) WHERE role <> 'user';
+
+-- promote the first admin by hand:
+-- UPDATE account SET role = 'admin' WHERE username = '<username>';
@@ -0,0 +1,19 @@
+-- append only, rows outlive the accounts they describe so there is no
+-- foreign key and no way to change them afterwards
+CREATE TABLE IF NOT EXISTS audit_log (
+	event_id    BIGSERIAL PRIMARY KEY,
+	user_id     BIGINT NULL,
+	actor_id    BIGINT NULL,
+	action      VARCHAR(32) NOT NULL,
+	ip_address  VARCHAR(45) NOT NULL DEFAULT '',
+	user_agent  TEXT NOT NULL DEFAULT '',
+	metadata    JSONB NULL,
+	created_at  TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_user_id_idx ON audit_log (user_id, event_id);
+CREATE INDEX IF NOT EXISTS audit_log_action_idx ON audit_log (action, event_id);
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log (created_at);
+
+CREATE OR REPLACE RULE audit_log_no_update AS ON UPDATE TO audit_log DO INSTEAD NOTHING;
+CREATE OR REPLACE RULE audit_log_no_delete AS ON DELETE TO audit_log DO INSTEAD NOTHING;
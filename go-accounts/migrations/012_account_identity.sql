@@ -0,0 +1,12 @@
+-- external identities from OpenID Connect providers, subject is the "sub"
+-- claim, unique per provider
+CREATE TABLE IF NOT EXISTS account_identity (
+	provider    VARCHAR(64) NOT NULL,
+	subject     VARCHAR(255) NOT NULL,
+	user_id     BIGINT NOT NULL REFERENCES account (user_id) ON DELETE CASCADE,
+	email       VARCHAR(255) NULL,
+	created_at  TIMESTAMP NOT NULL,
+	PRIMARY KEY (provider, subject)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS account_identity_user_provider_idx ON account_identity (user_id, provider);
@@ -0,0 +1,22 @@
+-- bot accounts have no password and are managed by the user who created them
+ALTER TABLE account ADD COLUMN IF NOT EXISTS is_bot BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE account ADD COLUMN IF NOT EXISTS owner_id BIGINT NULL REFERENCES account (user_id);
+
+CREATE INDEX IF NOT EXISTS account_owner_idx ON account (owner_id) WHERE owner_id IS NOT NULL;
+
+-- personal access tokens, only the sha256 of the token is stored. scopes is
+-- a space separated list
+CREATE TABLE IF NOT EXISTS personal_access_token (
+	token_id      VARCHAR(36) PRIMARY KEY,
+	user_id       BIGINT NOT NULL REFERENCES account (user_id) ON DELETE CASCADE,
+	created_by    BIGINT NOT NULL,
+	name          VARCHAR(100) NOT NULL,
+	token_hash    VARCHAR(64) NOT NULL UNIQUE,
+	scopes        VARCHAR(255) NOT NULL,
+	created_at    TIMESTAMP NOT NULL,
+	expires_at    TIMESTAMP NULL,
+	last_used_at  TIMESTAMP NULL,
+	revoked_at    TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS personal_access_token_user_idx ON personal_access_token (user_id);
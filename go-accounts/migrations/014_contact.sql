@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS contact_request (
+	requester_id  BIGINT NOT NULL REFERENCES account (user_id) ON DELETE CASCADE,
+	addressee_id  BIGINT NOT NULL REFERENCES account (user_id) ON DELETE CASCADE,
+	created_at    TIMESTAMP NOT NULL,
+	PRIMARY KEY (requester_id, addressee_id)
+);
+
+CREATE INDEX IF NOT EXISTS contact_request_addressee_id_idx ON contact_request (addressee_id);
+
+-- every contact is stored once for each side
+CREATE TABLE IF NOT EXISTS contact (
+	user_id          BIGINT NOT NULL REFERENCES account (user_id) ON DELETE CASCADE,
+	contact_user_id  BIGINT NOT NULL REFERENCES account (user_id) ON DELETE CASCADE,
+	created_at       TIMESTAMP NOT NULL,
+	PRIMARY KEY (user_id, contact_user_id)
+);
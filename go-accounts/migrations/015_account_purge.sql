@@ -0,0 +1,13 @@
+-- outbox of deleted accounts the other services still have to scrub,
+-- go-accounts publishes the deletion again until every service confirmed
+CREATE TABLE IF NOT EXISTS account_purge (
+	user_id         BIGINT NOT NULL,
+	service         VARCHAR(32) NOT NULL,
+	created_at      TIMESTAMP NOT NULL,
+	last_attempt_at TIMESTAMP NOT NULL,
+	attempts        INT NOT NULL DEFAULT 1,
+	confirmed_at    TIMESTAMP NULL,
+	PRIMARY KEY (user_id, service)
+);
+
+CREATE INDEX IF NOT EXISTS account_purge_pending_idx ON account_purge (last_attempt_at) WHERE confirmed_at IS NULL;
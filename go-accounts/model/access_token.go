@@ -0,0 +1,57 @@
+package model
+
+import "time"
+
+// Scopes of a personal access token. ScopeRead allows GET requests,
+// ScopeWrite every other method and ScopeChat connecting to the websocket.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeChat  = "chat"
+)
+
+func ValidScope(scope string) bool {
+	return scope == ScopeRead || scope == ScopeWrite || scope == ScopeChat
+}
+
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeForMethod is the scope a token needs to make an HTTP request with
+// method.
+func ScopeForMethod(method string) string {
+	switch method {
+	case "GET", "HEAD", "OPTIONS":
+		return ScopeRead
+	}
+	return ScopeWrite
+}
+
+// PersonalAccessToken is a long lived credential for scripts and bots. The
+// token itself is only known when it is created.
+type PersonalAccessToken struct {
+	TokenID string `json:"token_id"`
+	UserID  int64  `json:"user_id"`
+	// CreatedBy differs from UserID for tokens of bot accounts, it is the
+	// bot's owner.
+	CreatedBy  int64      `json:"created_by"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreatedAccessToken is returned once, when the token is created.
+type CreatedAccessToken struct {
+	PersonalAccessToken
+	Token string `json:"token"`
+}
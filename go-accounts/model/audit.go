@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+const (
+	AuditLogin              = "login"
+	AuditLoginFailed        = "login_failed"
+	AuditPasswordChange     = "password_change"
+	AuditPasswordReset      = "password_reset"
+	AuditUsernameChange     = "username_change"
+	AuditProfilePicChange   = "profile_pic_change"
+	AuditRoleChange         = "role_change"
+	AuditSuspend            = "suspend"
+	AuditUnsuspend          = "unsuspend"
+	AuditForcePasswordReset = "force_password_reset"
+	AuditIdentityLink       = "identity_link"
+	AuditIdentityUnlink     = "identity_unlink"
+	AuditTokenCreate        = "token_create"
+	AuditTokenRevoke        = "token_revoke"
+	AuditBotCreate          = "bot_create"
+	AuditBotDelete          = "bot_delete"
+	AuditEmailChange        = "email_change"
+	AuditTwoFactorDisable   = "two_factor_disable"
+)
+
+// AuditEvent is an entry of the security audit log. UserID is the account
+// the event is about, 0 for failed logins to unknown usernames. ActorID is
+// set when somebody else, such as an admin, acted on the account.
+type AuditEvent struct {
+	EventID   int64             `json:"event_id"`
+	UserID    int64             `json:"user_id"`
+	ActorID   int64             `json:"actor_id,omitempty"`
+	Action    string            `json:"action"`
+	IPAddress string            `json:"ip_address"`
+	UserAgent string            `json:"user_agent"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// AuditFilter selects audit events, newest first. Zero fields match
+// everything, BeforeID continues after the last event of a page.
+type AuditFilter struct {
+	UserID    int64
+	ActorID   int64
+	Action    string
+	IPAddress string
+	Since     *time.Time
+	Until     *time.Time
+	BeforeID  int64
+	Limit     int
+}
+
+type AuditPage struct {
+	Events     []AuditEvent `json:"events"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
@@ -1,5 +1,76 @@
 package model
 
+import "time"
+
+// UserToken is returned on login. When the account has two factor
+// authentication enabled only ChallengeToken is set and has to be exchanged
+// on /login/2fa.
 type UserToken struct {
-	AccessToken string `json:"access_token"`
+	AccessToken       string `json:"access_token,omitempty"`
+	RefreshToken      string `json:"refresh_token,omitempty"`
+	TokenType         string `json:"token_type,omitempty"`
+	ExpiresIn         int64  `json:"expires_in,omitempty"`
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+}
+
+// AccessClaims holds the validated claims of an access token. SessionID
+// identifies the refresh token family the access token was issued from.
+// Scopes is only set for personal access tokens, TokenID is then the id of
+// the token and SessionID is empty.
+type AccessClaims struct {
+	UserID    int64
+	Role      string
+	SessionID string
+	TokenID   string
+	Scopes    []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// RefreshToken is a server side record of an issued refresh token. Only the
+// hash of the token is stored. Every rotation issues a new token in the same
+// family; presenting a used token again revokes the whole family.
+type RefreshToken struct {
+	TokenHash string
+	FamilyID  string
+	UserID    int64
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+// ClientInfo describes the device a request came from.
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// Session is created on every successful login. SessionID is shared with the
+// refresh token family and carried in the "sid" claim of access tokens.
+type Session struct {
+	SessionID  string     `json:"session_id"`
+	UserID     int64      `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Current    bool       `json:"current"`
+}
+
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeResetPassword = "reset_password"
+)
+
+// AccountToken is a single use token sent by email. Only its hash is stored.
+type AccountToken struct {
+	TokenHash string
+	UserID    int64
+	Purpose   string
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
 }
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+)
+
+// AvatarSizes are the square variants generated for every uploaded avatar.
+var AvatarSizes = []int{32, 64, 256}
+
+// avatarOriginal matches the URL of an avatar stored by AvatarKey, older
+// uploads and external links have no variants.
+var avatarOriginal = regexp.MustCompile(`/image/avatar/([0-9a-f-]+)/original\.(jpg|png)$`)
+
+// AvatarKey is the storage key of one rendition of avatar id, name being
+// "original" or a size from AvatarSizes.
+func AvatarKey(id, name, ext string) string {
+	return fmt.Sprintf("image/avatar/%s/%s.%s", id, name, ext)
+}
+
+// AvatarVariantURLs derives the variant URLs from the URL of an original
+// avatar. It returns nil when profilePic has no variants.
+func AvatarVariantURLs(profilePic string) map[string]string {
+	if !avatarOriginal.MatchString(profilePic) {
+		return nil
+	}
+
+	dir, file := path.Split(profilePic)
+	ext := path.Ext(file)
+	variants := make(map[string]string, len(AvatarSizes))
+	for _, size := range AvatarSizes {
+		name := strconv.Itoa(size)
+		variants[name] = dir + name + ext
+	}
+	return variants
+}
+
+// AvatarKeys returns the storage keys of every rendition of the avatar at
+// profilePic, or nil when it was not stored by AvatarKey.
+func AvatarKeys(profilePic string) []string {
+	match := avatarOriginal.FindStringSubmatch(profilePic)
+	if match == nil {
+		return nil
+	}
+
+	id, ext := match[1], match[2]
+	keys := []string{AvatarKey(id, "original", ext)}
+	for _, size := range AvatarSizes {
+		keys = append(keys, AvatarKey(id, strconv.Itoa(size), ext))
+	}
+	return keys
+}
@@ -1,10 +1,23 @@
 package model
 
 type Config struct {
-	DB     PostgreCfg   `json:"postgresql"`
-	Redis  RedisCfg     `json:"redis"`
-	JWT    JWTCfg       `json:"jwt"`
-	S3Cred S3Credential `json:"s3"`
+	DB        PostgreCfg   `json:"postgresql"`
+	Redis     RedisCfg     `json:"redis"`
+	JWT       JWTCfg       `json:"jwt"`
+	S3Cred    S3Credential `json:"s3"`
+	Storage   StorageCfg   `json:"storage"`
+	Username  UsernameCfg  `json:"username"`
+	Account   AccountCfg   `json:"account"`
+	Services  ServicesCfg  `json:"services"`
+	Password  PasswordCfg  `json:"password"`
+	TOTP      TOTPCfg      `json:"totp"`
+	RateLimit RateLimitCfg `json:"rate_limit"`
+	Mail      MailCfg      `json:"mail"`
+	OIDC      []OIDCCfg    `json:"oidc"`
+	GRPC      GRPCCfg      `json:"grpc"`
+	// TrustedProxies lists the IPs or CIDRs of the proxies in front of the
+	// service, only they may set X-Forwarded-For.
+	TrustedProxies []string `json:"trusted_proxies"`
 }
 
 type PostgreCfg struct {
@@ -21,11 +34,112 @@ type RedisCfg struct {
 }
 
 type JWTCfg struct {
-	SignKey string `json:"signKey"`
+	SigningKID      string      `json:"signing_kid"`
+	Keys            []JWTKeyCfg `json:"keys"`
+	AccessTokenTTL  int64       `json:"access_token_ttl"`  // seconds
+	RefreshTokenTTL int64       `json:"refresh_token_ttl"` // seconds
+}
+
+// JWTKeyCfg points to a PEM encoded RSA or Ed25519 private key.
+type JWTKeyCfg struct {
+	KID            string `json:"kid"`
+	PrivateKeyFile string `json:"private_key_file"`
 }
 
 type S3Credential struct {
 	AccessID   string `json:"access_id"`
 	Secret     string `json:"secret"`
 	BucketName string `json:"bucket_name"`
+	Region     string `json:"region"`
+	// Endpoint points the client at an S3 compatible service such as MinIO,
+	// leave it empty for AWS.
+	Endpoint       string `json:"endpoint"`
+	ForcePathStyle bool   `json:"force_path_style"`
+}
+
+// StorageCfg selects where uploaded files are kept. Driver is "s3" or
+// "local"; PublicURL is prepended to object keys to build the links handed
+// out to clients.
+type StorageCfg struct {
+	Driver    string `json:"driver"`
+	Dir       string `json:"dir"`
+	PublicURL string `json:"public_url"`
+}
+
+type PasswordCfg struct {
+	Algorithm         string `json:"algorithm"`
+	BcryptCost        int    `json:"bcrypt_cost"`
+	Argon2Memory      uint32 `json:"argon2_memory"`
+	Argon2Iterations  uint32 `json:"argon2_iterations"`
+	Argon2Parallelism uint8  `json:"argon2_parallelism"`
+}
+
+// UsernameCfg durations are in seconds. ChangeCooldown is the wait between
+// two renames, HoldPeriod how long a released username stays reserved for
+// its previous owner.
+type UsernameCfg struct {
+	ChangeCooldown int64 `json:"change_cooldown"`
+	HoldPeriod     int64 `json:"hold_period"`
+}
+
+// AccountCfg.DeletionGracePeriod is in seconds.
+type AccountCfg struct {
+	DeletionGracePeriod int64 `json:"deletion_grace_period"`
+}
+
+// ServicesCfg locates the other services, used to gather their data for
+// exports.
+type ServicesCfg struct {
+	GroupchatHost string `json:"groupchat_host"`
+	WebsocketHost string `json:"websocket_host"`
+}
+
+type TOTPCfg struct {
+	Issuer string `json:"issuer"`
+}
+
+// RateLimitCfg durations are in seconds.
+type RateLimitCfg struct {
+	LoginPerMinute    int   `json:"login_per_minute"`
+	RegisterPerMinute int   `json:"register_per_minute"`
+	MaxFailures       int   `json:"max_failures"`
+	FailureWindow     int64 `json:"failure_window"`
+	BaseLockout       int64 `json:"base_lockout"`
+	MaxLockout        int64 `json:"max_lockout"`
+}
+
+// OIDCCfg registers an OpenID Connect provider under Name, the provider
+// segment of the /oidc routes. Issuer is the URL its discovery document is
+// served under. RedirectURL must point at /oidc/<name>/callback.
+//
+// New users are signed up automatically unless DisableSignup is set.
+// TrustEmail links a sign in to the local account with the same address,
+// enable it only for providers that verify every address they assert.
+type OIDCCfg struct {
+	Name          string   `json:"name"`
+	Issuer        string   `json:"issuer"`
+	ClientID      string   `json:"client_id"`
+	ClientSecret  string   `json:"client_secret"`
+	RedirectURL   string   `json:"redirect_url"`
+	Scopes        []string `json:"scopes"`
+	DisableSignup bool     `json:"disable_signup"`
+	TrustEmail    bool     `json:"trust_email"`
+}
+
+// GRPCCfg.Address is where the Accounts gRPC service listens, :7272 by
+// default.
+type GRPCCfg struct {
+	Address string `json:"address"`
+}
+
+type MailCfg struct {
+	Driver   string `json:"driver"`
+	Host     string `json:"host"`
+	Port     string `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"`
+	Dir      string `json:"dir"`
+	// LinkBaseURL prefixes the verification and password reset links.
+	LinkBaseURL string `json:"link_base_url"`
 }
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// Contact is a user the owner of the list is connected with. Online comes
+// from the websocket service and is left out when it cannot be reached.
+type Contact struct {
+	User   User      `json:"user"`
+	Since  time.Time `json:"since"`
+	Online *bool     `json:"online,omitempty"`
+}
+
+// ContactRequest is a pending request, User is the other side: the
+// requester for incoming requests and the addressee for outgoing ones.
+type ContactRequest struct {
+	User      User      `json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// OIDCIdentity is what an identity provider asserted about a user in a
+// verified ID token.
+type OIDCIdentity struct {
+	Provider          string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	Nonce             string
+}
+
+// Identity links an account at an external provider to a local account.
+type Identity struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	UserID    int64     `json:"user_id"`
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OIDCResult is the outcome of an OIDC callback: tokens when the user
+// signed in, or the identity linked to the signed in account.
+type OIDCResult struct {
+	Token    *UserToken `json:"token,omitempty"`
+	Identity *Identity  `json:"identity,omitempty"`
+}
@@ -0,0 +1,20 @@
+package model
+
+// UserSearchCursor is the position of the last user of a search page.
+// Results are ordered by Rank, then Score descending, then UserID.
+type UserSearchCursor struct {
+	Rank   int     `json:"r"`
+	Score  float64 `json:"s"`
+	UserID int64   `json:"u"`
+}
+
+type UserSearchHit struct {
+	User  User
+	Rank  int
+	Score float64
+}
+
+type UserPage struct {
+	Users      []User `json:"users"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
@@ -5,8 +5,63 @@ import "time"
 type User struct {
 	UserID     int64     `json:"user_id"`
 	Username   string    `json:"username"`
-	Password   string    `json:"omitempty"`
-	Salt       string    `json:"omitempty"`
+	Password   string    `json:"-"`
+	Salt       string    `json:"-"`
 	CreatedAt  time.Time `json:"created_at"`
 	ProfilePic string    `json:"profile_pic"`
+	// ProfilePicVariants maps a square size in pixels, "32", "64" or "256",
+	// to the URL of the resized avatar.
+	ProfilePicVariants map[string]string `json:"profile_pic_variants,omitempty"`
+	Email              string            `json:"email,omitempty"`
+	EmailVerified      bool              `json:"email_verified"`
+	DisplayName        string            `json:"display_name"`
+	Bio                string            `json:"bio"`
+	Timezone           string            `json:"timezone"`
+	Status             *UserStatus       `json:"status"`
+	UsernameChangedAt  *time.Time        `json:"username_changed_at,omitempty"`
+	DeactivatedAt      *time.Time        `json:"deactivated_at,omitempty"`
+	// DeleteAfter is set while a deletion request is pending, the account
+	// is anonymized once it has passed.
+	DeleteAfter *time.Time `json:"delete_after,omitempty"`
+	Deleted     bool       `json:"deleted,omitempty"`
+	// Role is one of RoleUser, RoleModerator and RoleAdmin.
+	Role                  string     `json:"role"`
+	SuspendedAt           *time.Time `json:"suspended_at,omitempty"`
+	PasswordResetRequired bool       `json:"password_reset_required,omitempty"`
+	// Bot accounts sign in with personal access tokens only, OwnerID is the
+	// user managing them.
+	Bot     bool  `json:"bot"`
+	OwnerID int64 `json:"owner_id,omitempty"`
+}
+
+// Public strips what only the user and admins may see.
+func (u User) Public() User {
+	u.Password = ""
+	u.Salt = ""
+	u.Email = ""
+	u.SuspendedAt = nil
+	u.PasswordResetRequired = false
+	return u
+}
+
+// UserStatus is a short message shown next to the user, cleared
+// automatically once ExpiresAt has passed.
+type UserStatus struct {
+	Text      string     `json:"text"`
+	Emoji     string     `json:"emoji"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (s *UserStatus) Expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !now.Before(*s.ExpiresAt)
+}
+
+// ProfileUpdate carries a partial profile update, nil fields are left
+// untouched.
+type ProfileUpdate struct {
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	Timezone    *string `json:"timezone"`
+	// Status replaces the current status, an empty text and emoji clears it.
+	Status *UserStatus `json:"status"`
 }
@@ -0,0 +1,180 @@
+package acc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/lolmourne/go-accounts/model"
+)
+
+const (
+	userCacheTTL = 10 * time.Minute
+	// userMissTTL keeps lookups of unknown users from reaching postgres on
+	// every request while letting a new registration show up quickly.
+	userMissTTL = 30 * time.Second
+
+	userMissValue = "-"
+)
+
+// cachedUser adds back the fields model.User hides from JSON, so a cached
+// user can still be used to check passwords.
+type cachedUser struct {
+	model.User
+	Password string `json:"password"`
+	Salt     string `json:"salt"`
+}
+
+// userKey is versioned: builds before cachedUser stored users without their
+// password hash and without a TTL, those entries must never be read back.
+func userKey(userID int64) string {
+	return fmt.Sprintf("user:v2:%d", userID)
+}
+
+// usernameKey indexes a username to its user id.
+func usernameKey(username string) string {
+	return fmt.Sprintf("username:%s", strings.ToLower(username))
+}
+
+// getCachedUser returns the cached user, whether the key was found at all,
+// and a found user with UserID 0 when the miss itself was cached.
+func (dbr *RedisResource) getCachedUser(ctx context.Context, userID int64) (model.User, bool) {
+	val, err := dbr.rdb.Get(ctx, userKey(userID)).Result()
+	if err != nil {
+		if err != redis.Nil {
+			log.Println(err)
+		}
+		return model.User{}, false
+	}
+
+	if val == userMissValue {
+		return model.User{}, true
+	}
+
+	user, err := decodeCachedUser(val)
+	if err != nil {
+		log.Println(err)
+		return model.User{}, false
+	}
+	return user, true
+}
+
+func decodeCachedUser(val string) (model.User, error) {
+	var cu cachedUser
+	err := json.Unmarshal([]byte(val), &cu)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	user := cu.User
+	user.Password = cu.Password
+	user.Salt = cu.Salt
+	return withDerivedFields(user), nil
+}
+
+// getCachedUsers looks userIDs up with one MGET. It returns the cached users
+// and the ids that have to be loaded; ids cached as unknown are in neither.
+func (dbr *RedisResource) getCachedUsers(ctx context.Context, userIDs []int64) ([]model.User, []int64) {
+	keys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = userKey(userID)
+	}
+
+	vals, err := dbr.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		log.Println(err)
+		return []model.User{}, userIDs
+	}
+
+	users := make([]model.User, 0, len(userIDs))
+	var missing []int64
+	for i, val := range vals {
+		str, ok := val.(string)
+		if !ok {
+			missing = append(missing, userIDs[i])
+			continue
+		}
+		if str == userMissValue {
+			continue
+		}
+
+		user, err := decodeCachedUser(str)
+		if err != nil {
+			log.Println(err)
+			missing = append(missing, userIDs[i])
+			continue
+		}
+		users = append(users, user)
+	}
+
+	return users, missing
+}
+
+// getCachedUserID resolves username through the index. found is false when
+// the index has no entry; userID is 0 when the miss was cached.
+func (dbr *RedisResource) getCachedUserID(ctx context.Context, username string) (int64, bool) {
+	val, err := dbr.rdb.Get(ctx, usernameKey(username)).Result()
+	if err != nil {
+		if err != redis.Nil {
+			log.Println(err)
+		}
+		return 0, false
+	}
+
+	if val == userMissValue {
+		return 0, true
+	}
+
+	userID, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return userID, true
+}
+
+func (dbr *RedisResource) cacheUser(ctx context.Context, user model.User) {
+	userJSON, err := json.Marshal(cachedUser{
+		User:     user,
+		Password: user.Password,
+		Salt:     user.Salt,
+	})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	pipe := dbr.rdb.Pipeline()
+	pipe.Set(ctx, userKey(user.UserID), userJSON, userCacheTTL)
+	pipe.Set(ctx, usernameKey(user.Username), user.UserID, userCacheTTL)
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (dbr *RedisResource) cacheMiss(ctx context.Context, key string) {
+	err := dbr.rdb.Set(ctx, key, userMissValue, userMissTTL).Err()
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// invalidateUser drops the cached user and the username index entries.
+func (dbr *RedisResource) invalidateUser(ctx context.Context, userID int64, usernames ...string) {
+	keys := []string{userKey(userID)}
+	for _, username := range usernames {
+		if username != "" {
+			keys = append(keys, usernameKey(username))
+		}
+	}
+
+	err := dbr.rdb.Del(ctx, keys...).Err()
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -1,14 +1,24 @@
 package acc
 
 import (
+	"errors"
+	"time"
+
 	"github.com/go-redis/redis/v8"
 	"github.com/jmoiron/sqlx"
 	"github.com/lolmourne/go-accounts/model"
+	"golang.org/x/sync/singleflight"
 )
 
+// ErrDuplicate is returned when a write violates a unique constraint, such
+// as registering a username that is already taken.
+var ErrDuplicate = errors.New("duplicate value")
+
 type RedisResource struct {
-	rdb  *redis.Client
-	next DBItf
+	rdb                *redis.Client
+	next               DBItf
+	sf                 singleflight.Group
+	sessionDenylistTTL time.Duration
 }
 
 type DBResource struct {
@@ -16,18 +26,126 @@ type DBResource struct {
 }
 
 type DBItf interface {
-	Register(username string, password string, salt string) error
+	Register(username string, password string, salt string, email string) error
 	GetUserByUserID(userID int64) (model.User, error)
 	GetUserByUserName(userName string) (model.User, error)
+	GetUserByEmail(email string) (model.User, error)
+	GetUsersByIDs(userIDs []int64) ([]model.User, error)
 	UpdateUserProfpic(userID int64, newProfpic string) error
 	UpdateUserName(userID int64, newUsername string) error
 	UpdateUserPassword(userID int64, newPassword string) error
+	UpdateUserEmail(userID int64, email string) error
+	MarkEmailVerified(userID int64, email string) error
+	UpdateUserProfile(userID int64, update model.ProfileUpdate) error
+	UpdateUserStatus(userID int64, status *model.UserStatus) error
+	// GetUsernameHolder returns the user that gave up username after since,
+	// or 0 when nobody did.
+	GetUsernameHolder(username string, since time.Time) (int64, error)
+	// SearchUsers leaves out users blocked by viewerID and users who
+	// blocked viewerID.
+	SearchUsers(viewerID int64, query string, cursor model.UserSearchCursor, limit int) ([]model.UserSearchHit, error)
+	DeactivateUser(userID int64, deactivatedAt time.Time, deleteAfter *time.Time) error
+	ReactivateUser(userID int64) error
+	GetUsersDueForDeletion(now time.Time, limit int) ([]int64, error)
+	AnonymizeUser(userID int64, deletedAt time.Time, services []string) error
+	GetPendingPurges(attemptedBefore time.Time, limit int) ([]int64, error)
+	MarkPurgeAttempted(userID int64, attemptedAt time.Time) error
+	ConfirmPurge(userID int64, service string, confirmedAt time.Time) error
+
+	BlockUser(userID, blockedUserID int64, blockedAt time.Time) error
+	UnblockUser(userID, blockedUserID int64) error
+	GetBlockedUsers(userID int64) ([]model.BlockedUser, error)
+	// IsBlocked reports whether either user blocked the other.
+	IsBlocked(userID, otherUserID int64) (bool, error)
+
+	// CreateContactRequest records a request from requesterID to
+	// addresseeID, false when it was already pending.
+	CreateContactRequest(requesterID, addresseeID int64, createdAt time.Time) (bool, error)
+	// DeleteContactRequest reports false when there was no such request.
+	DeleteContactRequest(requesterID, addresseeID int64) (bool, error)
+	// AcceptContactRequest turns the request into a contact on both sides,
+	// false when there was no such request.
+	AcceptContactRequest(requesterID, addresseeID int64, acceptedAt time.Time) (bool, error)
+	// GetContactRequests returns the requests sent to userID, or sent by it
+	// when outgoing, most recent first.
+	GetContactRequests(userID int64, outgoing bool) ([]model.ContactRequest, error)
+	// GetContacts returns the contacts of userID that are not deactivated,
+	// by username.
+	GetContacts(userID int64) ([]model.Contact, error)
+	IsContact(userID, otherUserID int64) (bool, error)
+	// GetMutualContacts returns the active users both users have as contact.
+	GetMutualContacts(userID, otherUserID int64) ([]model.User, error)
+	// RemoveContact drops the contact and any pending request between the
+	// two users, false when there was neither.
+	RemoveContact(userID, otherUserID int64) (bool, error)
+
+	// ListUsers pages through accounts by user id, role filters when set.
+	ListUsers(role string, afterUserID int64, limit int) ([]model.User, error)
+	UpdateUserRole(userID int64, role string) error
+	// SuspendUser suspends the account at suspendedAt, nil lifts the
+	// suspension.
+	SuspendUser(userID int64, suspendedAt *time.Time) error
+	SetPasswordResetRequired(userID int64, required bool) error
+
+	// GetUserIDByIdentity returns the account linked to the external
+	// identity, or 0 when there is none.
+	GetUserIDByIdentity(provider, subject string) (int64, error)
+	CreateIdentity(identity model.Identity) error
+	GetIdentities(userID int64) ([]model.Identity, error)
+	DeleteIdentity(userID int64, provider string) error
+
+	// CreateBot creates a bot account without a password owned by ownerID.
+	CreateBot(username string, ownerID int64, createdAt time.Time) (int64, error)
+	// GetBots returns the bots of ownerID that are not deleted.
+	GetBots(ownerID int64) ([]model.User, error)
+	CreateAccessToken(token model.PersonalAccessToken) error
+	// GetAccessTokenByHash returns an empty token when none matches.
+	GetAccessTokenByHash(tokenHash string) (model.PersonalAccessToken, error)
+	// GetAccessTokens returns the tokens of userID that are not revoked.
+	GetAccessTokens(userID int64) ([]model.PersonalAccessToken, error)
+	// RevokeAccessToken reports false when userID has no such active token.
+	RevokeAccessToken(userID int64, tokenID string, revokedAt time.Time) (bool, error)
+	RevokeAccessTokens(userID int64, revokedAt time.Time) error
+	// TouchAccessToken records a use, at most once per interval.
+	TouchAccessToken(tokenID string, usedAt time.Time, interval time.Duration) error
+
+	CreateRefreshToken(token model.RefreshToken) error
+	GetRefreshToken(tokenHash string) (model.RefreshToken, error)
+	MarkRefreshTokenUsed(tokenHash string, usedAt time.Time) (bool, error)
+	RevokeTokenFamily(familyID string) error
+	IsTokenFamilyRevoked(familyID string) (bool, error)
+
+	CreateSession(session model.Session) error
+	GetSession(sessionID string) (model.Session, error)
+	GetSessionsByUserID(userID int64) ([]model.Session, error)
+	TouchSession(sessionID string, ipAddress string, seenAt time.Time) error
+	RevokeSession(sessionID string) error
+	IsSessionRevoked(sessionID string) (bool, error)
+
+	SaveTOTPSecret(userID int64, secret string) error
+	GetTOTP(userID int64) (model.TOTP, error)
+	EnableTOTP(userID int64) error
+	DisableTOTP(userID int64) error
+	UseTOTPStep(userID int64, step int64) (bool, error)
+	SaveRecoveryCodes(userID int64, codeHashes []string) error
+	UseRecoveryCode(userID int64, codeHash string) (bool, error)
+
+	CreateAccountToken(token model.AccountToken) error
+	UseAccountToken(tokenHash string, purpose string, usedAt time.Time) (model.AccountToken, error)
 }
 
-func NewRedisResource(rdb *redis.Client, next DBItf) DBItf {
+// NewRedisResource caches next in redis. accessTokenTTL is how long access
+// tokens live, revoked sessions stay in the denylist at least that long.
+func NewRedisResource(rdb *redis.Client, next DBItf, accessTokenTTL time.Duration) DBItf {
+	denylistTTL := defaultSessionDenylistTTL
+	if accessTokenTTL > 0 {
+		denylistTTL = accessTokenTTL + sessionDenylistSkew
+	}
+
 	return &RedisResource{
-		rdb:  rdb,
-		next: next,
+		rdb:                rdb,
+		next:               next,
+		sessionDenylistTTL: denylistTTL,
 	}
 }
 
@@ -6,10 +6,106 @@ import (
 )
 
 type UserDB struct {
+	UserID                sql.NullInt64  `db:"user_id"`
+	UserName              sql.NullString `db:"username"`
+	ProfilePic            sql.NullString `db:"profile_pic"`
+	Salt                  sql.NullString `db:"salt"`
+	Password              sql.NullString `db:"password"`
+	CreatedAt             time.Time      `db:"created_at"`
+	Email                 sql.NullString `db:"email"`
+	EmailVerifiedAt       sql.NullTime   `db:"email_verified_at"`
+	DisplayName           sql.NullString `db:"display_name"`
+	Bio                   sql.NullString `db:"bio"`
+	Timezone              sql.NullString `db:"timezone"`
+	StatusText            sql.NullString `db:"status_text"`
+	StatusEmoji           sql.NullString `db:"status_emoji"`
+	StatusExpiresAt       sql.NullTime   `db:"status_expires_at"`
+	UsernameChangedAt     sql.NullTime   `db:"username_changed_at"`
+	DeactivatedAt         sql.NullTime   `db:"deactivated_at"`
+	DeleteAfter           sql.NullTime   `db:"delete_after"`
+	DeletedAt             sql.NullTime   `db:"deleted_at"`
+	Role                  sql.NullString `db:"role"`
+	SuspendedAt           sql.NullTime   `db:"suspended_at"`
+	PasswordResetRequired sql.NullBool   `db:"password_reset_required"`
+	IsBot                 sql.NullBool   `db:"is_bot"`
+	OwnerID               sql.NullInt64  `db:"owner_id"`
+}
+
+type UserSearchDB struct {
+	UserDB
+	SearchRank  int     `db:"search_rank"`
+	SearchScore float64 `db:"search_score"`
+}
+
+type BlockedUserDB struct {
+	UserDB
+	BlockedAt time.Time `db:"blocked_at"`
+}
+
+type ContactDB struct {
+	UserDB
+	Since time.Time `db:"since"`
+}
+
+type ContactRequestDB struct {
+	UserDB
+	RequestedAt time.Time `db:"requested_at"`
+}
+
+type IdentityDB struct {
+	Provider  string         `db:"provider"`
+	Subject   string         `db:"subject"`
+	UserID    int64          `db:"user_id"`
+	Email     sql.NullString `db:"email"`
+	CreatedAt time.Time      `db:"created_at"`
+}
+
+type PersonalAccessTokenDB struct {
+	TokenID    string       `db:"token_id"`
+	UserID     int64        `db:"user_id"`
+	CreatedBy  int64        `db:"created_by"`
+	Name       string       `db:"name"`
+	TokenHash  string       `db:"token_hash"`
+	Scopes     string       `db:"scopes"`
+	CreatedAt  time.Time    `db:"created_at"`
+	ExpiresAt  sql.NullTime `db:"expires_at"`
+	LastUsedAt sql.NullTime `db:"last_used_at"`
+	RevokedAt  sql.NullTime `db:"revoked_at"`
+}
+
+type RefreshTokenDB struct {
+	TokenHash sql.NullString `db:"token_hash"`
+	FamilyID  sql.NullString `db:"family_id"`
+	UserID    sql.NullInt64  `db:"user_id"`
+	CreatedAt time.Time      `db:"created_at"`
+	ExpiresAt time.Time      `db:"expires_at"`
+	UsedAt    sql.NullTime   `db:"used_at"`
+	RevokedAt sql.NullTime   `db:"revoked_at"`
+}
+
+type SessionDB struct {
+	SessionID  sql.NullString `db:"session_id"`
 	UserID     sql.NullInt64  `db:"user_id"`
-	UserName   sql.NullString `db:"username"`
-	ProfilePic sql.NullString `db:"profile_pic"`
-	Salt       sql.NullString `db:"salt"`
-	Password   sql.NullString `db:"password"`
+	UserAgent  sql.NullString `db:"user_agent"`
+	IPAddress  sql.NullString `db:"ip_address"`
 	CreatedAt  time.Time      `db:"created_at"`
+	LastSeenAt time.Time      `db:"last_seen_at"`
+	RevokedAt  sql.NullTime   `db:"revoked_at"`
+}
+
+type TOTPDB struct {
+	UserID       sql.NullInt64  `db:"user_id"`
+	Secret       sql.NullString `db:"secret"`
+	Enabled      sql.NullBool   `db:"enabled"`
+	LastUsedStep sql.NullInt64  `db:"last_used_step"`
+	CreatedAt    time.Time      `db:"created_at"`
+}
+
+type AccountTokenDB struct {
+	TokenHash sql.NullString `db:"token_hash"`
+	UserID    sql.NullInt64  `db:"user_id"`
+	Purpose   sql.NullString `db:"purpose"`
+	Email     sql.NullString `db:"email"`
+	CreatedAt time.Time      `db:"created_at"`
+	ExpiresAt time.Time      `db:"expires_at"`
 }
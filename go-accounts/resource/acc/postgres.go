@@ -1,12 +1,40 @@
 package acc
 
 import (
+	"database/sql"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/lolmourne/go-accounts/model"
 )
 
-func (dbr *DBResource) Register(username string, password string, salt string) error {
+// userColumns are the account columns scanned into UserDB.
+const userColumns = `
+		user_id,
+		username,
+		password,
+		salt,
+		created_at,
+		profile_pic,
+		email,
+		email_verified_at,
+		display_name,
+		bio,
+		timezone,
+		status_text,
+		status_emoji,
+		status_expires_at,
+		username_changed_at,
+		deactivated_at,
+		delete_after,
+		deleted_at,
+		role,
+		suspended_at,
+		password_reset_required,
+		is_bot,
+		owner_id`
+
+func (dbr *DBResource) Register(username string, password string, salt string, email string) error {
 	query := `
 		INSERT INTO
 			account
@@ -15,7 +43,8 @@ func (dbr *DBResource) Register(username string, password string, salt string) e
 			password,
 			salt,
 			created_at,
-			profile_pic
+			profile_pic,
+			email
 		)
 		VALUES
 		(
@@ -23,13 +52,14 @@ func (dbr *DBResource) Register(username string, password string, salt string) e
 			$2,
 			$3,
 			$4,
-			$5
+			$5,
+			NULLIF($6, '')
 		)
 	`
 
-	_, err := dbr.db.Exec(query, username, password, salt, time.Now(), "")
+	_, err := dbr.db.Exec(query, username, password, salt, time.Now(), "", email)
 	if err != nil {
-		return err
+		return uniqueErr(err)
 	}
 
 	return nil
@@ -37,13 +67,8 @@ func (dbr *DBResource) Register(username string, password string, salt string) e
 
 func (dbr *DBResource) GetUserByUserID(userID int64) (model.User, error) {
 	query := `
-	SELECT 
-		user_id,
-		username,
-		password,
-		salt,
-		created_at,
-		profile_pic
+	SELECT
+		` + userColumns + `
 	FROM
 		account
 	WHERE
@@ -52,49 +77,88 @@ func (dbr *DBResource) GetUserByUserID(userID int64) (model.User, error) {
 
 	var user UserDB
 	err := dbr.db.Get(&user, query, userID)
-	if err != nil {
+	if err == sql.ErrNoRows {
 		return model.User{}, nil
 	}
+	if err != nil {
+		return model.User{}, err
+	}
 
-	return model.User{
-		UserID:     user.UserID.Int64,
-		Username:   user.UserName.String,
-		Password:   user.Password.String,
-		Salt:       user.Salt.String,
-		CreatedAt:  user.CreatedAt,
-		ProfilePic: user.ProfilePic.String,
-	}, nil
+	return userFromDB(user), nil
 }
 
 func (dbr *DBResource) GetUserByUserName(userName string) (model.User, error) {
 	query := `
-	SELECT 
-		user_id,
-		username,
-		password,
-		salt,
-		created_at,
-		profile_pic
+	SELECT
+		` + userColumns + `
 	FROM
 		account
 	WHERE
-		username = $1
+		lower(username) = lower($1)
 	`
 
 	var user UserDB
 	err := dbr.db.Get(&user, query, userName)
+	if err == sql.ErrNoRows {
+		return model.User{}, nil
+	}
 	if err != nil {
+		return model.User{}, err
+	}
+
+	return userFromDB(user), nil
+}
+
+func (dbr *DBResource) GetUserByEmail(email string) (model.User, error) {
+	query := `
+	SELECT
+		` + userColumns + `
+	FROM
+		account
+	WHERE
+		lower(email) = lower($1)
+	`
+
+	var user UserDB
+	err := dbr.db.Get(&user, query, email)
+	if err == sql.ErrNoRows {
 		return model.User{}, nil
 	}
+	if err != nil {
+		return model.User{}, err
+	}
+
+	return userFromDB(user), nil
+}
+
+// GetUsersByIDs returns the users that exist among userIDs, in no
+// particular order.
+func (dbr *DBResource) GetUsersByIDs(userIDs []int64) ([]model.User, error) {
+	if len(userIDs) == 0 {
+		return []model.User{}, nil
+	}
+
+	query := `
+	SELECT
+		` + userColumns + `
+	FROM
+		account
+	WHERE
+		user_id = ANY($1)
+	`
+
+	var usersDB []UserDB
+	err := dbr.db.Select(&usersDB, query, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]model.User, len(usersDB))
+	for i, user := range usersDB {
+		users[i] = userFromDB(user)
+	}
 
-	return model.User{
-		UserID:     user.UserID.Int64,
-		Username:   user.UserName.String,
-		Password:   user.Password.String,
-		Salt:       user.Salt.String,
-		CreatedAt:  user.CreatedAt,
-		ProfilePic: user.ProfilePic.String,
-	}, nil
+	return users, nil
 }
 
 func (dbr *DBResource) UpdateUserProfpic(userID int64, newProfpic string) error {
@@ -120,7 +184,8 @@ func (dbr *DBResource) UpdateUserPassword(userID int64, newPassword string) erro
 		UPDATE
 			account
 		SET 
-		    password = $1
+		    password = $1,
+		    salt = ''
 		WHERE
 			user_id = $2
 	`
@@ -133,20 +198,191 @@ func (dbr *DBResource) UpdateUserPassword(userID int64, newPassword string) erro
 	return nil
 }
 
+// UpdateUserName renames the user and records the old name in
+// username_history, so it can be held back from other users for a while.
 func (dbr *DBResource) UpdateUserName(userID int64, newUsername string) error {
+	tx, err := dbr.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	query := `
+		INSERT INTO
+			username_history
+		(
+			user_id,
+			username,
+			changed_at
+		)
+		SELECT
+			user_id,
+			username,
+			$1
+		FROM
+			account
+		WHERE
+			user_id = $2
+	`
+
+	_, err = tx.Exec(query, now, userID)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		UPDATE
+			account
+		SET 
+		    username = $1,
+		    username_changed_at = $2
+		WHERE
+			user_id = $3
+	`
+
+	_, err = tx.Exec(query, newUsername, now, userID)
+	if err != nil {
+		return uniqueErr(err)
+	}
+
+	return tx.Commit()
+}
+
+func (dbr *DBResource) GetUsernameHolder(username string, since time.Time) (int64, error) {
+	query := `
+		SELECT
+			user_id
+		FROM
+			username_history
+		WHERE
+			lower(username) = lower($1)
+		AND
+			changed_at > $2
+		ORDER BY
+			changed_at DESC
+		LIMIT 1
+	`
+
+	var userID int64
+	err := dbr.db.Get(&userID, query, username, since)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+func (dbr *DBResource) UpdateUserEmail(userID int64, email string) error {
+	query := `
+		UPDATE
+			account
+		SET 
+		    email = NULLIF($1, ''),
+		    email_verified_at = NULL
+		WHERE
+			user_id = $2
+	`
+
+	_, err := dbr.db.Exec(query, email, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// MarkEmailVerified only verifies email if it is still the address of the
+// account, so a link sent to a replaced address does nothing.
+func (dbr *DBResource) MarkEmailVerified(userID int64, email string) error {
 	query := `
 		UPDATE
 			account
 		SET 
-		    username = $1
+		    email_verified_at = $1
 		WHERE
 			user_id = $2
+		AND
+			lower(email) = lower($3)
 	`
 
-	_, err := dbr.db.Exec(query, newUsername, userID)
+	_, err := dbr.db.Exec(query, time.Now(), userID, email)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+func userFromDB(user UserDB) model.User {
+	u := model.User{
+		UserID:        user.UserID.Int64,
+		Username:      user.UserName.String,
+		Password:      user.Password.String,
+		Salt:          user.Salt.String,
+		CreatedAt:     user.CreatedAt,
+		ProfilePic:    user.ProfilePic.String,
+		Email:         user.Email.String,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		DisplayName:   user.DisplayName.String,
+		Bio:           user.Bio.String,
+		Timezone:      user.Timezone.String,
+	}
+	if user.UsernameChangedAt.Valid {
+		changedAt := user.UsernameChangedAt.Time
+		u.UsernameChangedAt = &changedAt
+	}
+	if user.DeactivatedAt.Valid {
+		deactivatedAt := user.DeactivatedAt.Time
+		u.DeactivatedAt = &deactivatedAt
+	}
+	if user.DeleteAfter.Valid {
+		deleteAfter := user.DeleteAfter.Time
+		u.DeleteAfter = &deleteAfter
+	}
+	u.Deleted = user.DeletedAt.Valid
+	u.Role = user.Role.String
+	if user.SuspendedAt.Valid {
+		suspendedAt := user.SuspendedAt.Time
+		u.SuspendedAt = &suspendedAt
+	}
+	u.PasswordResetRequired = user.PasswordResetRequired.Bool
+	u.Bot = user.IsBot.Bool
+	u.OwnerID = user.OwnerID.Int64
+	if user.StatusText.Valid || user.StatusEmoji.Valid {
+		u.Status = &model.UserStatus{
+			Text:  user.StatusText.String,
+			Emoji: user.StatusEmoji.String,
+		}
+		if user.StatusExpiresAt.Valid {
+			expiresAt := user.StatusExpiresAt.Time
+			u.Status.ExpiresAt = &expiresAt
+		}
+	}
+
+	return withDerivedFields(u)
+}
+
+// uniqueErr turns unique constraint violations into ErrDuplicate.
+func uniqueErr(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// withDerivedFields fills what is computed rather than stored, it runs on
+// users read from postgres and from the cache alike.
+func withDerivedFields(user model.User) model.User {
+	user.ProfilePicVariants = model.AvatarVariantURLs(user.ProfilePic)
+	if user.Role == "" {
+		user.Role = model.RoleUser
+	}
+	if user.Status != nil && user.Status.Expired(time.Now()) {
+		user.Status = nil
+	}
+	return user
+}
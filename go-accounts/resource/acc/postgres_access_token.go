@@ -0,0 +1,265 @@
+package acc
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+func (dbr *DBResource) CreateBot(username string, ownerID int64, createdAt time.Time) (int64, error) {
+	query := `
+		INSERT INTO
+			account
+		(
+			username,
+			password,
+			salt,
+			created_at,
+			profile_pic,
+			is_bot,
+			owner_id
+		)
+		VALUES
+		(
+			$1,
+			'',
+			'',
+			$2,
+			'',
+			TRUE,
+			$3
+		)
+		RETURNING
+			user_id
+	`
+
+	var userID int64
+	err := dbr.db.Get(&userID, query, username, createdAt, ownerID)
+	if err != nil {
+		return 0, uniqueErr(err)
+	}
+
+	return userID, nil
+}
+
+func (dbr *DBResource) GetBots(ownerID int64) ([]model.User, error) {
+	query := `
+	SELECT
+		` + userColumns + `
+	FROM
+		account
+	WHERE
+		owner_id = $1
+	AND
+		is_bot
+	AND
+		deleted_at IS NULL
+	ORDER BY
+		user_id
+	`
+
+	var rows []UserDB
+	err := dbr.db.Select(&rows, query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]model.User, len(rows))
+	for i, row := range rows {
+		users[i] = userFromDB(row)
+	}
+
+	return users, nil
+}
+
+func (dbr *DBResource) CreateAccessToken(token model.PersonalAccessToken) error {
+	query := `
+		INSERT INTO
+			personal_access_token
+		(
+			token_id,
+			user_id,
+			created_by,
+			name,
+			token_hash,
+			scopes,
+			created_at,
+			expires_at
+		)
+		VALUES
+		(
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8
+		)
+	`
+
+	_, err := dbr.db.Exec(query, token.TokenID, token.UserID, token.CreatedBy, token.Name, token.TokenHash,
+		strings.Join(token.Scopes, " "), token.CreatedAt, token.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+const accessTokenColumns = `
+		token_id,
+		user_id,
+		created_by,
+		name,
+		token_hash,
+		scopes,
+		created_at,
+		expires_at,
+		last_used_at,
+		revoked_at`
+
+func (dbr *DBResource) GetAccessTokenByHash(tokenHash string) (model.PersonalAccessToken, error) {
+	query := `
+	SELECT
+		` + accessTokenColumns + `
+	FROM
+		personal_access_token
+	WHERE
+		token_hash = $1
+	`
+
+	var row PersonalAccessTokenDB
+	err := dbr.db.Get(&row, query, tokenHash)
+	if err == sql.ErrNoRows {
+		return model.PersonalAccessToken{}, nil
+	}
+	if err != nil {
+		return model.PersonalAccessToken{}, err
+	}
+
+	return accessTokenFromDB(row), nil
+}
+
+func (dbr *DBResource) GetAccessTokens(userID int64) ([]model.PersonalAccessToken, error) {
+	query := `
+	SELECT
+		` + accessTokenColumns + `
+	FROM
+		personal_access_token
+	WHERE
+		user_id = $1
+	AND
+		revoked_at IS NULL
+	ORDER BY
+		created_at DESC
+	`
+
+	var rows []PersonalAccessTokenDB
+	err := dbr.db.Select(&rows, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]model.PersonalAccessToken, len(rows))
+	for i, row := range rows {
+		tokens[i] = accessTokenFromDB(row)
+	}
+
+	return tokens, nil
+}
+
+func (dbr *DBResource) RevokeAccessToken(userID int64, tokenID string, revokedAt time.Time) (bool, error) {
+	query := `
+		UPDATE
+			personal_access_token
+		SET
+			revoked_at = $3
+		WHERE
+			user_id = $1
+		AND
+			token_id = $2
+		AND
+			revoked_at IS NULL
+	`
+
+	res, err := dbr.db.Exec(query, userID, tokenID, revokedAt)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (dbr *DBResource) RevokeAccessTokens(userID int64, revokedAt time.Time) error {
+	query := `
+		UPDATE
+			personal_access_token
+		SET
+			revoked_at = $2
+		WHERE
+			user_id = $1
+		AND
+			revoked_at IS NULL
+	`
+
+	_, err := dbr.db.Exec(query, userID, revokedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (dbr *DBResource) TouchAccessToken(tokenID string, usedAt time.Time, interval time.Duration) error {
+	query := `
+		UPDATE
+			personal_access_token
+		SET
+			last_used_at = $2
+		WHERE
+			token_id = $1
+		AND
+			(last_used_at IS NULL OR last_used_at < $3)
+	`
+
+	_, err := dbr.db.Exec(query, tokenID, usedAt, usedAt.Add(-interval))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func accessTokenFromDB(row PersonalAccessTokenDB) model.PersonalAccessToken {
+	token := model.PersonalAccessToken{
+		TokenID:   row.TokenID,
+		UserID:    row.UserID,
+		CreatedBy: row.CreatedBy,
+		Name:      row.Name,
+		TokenHash: row.TokenHash,
+		Scopes:    strings.Fields(row.Scopes),
+		CreatedAt: row.CreatedAt,
+	}
+	if row.ExpiresAt.Valid {
+		expiresAt := row.ExpiresAt.Time
+		token.ExpiresAt = &expiresAt
+	}
+	if row.LastUsedAt.Valid {
+		lastUsedAt := row.LastUsedAt.Time
+		token.LastUsedAt = &lastUsedAt
+	}
+	if row.RevokedAt.Valid {
+		revokedAt := row.RevokedAt.Time
+		token.RevokedAt = &revokedAt
+	}
+	return token
+}
@@ -0,0 +1,79 @@
+package acc
+
+import (
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+func (dbr *DBResource) CreateAccountToken(token model.AccountToken) error {
+	query := `
+		INSERT INTO
+			account_token
+		(
+			token_hash,
+			user_id,
+			purpose,
+			email,
+			created_at,
+			expires_at
+		)
+		VALUES
+		(
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6
+		)
+	`
+
+	_, err := dbr.db.Exec(query, token.TokenHash, token.UserID, token.Purpose, token.Email, token.CreatedAt, token.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UseAccountToken consumes an unexpired, unused token of the given purpose
+// and returns it. Consuming is atomic so a token works exactly once.
+func (dbr *DBResource) UseAccountToken(tokenHash string, purpose string, usedAt time.Time) (model.AccountToken, error) {
+	query := `
+		UPDATE
+			account_token
+		SET
+			used_at = $1
+		WHERE
+			token_hash = $2
+		AND
+			purpose = $3
+		AND
+			used_at IS NULL
+		AND
+			expires_at > $1
+		RETURNING
+			token_hash,
+			user_id,
+			purpose,
+			email,
+			created_at,
+			expires_at
+	`
+
+	var token AccountTokenDB
+	err := dbr.db.Get(&token, query, usedAt, tokenHash, purpose)
+	if err != nil {
+		return model.AccountToken{}, err
+	}
+
+	return model.AccountToken{
+		TokenHash: token.TokenHash.String,
+		UserID:    token.UserID.Int64,
+		Purpose:   token.Purpose.String,
+		Email:     token.Email.String,
+		CreatedAt: token.CreatedAt,
+		ExpiresAt: token.ExpiresAt,
+	}, nil
+}
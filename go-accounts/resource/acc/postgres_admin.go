@@ -0,0 +1,90 @@
+package acc
+
+import (
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+func (dbr *DBResource) ListUsers(role string, afterUserID int64, limit int) ([]model.User, error) {
+	query := `
+	SELECT
+		` + userColumns + `
+	FROM
+		account
+	WHERE
+		user_id > $1
+	AND
+		($2 = '' OR role = $2)
+	ORDER BY
+		user_id
+	LIMIT $3
+	`
+
+	var rows []UserDB
+	err := dbr.db.Select(&rows, query, afterUserID, role, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]model.User, len(rows))
+	for i, row := range rows {
+		users[i] = userFromDB(row)
+	}
+
+	return users, nil
+}
+
+func (dbr *DBResource) UpdateUserRole(userID int64, role string) error {
+	query := `
+		UPDATE
+			account
+		SET
+			role = $1
+		WHERE
+			user_id = $2
+	`
+
+	_, err := dbr.db.Exec(query, role, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (dbr *DBResource) SuspendUser(userID int64, suspendedAt *time.Time) error {
+	query := `
+		UPDATE
+			account
+		SET
+			suspended_at = $1
+		WHERE
+			user_id = $2
+	`
+
+	_, err := dbr.db.Exec(query, suspendedAt, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (dbr *DBResource) SetPasswordResetRequired(userID int64, required bool) error {
+	query := `
+		UPDATE
+			account
+		SET
+			password_reset_required = $1
+		WHERE
+			user_id = $2
+	`
+
+	_, err := dbr.db.Exec(query, required, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
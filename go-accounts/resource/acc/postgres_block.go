@@ -0,0 +1,118 @@
+package acc
+
+import (
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+// BlockUser records that userID blocked blockedUserID. Blocking twice keeps
+// the first block.
+func (dbr *DBResource) BlockUser(userID, blockedUserID int64, blockedAt time.Time) error {
+	query := `
+		INSERT INTO
+			user_block
+		(
+			user_id,
+			blocked_user_id,
+			created_at
+		)
+		VALUES
+		(
+			$1,
+			$2,
+			$3
+		)
+		ON CONFLICT (user_id, blocked_user_id) DO NOTHING
+	`
+
+	_, err := dbr.db.Exec(query, userID, blockedUserID, blockedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (dbr *DBResource) UnblockUser(userID, blockedUserID int64) error {
+	query := `
+		DELETE
+		FROM
+			user_block
+		WHERE
+			user_id = $1
+		AND
+			blocked_user_id = $2
+	`
+
+	_, err := dbr.db.Exec(query, userID, blockedUserID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetBlockedUsers returns the users blocked by userID, most recent first.
+func (dbr *DBResource) GetBlockedUsers(userID int64) ([]model.BlockedUser, error) {
+	query := `
+	SELECT
+		` + userColumns + `,
+		b.blocked_at
+	FROM
+		account
+	INNER JOIN
+	(
+		SELECT
+			blocked_user_id,
+			created_at AS blocked_at
+		FROM
+			user_block
+		WHERE
+			user_id = $1
+	) b
+	ON
+		account.user_id = b.blocked_user_id
+	ORDER BY
+		b.blocked_at DESC
+	`
+
+	var rows []BlockedUserDB
+	err := dbr.db.Select(&rows, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	blocked := make([]model.BlockedUser, len(rows))
+	for i, row := range rows {
+		blocked[i] = model.BlockedUser{
+			User:      userFromDB(row.UserDB),
+			BlockedAt: row.BlockedAt,
+		}
+	}
+
+	return blocked, nil
+}
+
+func (dbr *DBResource) IsBlocked(userID, otherUserID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT
+				1
+			FROM
+				user_block
+			WHERE
+				(user_id = $1 AND blocked_user_id = $2)
+			OR
+				(user_id = $2 AND blocked_user_id = $1)
+		)
+	`
+
+	var blocked bool
+	err := dbr.db.Get(&blocked, query, userID, otherUserID)
+	if err != nil {
+		return false, err
+	}
+
+	return blocked, nil
+}
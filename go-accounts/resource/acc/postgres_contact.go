@@ -0,0 +1,305 @@
+package acc
+
+import (
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+func (dbr *DBResource) CreateContactRequest(requesterID, addresseeID int64, createdAt time.Time) (bool, error) {
+	query := `
+		INSERT INTO
+			contact_request
+		(
+			requester_id,
+			addressee_id,
+			created_at
+		)
+		VALUES
+		(
+			$1,
+			$2,
+			$3
+		)
+		ON CONFLICT (requester_id, addressee_id) DO NOTHING
+	`
+
+	res, err := dbr.db.Exec(query, requesterID, addresseeID, createdAt)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (dbr *DBResource) DeleteContactRequest(requesterID, addresseeID int64) (bool, error) {
+	query := `
+		DELETE
+		FROM
+			contact_request
+		WHERE
+			requester_id = $1
+		AND
+			addressee_id = $2
+	`
+
+	res, err := dbr.db.Exec(query, requesterID, addresseeID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (dbr *DBResource) AcceptContactRequest(requesterID, addresseeID int64, acceptedAt time.Time) (bool, error) {
+	tx, err := dbr.db.Beginx()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM contact_request WHERE requester_id = $1 AND addressee_id = $2`, requesterID, addresseeID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	query := `
+		INSERT INTO
+			contact
+		(
+			user_id,
+			contact_user_id,
+			created_at
+		)
+		VALUES
+			($1, $2, $3),
+			($2, $1, $3)
+		ON CONFLICT (user_id, contact_user_id) DO NOTHING
+	`
+
+	_, err = tx.Exec(query, requesterID, addresseeID, acceptedAt)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (dbr *DBResource) GetContactRequests(userID int64, outgoing bool) ([]model.ContactRequest, error) {
+	// the other side of the request is joined with the account
+	self, other := "addressee_id", "requester_id"
+	if outgoing {
+		self, other = other, self
+	}
+
+	query := `
+	SELECT
+		` + userColumns + `,
+		r.requested_at
+	FROM
+		account
+	INNER JOIN
+	(
+		SELECT
+			` + other + ` AS other_user_id,
+			created_at AS requested_at
+		FROM
+			contact_request
+		WHERE
+			` + self + ` = $1
+	) r
+	ON
+		account.user_id = r.other_user_id
+	ORDER BY
+		r.requested_at DESC
+	`
+
+	var rows []ContactRequestDB
+	err := dbr.db.Select(&rows, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	requests := make([]model.ContactRequest, len(rows))
+	for i, row := range rows {
+		requests[i] = model.ContactRequest{
+			User:      userFromDB(row.UserDB),
+			CreatedAt: row.RequestedAt,
+		}
+	}
+
+	return requests, nil
+}
+
+func (dbr *DBResource) GetContacts(userID int64) ([]model.Contact, error) {
+	query := `
+	SELECT
+		` + userColumns + `,
+		c.since
+	FROM
+		account
+	INNER JOIN
+	(
+		SELECT
+			contact_user_id,
+			created_at AS since
+		FROM
+			contact
+		WHERE
+			user_id = $1
+	) c
+	ON
+		account.user_id = c.contact_user_id
+	WHERE
+		deactivated_at IS NULL
+	ORDER BY
+		username
+	`
+
+	var rows []ContactDB
+	err := dbr.db.Select(&rows, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	contacts := make([]model.Contact, len(rows))
+	for i, row := range rows {
+		contacts[i] = model.Contact{
+			User:  userFromDB(row.UserDB),
+			Since: row.Since,
+		}
+	}
+
+	return contacts, nil
+}
+
+func (dbr *DBResource) IsContact(userID, otherUserID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT
+				1
+			FROM
+				contact
+			WHERE
+				user_id = $1
+			AND
+				contact_user_id = $2
+		)
+	`
+
+	var isContact bool
+	err := dbr.db.Get(&isContact, query, userID, otherUserID)
+	if err != nil {
+		return false, err
+	}
+
+	return isContact, nil
+}
+
+func (dbr *DBResource) GetMutualContacts(userID, otherUserID int64) ([]model.User, error) {
+	query := `
+	SELECT
+		` + userColumns + `
+	FROM
+		account
+	WHERE
+		user_id IN (
+			SELECT
+				a.contact_user_id
+			FROM
+				contact a
+			INNER JOIN
+				contact b
+			ON
+				a.contact_user_id = b.contact_user_id
+			WHERE
+				a.user_id = $1
+			AND
+				b.user_id = $2
+		)
+	AND
+		deactivated_at IS NULL
+	ORDER BY
+		username
+	`
+
+	var rows []UserDB
+	err := dbr.db.Select(&rows, query, userID, otherUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]model.User, len(rows))
+	for i, row := range rows {
+		users[i] = userFromDB(row)
+	}
+
+	return users, nil
+}
+
+func (dbr *DBResource) RemoveContact(userID, otherUserID int64) (bool, error) {
+	tx, err := dbr.db.Beginx()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		DELETE
+		FROM
+			contact
+		WHERE
+			(user_id = $1 AND contact_user_id = $2)
+		OR
+			(user_id = $2 AND contact_user_id = $1)
+	`
+
+	contacts, err := tx.Exec(query, userID, otherUserID)
+	if err != nil {
+		return false, err
+	}
+
+	query = `
+		DELETE
+		FROM
+			contact_request
+		WHERE
+			(requester_id = $1 AND addressee_id = $2)
+		OR
+			(requester_id = $2 AND addressee_id = $1)
+	`
+
+	requests, err := tx.Exec(query, userID, otherUserID)
+	if err != nil {
+		return false, err
+	}
+
+	removedContacts, err := contacts.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	removedRequests, err := requests.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return removedContacts+removedRequests > 0, tx.Commit()
+}
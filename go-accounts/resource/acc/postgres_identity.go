@@ -0,0 +1,117 @@
+package acc
+
+import (
+	"database/sql"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+func (dbr *DBResource) GetUserIDByIdentity(provider, subject string) (int64, error) {
+	query := `
+		SELECT
+			user_id
+		FROM
+			account_identity
+		WHERE
+			provider = $1
+		AND
+			subject = $2
+	`
+
+	var userID int64
+	err := dbr.db.Get(&userID, query, provider, subject)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// CreateIdentity links an external identity, ErrDuplicate means it already
+// belongs to an account or the account has one from the same provider.
+func (dbr *DBResource) CreateIdentity(identity model.Identity) error {
+	query := `
+		INSERT INTO
+			account_identity
+		(
+			provider,
+			subject,
+			user_id,
+			email,
+			created_at
+		)
+		VALUES
+		(
+			$1,
+			$2,
+			$3,
+			NULLIF($4, ''),
+			$5
+		)
+	`
+
+	_, err := dbr.db.Exec(query, identity.Provider, identity.Subject, identity.UserID, identity.Email, identity.CreatedAt)
+	if err != nil {
+		return uniqueErr(err)
+	}
+
+	return nil
+}
+
+func (dbr *DBResource) GetIdentities(userID int64) ([]model.Identity, error) {
+	query := `
+		SELECT
+			provider,
+			subject,
+			user_id,
+			email,
+			created_at
+		FROM
+			account_identity
+		WHERE
+			user_id = $1
+		ORDER BY
+			created_at
+	`
+
+	var rows []IdentityDB
+	err := dbr.db.Select(&rows, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	identities := make([]model.Identity, len(rows))
+	for i, row := range rows {
+		identities[i] = model.Identity{
+			Provider:  row.Provider,
+			Subject:   row.Subject,
+			UserID:    row.UserID,
+			Email:     row.Email.String,
+			CreatedAt: row.CreatedAt,
+		}
+	}
+
+	return identities, nil
+}
+
+func (dbr *DBResource) DeleteIdentity(userID int64, provider string) error {
+	query := `
+		DELETE
+		FROM
+			account_identity
+		WHERE
+			user_id = $1
+		AND
+			provider = $2
+	`
+
+	_, err := dbr.db.Exec(query, userID, provider)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package acc
+
+import (
+	"time"
+)
+
+// DeactivateUser hides the account. deleteAfter schedules its deletion,
+// nil only deactivates it.
+func (dbr *DBResource) DeactivateUser(userID int64, deactivatedAt time.Time, deleteAfter *time.Time) error {
+	query := `
+		UPDATE
+			account
+		SET
+			deactivated_at = $1,
+			delete_after = $2
+		WHERE
+			user_id = $3
+		AND
+			deleted_at IS NULL
+	`
+
+	_, err := dbr.db.Exec(query, deactivatedAt, deleteAfter, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReactivateUser brings a deactivated account back and cancels a pending
+// deletion. Accounts already anonymized stay deleted.
+func (dbr *DBResource) ReactivateUser(userID int64) error {
+	query := `
+		UPDATE
+			account
+		SET
+			deactivated_at = NULL,
+			delete_after = NULL
+		WHERE
+			user_id = $1
+		AND
+			deleted_at IS NULL
+	`
+
+	_, err := dbr.db.Exec(query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (dbr *DBResource) GetUsersDueForDeletion(now time.Time, limit int) ([]int64, error) {
+	query := `
+		SELECT
+			user_id
+		FROM
+			account
+		WHERE
+			delete_after <= $1
+		AND
+			deleted_at IS NULL
+		ORDER BY
+			delete_after
+		LIMIT $2
+	`
+
+	userIDs := []int64{}
+	err := dbr.db.Select(&userIDs, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+// AnonymizeUser strips every piece of personal data from the account and
+// drops its credentials. The row itself stays so chat history in other
+// services keeps a valid author, shown as "deleted-<user_id>", a name
+// the username policy does not allow anyone to register. Every service in
+// services gets a pending account_purge row in the same transaction.
+func (dbr *DBResource) AnonymizeUser(userID int64, deletedAt time.Time, services []string) error {
+	tx, err := dbr.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE
+			account
+		SET
+			username = 'deleted-' || user_id,
+			password = '',
+			salt = '',
+			profile_pic = '',
+			email = NULL,
+			email_verified_at = NULL,
+			display_name = NULL,
+			bio = NULL,
+			timezone = NULL,
+			status_text = NULL,
+			status_emoji = NULL,
+			status_expires_at = NULL,
+			delete_after = NULL,
+			deleted_at = $1
+		WHERE
+			user_id = $2
+		AND
+			deleted_at IS NULL
+	`
+
+	_, err = tx.Exec(query, deletedAt, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range []string{"session", "refresh_token", "account_totp", "recovery_code", "account_token", "username_history", "account_identity", "personal_access_token"} {
+		_, err = tx.Exec(`DELETE FROM `+table+` WHERE user_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`DELETE FROM user_block WHERE user_id = $1 OR blocked_user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM contact WHERE user_id = $1 OR contact_user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM contact_request WHERE requester_id = $1 OR addressee_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, service := range services {
+		_, err = tx.Exec(`
+			INSERT INTO account_purge
+				(user_id, service, created_at, last_attempt_at)
+			VALUES
+				($1, $2, $3, $3)
+			ON CONFLICT (user_id, service) DO NOTHING
+		`, userID, service, deletedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetPendingPurges returns the users with a service that has not confirmed
+// their deletion and was last asked before attemptedBefore.
+func (dbr *DBResource) GetPendingPurges(attemptedBefore time.Time, limit int) ([]int64, error) {
+	query := `
+		SELECT
+			user_id
+		FROM
+			account_purge
+		WHERE
+			confirmed_at IS NULL
+		AND
+			last_attempt_at < $1
+		GROUP BY
+			user_id
+		ORDER BY
+			MIN(last_attempt_at)
+		LIMIT $2
+	`
+
+	userIDs := []int64{}
+	err := dbr.db.Select(&userIDs, query, attemptedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+func (dbr *DBResource) MarkPurgeAttempted(userID int64, attemptedAt time.Time) error {
+	query := `
+		UPDATE
+			account_purge
+		SET
+			last_attempt_at = $1,
+			attempts = attempts + 1
+		WHERE
+			user_id = $2
+		AND
+			confirmed_at IS NULL
+	`
+
+	_, err := dbr.db.Exec(query, attemptedAt, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (dbr *DBResource) ConfirmPurge(userID int64, service string, confirmedAt time.Time) error {
+	query := `
+		UPDATE
+			account_purge
+		SET
+			confirmed_at = $1
+		WHERE
+			user_id = $2
+		AND
+			service = $3
+		AND
+			confirmed_at IS NULL
+	`
+
+	_, err := dbr.db.Exec(query, confirmedAt, userID, service)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package acc
+
+import (
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+func (dbr *DBResource) UpdateUserProfile(userID int64, update model.ProfileUpdate) error {
+	query := `
+		UPDATE
+			account
+		SET
+			display_name = COALESCE($1, display_name),
+			bio = COALESCE($2, bio),
+			timezone = COALESCE($3, timezone)
+		WHERE
+			user_id = $4
+	`
+
+	_, err := dbr.db.Exec(query, update.DisplayName, update.Bio, update.Timezone, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateUserStatus replaces the status, a nil status clears it.
+func (dbr *DBResource) UpdateUserStatus(userID int64, status *model.UserStatus) error {
+	query := `
+		UPDATE
+			account
+		SET
+			status_text = $1,
+			status_emoji = $2,
+			status_expires_at = $3
+		WHERE
+			user_id = $4
+	`
+
+	var text, emoji *string
+	var expiresAt *time.Time
+	if status != nil {
+		text, emoji, expiresAt = &status.Text, &status.Emoji, status.ExpiresAt
+	}
+
+	_, err := dbr.db.Exec(query, text, emoji, expiresAt, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
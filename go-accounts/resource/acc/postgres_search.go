@@ -0,0 +1,87 @@
+package acc
+
+import (
+	"strings"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchUsers matches query against the start of usernames and display
+// names first, then by trigram similarity, and returns up to limit users
+// after cursor. A zero cursor starts from the first page.
+func (dbr *DBResource) SearchUsers(viewerID int64, query string, cursor model.UserSearchCursor, limit int) ([]model.UserSearchHit, error) {
+	sqlQuery := `
+	SELECT
+		*
+	FROM
+	(
+		SELECT
+			` + userColumns + `,
+			CASE
+				WHEN lower(username) LIKE $1 OR lower(COALESCE(display_name, '')) LIKE $1 THEN 0
+				ELSE 1
+			END AS search_rank,
+			GREATEST(
+				similarity(lower(username), $2),
+				similarity(lower(COALESCE(display_name, '')), $2)
+			)::float8 AS search_score
+		FROM
+			account
+		WHERE
+			deactivated_at IS NULL
+		AND
+			deleted_at IS NULL
+		AND NOT EXISTS (
+			SELECT
+				1
+			FROM
+				user_block b
+			WHERE
+				(b.user_id = $7 AND b.blocked_user_id = account.user_id)
+			OR
+				(b.user_id = account.user_id AND b.blocked_user_id = $7)
+		)
+		AND
+		(
+			lower(username) LIKE $1
+		OR
+			lower(COALESCE(display_name, '')) LIKE $1
+		OR
+			lower(username) % $2
+		OR
+			lower(COALESCE(display_name, '')) % $2
+		)
+	) matches
+	WHERE
+		(search_rank, -search_score, user_id) > ($3, -$4::float8, $5)
+	ORDER BY
+		search_rank,
+		search_score DESC,
+		user_id
+	LIMIT $6
+	`
+
+	if cursor.UserID == 0 {
+		cursor.Rank = -1
+	}
+
+	query = strings.ToLower(query)
+	var rows []UserSearchDB
+	err := dbr.db.Select(&rows, sqlQuery, likeEscaper.Replace(query)+"%", query, cursor.Rank, cursor.Score, cursor.UserID, limit, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]model.UserSearchHit, len(rows))
+	for i, row := range rows {
+		hits[i] = model.UserSearchHit{
+			User:  userFromDB(row.UserDB),
+			Rank:  row.SearchRank,
+			Score: row.SearchScore,
+		}
+	}
+
+	return hits, nil
+}
@@ -0,0 +1,180 @@
+package acc
+
+import (
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+func (dbr *DBResource) CreateSession(session model.Session) error {
+	query := `
+		INSERT INTO
+			session
+		(
+			session_id,
+			user_id,
+			user_agent,
+			ip_address,
+			created_at,
+			last_seen_at
+		)
+		VALUES
+		(
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6
+		)
+	`
+
+	_, err := dbr.db.Exec(query, session.SessionID, session.UserID, session.UserAgent, session.IPAddress, session.CreatedAt, session.LastSeenAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (dbr *DBResource) GetSession(sessionID string) (model.Session, error) {
+	query := `
+	SELECT
+		session_id,
+		user_id,
+		user_agent,
+		ip_address,
+		created_at,
+		last_seen_at,
+		revoked_at
+	FROM
+		session
+	WHERE
+		session_id = $1
+	`
+
+	var session SessionDB
+	err := dbr.db.Get(&session, query, sessionID)
+	if err != nil {
+		return model.Session{}, err
+	}
+
+	return sessionFromDB(session), nil
+}
+
+func (dbr *DBResource) GetSessionsByUserID(userID int64) ([]model.Session, error) {
+	query := `
+	SELECT
+		session_id,
+		user_id,
+		user_agent,
+		ip_address,
+		created_at,
+		last_seen_at,
+		revoked_at
+	FROM
+		session
+	WHERE
+		user_id = $1
+	AND
+		revoked_at IS NULL
+	ORDER BY
+		last_seen_at DESC
+	`
+
+	rows, err := dbr.db.Queryx(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []model.Session
+	for rows.Next() {
+		var s SessionDB
+		err = rows.StructScan(&s)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, sessionFromDB(s))
+	}
+
+	return sessions, rows.Err()
+}
+
+func (dbr *DBResource) TouchSession(sessionID string, ipAddress string, seenAt time.Time) error {
+	query := `
+		UPDATE
+			session
+		SET
+			last_seen_at = $1,
+			ip_address = COALESCE(NULLIF($2, ''), ip_address)
+		WHERE
+			session_id = $3
+	`
+
+	_, err := dbr.db.Exec(query, seenAt, ipAddress, sessionID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (dbr *DBResource) RevokeSession(sessionID string) error {
+	query := `
+		UPDATE
+			session
+		SET
+			revoked_at = $1
+		WHERE
+			session_id = $2
+		AND
+			revoked_at IS NULL
+	`
+
+	_, err := dbr.db.Exec(query, time.Now(), sessionID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (dbr *DBResource) IsSessionRevoked(sessionID string) (bool, error) {
+	query := `
+	SELECT EXISTS (
+		SELECT
+			1
+		FROM
+			session
+		WHERE
+			session_id = $1
+		AND
+			revoked_at IS NOT NULL
+	)
+	`
+
+	var revoked bool
+	err := dbr.db.Get(&revoked, query, sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+func sessionFromDB(s SessionDB) model.Session {
+	session := model.Session{
+		SessionID:  s.SessionID.String,
+		UserID:     s.UserID.Int64,
+		UserAgent:  s.UserAgent.String,
+		IPAddress:  s.IPAddress.String,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+	}
+	if s.RevokedAt.Valid {
+		revokedAt := s.RevokedAt.Time
+		session.RevokedAt = &revokedAt
+	}
+	return session
+}
@@ -0,0 +1,150 @@
+package acc
+
+import (
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+func (dbr *DBResource) CreateRefreshToken(token model.RefreshToken) error {
+	query := `
+		INSERT INTO
+			refresh_token
+		(
+			token_hash,
+			family_id,
+			user_id,
+			created_at,
+			expires_at
+		)
+		VALUES
+		(
+			$1,
+			$2,
+			$3,
+			$4,
+			$5
+		)
+	`
+
+	_, err := dbr.db.Exec(query, token.TokenHash, token.FamilyID, token.UserID, token.CreatedAt, token.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (dbr *DBResource) GetRefreshToken(tokenHash string) (model.RefreshToken, error) {
+	query := `
+	SELECT
+		token_hash,
+		family_id,
+		user_id,
+		created_at,
+		expires_at,
+		used_at,
+		revoked_at
+	FROM
+		refresh_token
+	WHERE
+		token_hash = $1
+	`
+
+	var token RefreshTokenDB
+	err := dbr.db.Get(&token, query, tokenHash)
+	if err != nil {
+		return model.RefreshToken{}, err
+	}
+
+	return refreshTokenFromDB(token), nil
+}
+
+func (dbr *DBResource) MarkRefreshTokenUsed(tokenHash string, usedAt time.Time) (bool, error) {
+	query := `
+		UPDATE
+			refresh_token
+		SET
+			used_at = $1
+		WHERE
+			token_hash = $2
+		AND
+			used_at IS NULL
+		AND
+			revoked_at IS NULL
+	`
+
+	res, err := dbr.db.Exec(query, usedAt, tokenHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (dbr *DBResource) RevokeTokenFamily(familyID string) error {
+	query := `
+		UPDATE
+			refresh_token
+		SET
+			revoked_at = $1
+		WHERE
+			family_id = $2
+		AND
+			revoked_at IS NULL
+	`
+
+	_, err := dbr.db.Exec(query, time.Now(), familyID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (dbr *DBResource) IsTokenFamilyRevoked(familyID string) (bool, error) {
+	query := `
+	SELECT EXISTS (
+		SELECT
+			1
+		FROM
+			refresh_token
+		WHERE
+			family_id = $1
+		AND
+			revoked_at IS NOT NULL
+	)
+	`
+
+	var revoked bool
+	err := dbr.db.Get(&revoked, query, familyID)
+	if err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+func refreshTokenFromDB(token RefreshTokenDB) model.RefreshToken {
+	result := model.RefreshToken{
+		TokenHash: token.TokenHash.String,
+		FamilyID:  token.FamilyID.String,
+		UserID:    token.UserID.Int64,
+		CreatedAt: token.CreatedAt,
+		ExpiresAt: token.ExpiresAt,
+	}
+	if token.UsedAt.Valid {
+		usedAt := token.UsedAt.Time
+		result.UsedAt = &usedAt
+	}
+	if token.RevokedAt.Valid {
+		revokedAt := token.RevokedAt.Time
+		result.RevokedAt = &revokedAt
+	}
+	return result
+}
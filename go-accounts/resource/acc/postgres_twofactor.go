@@ -0,0 +1,205 @@
+package acc
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+func (dbr *DBResource) SaveTOTPSecret(userID int64, secret string) error {
+	query := `
+		INSERT INTO
+			account_totp
+		(
+			user_id,
+			secret,
+			enabled,
+			last_used_step,
+			created_at
+		)
+		VALUES
+		(
+			$1,
+			$2,
+			false,
+			0,
+			$3
+		)
+		ON CONFLICT (user_id) DO UPDATE SET
+			secret = EXCLUDED.secret,
+			enabled = false,
+			last_used_step = 0,
+			created_at = EXCLUDED.created_at
+	`
+
+	_, err := dbr.db.Exec(query, userID, secret, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (dbr *DBResource) GetTOTP(userID int64) (model.TOTP, error) {
+	query := `
+	SELECT
+		user_id,
+		secret,
+		enabled,
+		last_used_step,
+		created_at
+	FROM
+		account_totp
+	WHERE
+		user_id = $1
+	`
+
+	var totp TOTPDB
+	err := dbr.db.Get(&totp, query, userID)
+	if err == sql.ErrNoRows {
+		return model.TOTP{}, nil
+	}
+	if err != nil {
+		return model.TOTP{}, err
+	}
+
+	return model.TOTP{
+		UserID:       totp.UserID.Int64,
+		Secret:       totp.Secret.String,
+		Enabled:      totp.Enabled.Bool,
+		LastUsedStep: totp.LastUsedStep.Int64,
+		CreatedAt:    totp.CreatedAt,
+	}, nil
+}
+
+func (dbr *DBResource) EnableTOTP(userID int64) error {
+	query := `
+		UPDATE
+			account_totp
+		SET
+			enabled = true
+		WHERE
+			user_id = $1
+	`
+
+	_, err := dbr.db.Exec(query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (dbr *DBResource) DisableTOTP(userID int64) error {
+	tx, err := dbr.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM account_totp WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM recovery_code WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseTOTPStep records step as the last accepted time step. It fails when the
+// step, or a later one, was already used so a code cannot be replayed.
+func (dbr *DBResource) UseTOTPStep(userID int64, step int64) (bool, error) {
+	query := `
+		UPDATE
+			account_totp
+		SET
+			last_used_step = $1
+		WHERE
+			user_id = $2
+		AND
+			last_used_step < $1
+	`
+
+	res, err := dbr.db.Exec(query, step, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (dbr *DBResource) SaveRecoveryCodes(userID int64, codeHashes []string) error {
+	tx, err := dbr.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM recovery_code WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO
+			recovery_code
+		(
+			user_id,
+			code_hash,
+			created_at
+		)
+		VALUES
+		(
+			$1,
+			$2,
+			$3
+		)
+	`
+
+	now := time.Now()
+	for _, codeHash := range codeHashes {
+		_, err = tx.Exec(query, userID, codeHash, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (dbr *DBResource) UseRecoveryCode(userID int64, codeHash string) (bool, error) {
+	query := `
+		UPDATE
+			recovery_code
+		SET
+			used_at = $1
+		WHERE
+			user_id = $2
+		AND
+			code_hash = $3
+		AND
+			used_at IS NULL
+	`
+
+	res, err := dbr.db.Exec(query, time.Now(), userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
@@ -2,54 +2,185 @@ package acc
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/lolmourne/go-accounts/model"
 )
 
-func (dbr *RedisResource) Register(username string, password string, salt string) error {
-	return dbr.next.Register(username, password, salt)
+func (dbr *RedisResource) Register(username string, password string, salt string, email string) error {
+	err := dbr.next.Register(username, password, salt, email)
+	if err != nil {
+		return err
+	}
+
+	// the username may have been cached as unknown
+	err = dbr.rdb.Del(context.Background(), usernameKey(username)).Err()
+	if err != nil {
+		log.Println(err)
+	}
+
+	return nil
 }
 
 func (dbr *RedisResource) GetUserByUserID(userID int64) (model.User, error) {
-	val, err := dbr.rdb.Get(context.Background(), fmt.Sprintf("user:%d", userID)).Result()
-	if err != nil {
-		usr, err := dbr.next.GetUserByUserID(userID)
+	ctx := context.Background()
+	if user, ok := dbr.getCachedUser(ctx, userID); ok {
+		return user, nil
+	}
+
+	// singleflight collapses concurrent misses on a cold key into one query
+	res, err, _ := dbr.sf.Do(userKey(userID), func() (interface{}, error) {
+		user, err := dbr.next.GetUserByUserID(userID)
 		if err != nil {
-			return model.User{}, errors.New("User not found")
+			return model.User{}, err
+		}
+
+		if user.UserID == 0 {
+			dbr.cacheMiss(ctx, userKey(userID))
+		} else {
+			dbr.cacheUser(ctx, user)
 		}
-		usrJSON, err := json.Marshal(usr)
+		return user, nil
+	})
+	if err != nil {
+		log.Println(err)
+		return model.User{}, errors.New("User not found")
+	}
+
+	return res.(model.User), nil
+}
+
+func (dbr *RedisResource) GetUserByUserName(userName string) (model.User, error) {
+	ctx := context.Background()
+	if userID, ok := dbr.getCachedUserID(ctx, userName); ok {
+		if userID == 0 {
+			return model.User{}, nil
+		}
+
+		user, err := dbr.GetUserByUserID(userID)
+		// a stale index entry points to a user that has been renamed since
+		if err == nil && strings.EqualFold(user.Username, userName) {
+			return user, nil
+		}
+	}
+
+	res, err, _ := dbr.sf.Do(usernameKey(userName), func() (interface{}, error) {
+		user, err := dbr.next.GetUserByUserName(userName)
 		if err != nil {
-			return model.User{}, errors.New("Fail to marshall")
+			return model.User{}, err
 		}
-		stats := dbr.rdb.Set(context.Background(), fmt.Sprintf("user:%d", userID), usrJSON, time.Duration(0))
-		log.Println(stats.Result())
 
-		return usr, err
+		if user.UserID == 0 {
+			dbr.cacheMiss(ctx, usernameKey(userName))
+		} else {
+			dbr.cacheUser(ctx, user)
+		}
+		return user, nil
+	})
+	if err != nil {
+		log.Println(err)
+		return model.User{}, errors.New("User not found")
+	}
+
+	return res.(model.User), nil
+}
+
+// GetUsersByIDs serves what it can from the cache with a single MGET and
+// loads the rest from next in one query.
+func (dbr *RedisResource) GetUsersByIDs(userIDs []int64) ([]model.User, error) {
+	if len(userIDs) == 0 {
+		return []model.User{}, nil
+	}
+
+	ctx := context.Background()
+	users, missing := dbr.getCachedUsers(ctx, userIDs)
+	if len(missing) == 0 {
+		return users, nil
+	}
+
+	found, err := dbr.next.GetUsersByIDs(missing)
+	if err != nil {
+		return nil, err
 	}
 
-	var user model.User
-	json.Unmarshal([]byte(val), &user)
+	loaded := make(map[int64]bool, len(found))
+	for _, user := range found {
+		dbr.cacheUser(ctx, user)
+		loaded[user.UserID] = true
+		users = append(users, user)
+	}
+	for _, userID := range missing {
+		if !loaded[userID] {
+			dbr.cacheMiss(ctx, userKey(userID))
+		}
+	}
 
-	return user, nil
+	return users, nil
 }
 
-func (dbr *RedisResource) GetUserByUserName(userName string) (model.User, error) {
-	return dbr.next.GetUserByUserName(userName)
+func (dbr *RedisResource) GetUsernameHolder(username string, since time.Time) (int64, error) {
+	return dbr.next.GetUsernameHolder(username, since)
+}
+
+func (dbr *RedisResource) SearchUsers(viewerID int64, query string, cursor model.UserSearchCursor, limit int) ([]model.UserSearchHit, error) {
+	return dbr.next.SearchUsers(viewerID, query, cursor, limit)
+}
+
+func (dbr *RedisResource) GetUserByEmail(email string) (model.User, error) {
+	return dbr.next.GetUserByEmail(email)
 }
 
 func (dbr *RedisResource) UpdateUserProfpic(userID int64, newProfpic string) error {
-	return dbr.next.UpdateUserProfpic(userID, newProfpic)
+	err := dbr.next.UpdateUserProfpic(userID, newProfpic)
+	if err != nil {
+		return err
+	}
+	dbr.invalidateUser(context.Background(), userID)
+	return nil
 }
 
 func (dbr *RedisResource) UpdateUserPassword(userID int64, newPassword string) error {
-	return dbr.next.UpdateUserPassword(userID, newPassword)
+	err := dbr.next.UpdateUserPassword(userID, newPassword)
+	if err != nil {
+		return err
+	}
+	dbr.invalidateUser(context.Background(), userID)
+	return nil
 }
 
 func (dbr *RedisResource) UpdateUserName(userID int64, newUsername string) error {
-	return dbr.next.UpdateUserName(userID, newUsername)
+	ctx := context.Background()
+	oldUser, err := dbr.next.GetUserByUserID(userID)
+	if err != nil {
+		return err
+	}
+
+	err = dbr.next.UpdateUserName(userID, newUsername)
+	if err != nil {
+		return err
+	}
+	// the new name may be cached as unknown, the old one must stop resolving
+	dbr.invalidateUser(ctx, userID, oldUser.Username, newUsername)
+	return nil
+}
+
+func (dbr *RedisResource) UpdateUserEmail(userID int64, email string) error {
+	err := dbr.next.UpdateUserEmail(userID, email)
+	if err != nil {
+		return err
+	}
+	dbr.invalidateUser(context.Background(), userID)
+	return nil
+}
+
+func (dbr *RedisResource) MarkEmailVerified(userID int64, email string) error {
+	err := dbr.next.MarkEmailVerified(userID, email)
+	if err != nil {
+		return err
+	}
+	dbr.invalidateUser(context.Background(), userID)
+	return nil
 }
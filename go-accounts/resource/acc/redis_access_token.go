@@ -0,0 +1,54 @@
+package acc
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+func (dbr *RedisResource) CreateBot(username string, ownerID int64, createdAt time.Time) (int64, error) {
+	botID, err := dbr.next.CreateBot(username, ownerID, createdAt)
+	if err != nil {
+		return botID, err
+	}
+
+	// the username may have been cached as unknown
+	err = dbr.rdb.Del(context.Background(), usernameKey(username)).Err()
+	if err != nil {
+		log.Println(err)
+	}
+
+	return botID, nil
+}
+
+func (dbr *RedisResource) GetBots(ownerID int64) ([]model.User, error) {
+	return dbr.next.GetBots(ownerID)
+}
+
+func (dbr *RedisResource) CreateAccessToken(token model.PersonalAccessToken) error {
+	return dbr.next.CreateAccessToken(token)
+}
+
+// GetAccessTokenByHash is not cached, a revoked token has to stop working
+// right away.
+func (dbr *RedisResource) GetAccessTokenByHash(tokenHash string) (model.PersonalAccessToken, error) {
+	return dbr.next.GetAccessTokenByHash(tokenHash)
+}
+
+func (dbr *RedisResource) GetAccessTokens(userID int64) ([]model.PersonalAccessToken, error) {
+	return dbr.next.GetAccessTokens(userID)
+}
+
+func (dbr *RedisResource) RevokeAccessToken(userID int64, tokenID string, revokedAt time.Time) (bool, error) {
+	return dbr.next.RevokeAccessToken(userID, tokenID, revokedAt)
+}
+
+func (dbr *RedisResource) RevokeAccessTokens(userID int64, revokedAt time.Time) error {
+	return dbr.next.RevokeAccessTokens(userID, revokedAt)
+}
+
+func (dbr *RedisResource) TouchAccessToken(tokenID string, usedAt time.Time, interval time.Duration) error {
+	return dbr.next.TouchAccessToken(tokenID, usedAt, interval)
+}
@@ -0,0 +1,15 @@
+package acc
+
+import (
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+func (dbr *RedisResource) CreateAccountToken(token model.AccountToken) error {
+	return dbr.next.CreateAccountToken(token)
+}
+
+func (dbr *RedisResource) UseAccountToken(tokenHash string, purpose string, usedAt time.Time) (model.AccountToken, error) {
+	return dbr.next.UseAccountToken(tokenHash, purpose, usedAt)
+}
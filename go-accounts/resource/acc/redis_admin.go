@@ -0,0 +1,39 @@
+package acc
+
+import (
+	"context"
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+func (dbr *RedisResource) ListUsers(role string, afterUserID int64, limit int) ([]model.User, error) {
+	return dbr.next.ListUsers(role, afterUserID, limit)
+}
+
+func (dbr *RedisResource) UpdateUserRole(userID int64, role string) error {
+	err := dbr.next.UpdateUserRole(userID, role)
+	if err != nil {
+		return err
+	}
+	dbr.invalidateUser(context.Background(), userID)
+	return nil
+}
+
+func (dbr *RedisResource) SuspendUser(userID int64, suspendedAt *time.Time) error {
+	err := dbr.next.SuspendUser(userID, suspendedAt)
+	if err != nil {
+		return err
+	}
+	dbr.invalidateUser(context.Background(), userID)
+	return nil
+}
+
+func (dbr *RedisResource) SetPasswordResetRequired(userID int64, required bool) error {
+	err := dbr.next.SetPasswordResetRequired(userID, required)
+	if err != nil {
+		return err
+	}
+	dbr.invalidateUser(context.Background(), userID)
+	return nil
+}
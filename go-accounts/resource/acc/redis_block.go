@@ -0,0 +1,23 @@
+package acc
+
+import (
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+func (dbr *RedisResource) BlockUser(userID, blockedUserID int64, blockedAt time.Time) error {
+	return dbr.next.BlockUser(userID, blockedUserID, blockedAt)
+}
+
+func (dbr *RedisResource) UnblockUser(userID, blockedUserID int64) error {
+	return dbr.next.UnblockUser(userID, blockedUserID)
+}
+
+func (dbr *RedisResource) GetBlockedUsers(userID int64) ([]model.BlockedUser, error) {
+	return dbr.next.GetBlockedUsers(userID)
+}
+
+func (dbr *RedisResource) IsBlocked(userID, otherUserID int64) (bool, error) {
+	return dbr.next.IsBlocked(userID, otherUserID)
+}
@@ -0,0 +1,39 @@
+package acc
+
+import (
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+func (dbr *RedisResource) CreateContactRequest(requesterID, addresseeID int64, createdAt time.Time) (bool, error) {
+	return dbr.next.CreateContactRequest(requesterID, addresseeID, createdAt)
+}
+
+func (dbr *RedisResource) DeleteContactRequest(requesterID, addresseeID int64) (bool, error) {
+	return dbr.next.DeleteContactRequest(requesterID, addresseeID)
+}
+
+func (dbr *RedisResource) AcceptContactRequest(requesterID, addresseeID int64, acceptedAt time.Time) (bool, error) {
+	return dbr.next.AcceptContactRequest(requesterID, addresseeID, acceptedAt)
+}
+
+func (dbr *RedisResource) GetContactRequests(userID int64, outgoing bool) ([]model.ContactRequest, error) {
+	return dbr.next.GetContactRequests(userID, outgoing)
+}
+
+func (dbr *RedisResource) GetContacts(userID int64) ([]model.Contact, error) {
+	return dbr.next.GetContacts(userID)
+}
+
+func (dbr *RedisResource) IsContact(userID, otherUserID int64) (bool, error) {
+	return dbr.next.IsContact(userID, otherUserID)
+}
+
+func (dbr *RedisResource) GetMutualContacts(userID, otherUserID int64) ([]model.User, error) {
+	return dbr.next.GetMutualContacts(userID, otherUserID)
+}
+
+func (dbr *RedisResource) RemoveContact(userID, otherUserID int64) (bool, error) {
+	return dbr.next.RemoveContact(userID, otherUserID)
+}
@@ -0,0 +1,19 @@
+package acc
+
+import "github.com/lolmourne/go-accounts/model"
+
+func (dbr *RedisResource) GetUserIDByIdentity(provider, subject string) (int64, error) {
+	return dbr.next.GetUserIDByIdentity(provider, subject)
+}
+
+func (dbr *RedisResource) CreateIdentity(identity model.Identity) error {
+	return dbr.next.CreateIdentity(identity)
+}
+
+func (dbr *RedisResource) GetIdentities(userID int64) ([]model.Identity, error) {
+	return dbr.next.GetIdentities(userID)
+}
+
+func (dbr *RedisResource) DeleteIdentity(userID int64, provider string) error {
+	return dbr.next.DeleteIdentity(userID, provider)
+}
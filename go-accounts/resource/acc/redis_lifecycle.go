@@ -0,0 +1,55 @@
+package acc
+
+import (
+	"context"
+	"time"
+)
+
+func (dbr *RedisResource) DeactivateUser(userID int64, deactivatedAt time.Time, deleteAfter *time.Time) error {
+	err := dbr.next.DeactivateUser(userID, deactivatedAt, deleteAfter)
+	if err != nil {
+		return err
+	}
+	dbr.invalidateUser(context.Background(), userID)
+	return nil
+}
+
+func (dbr *RedisResource) ReactivateUser(userID int64) error {
+	err := dbr.next.ReactivateUser(userID)
+	if err != nil {
+		return err
+	}
+	dbr.invalidateUser(context.Background(), userID)
+	return nil
+}
+
+func (dbr *RedisResource) GetUsersDueForDeletion(now time.Time, limit int) ([]int64, error) {
+	return dbr.next.GetUsersDueForDeletion(now, limit)
+}
+
+func (dbr *RedisResource) AnonymizeUser(userID int64, deletedAt time.Time, services []string) error {
+	user, err := dbr.next.GetUserByUserID(userID)
+	if err != nil {
+		return err
+	}
+
+	err = dbr.next.AnonymizeUser(userID, deletedAt, services)
+	if err != nil {
+		return err
+	}
+	// the old username must stop resolving to the account
+	dbr.invalidateUser(context.Background(), userID, user.Username)
+	return nil
+}
+
+func (dbr *RedisResource) GetPendingPurges(attemptedBefore time.Time, limit int) ([]int64, error) {
+	return dbr.next.GetPendingPurges(attemptedBefore, limit)
+}
+
+func (dbr *RedisResource) MarkPurgeAttempted(userID int64, attemptedAt time.Time) error {
+	return dbr.next.MarkPurgeAttempted(userID, attemptedAt)
+}
+
+func (dbr *RedisResource) ConfirmPurge(userID int64, service string, confirmedAt time.Time) error {
+	return dbr.next.ConfirmPurge(userID, service, confirmedAt)
+}
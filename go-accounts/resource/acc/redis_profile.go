@@ -0,0 +1,25 @@
+package acc
+
+import (
+	"context"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+func (dbr *RedisResource) UpdateUserProfile(userID int64, update model.ProfileUpdate) error {
+	err := dbr.next.UpdateUserProfile(userID, update)
+	if err != nil {
+		return err
+	}
+	dbr.invalidateUser(context.Background(), userID)
+	return nil
+}
+
+func (dbr *RedisResource) UpdateUserStatus(userID int64, status *model.UserStatus) error {
+	err := dbr.next.UpdateUserStatus(userID, status)
+	if err != nil {
+		return err
+	}
+	dbr.invalidateUser(context.Background(), userID)
+	return nil
+}
@@ -0,0 +1,91 @@
+package acc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	authClient "github.com/lolmourne/go-accounts/client/userauth"
+	"github.com/lolmourne/go-accounts/model"
+)
+
+const (
+	// defaultSessionDenylistTTL is used when the access token TTL is not
+	// known, it outlives any access token issued with the default TTL.
+	defaultSessionDenylistTTL = 24 * time.Hour
+	// sessionDenylistSkew covers clock differences between services.
+	sessionDenylistSkew = time.Minute
+	// sessionActiveTTL bounds how long a session checked in postgres is
+	// cached as not revoked.
+	sessionActiveTTL = 5 * time.Minute
+
+	// sessionTouchInterval throttles last seen updates written to postgres.
+	sessionTouchInterval = time.Minute
+)
+
+func (dbr *RedisResource) CreateSession(session model.Session) error {
+	return dbr.next.CreateSession(session)
+}
+
+func (dbr *RedisResource) GetSession(sessionID string) (model.Session, error) {
+	return dbr.next.GetSession(sessionID)
+}
+
+func (dbr *RedisResource) GetSessionsByUserID(userID int64) ([]model.Session, error) {
+	return dbr.next.GetSessionsByUserID(userID)
+}
+
+func (dbr *RedisResource) TouchSession(sessionID string, ipAddress string, seenAt time.Time) error {
+	ok, err := dbr.rdb.SetNX(context.Background(), fmt.Sprintf("session_seen:%s", sessionID), "1", sessionTouchInterval).Result()
+	if err == nil && !ok {
+		return nil
+	}
+
+	return dbr.next.TouchSession(sessionID, ipAddress, seenAt)
+}
+
+func (dbr *RedisResource) RevokeSession(sessionID string) error {
+	err := dbr.next.RevokeSession(sessionID)
+	if err != nil {
+		return err
+	}
+
+	err = dbr.rdb.Set(context.Background(), authClient.SessionDenylistKey(sessionID), "1", dbr.sessionDenylistTTL).Err()
+	if err != nil {
+		log.Println(err)
+	}
+	return nil
+}
+
+// IsSessionRevoked consults the denylist and falls back to postgres when the
+// session is not in it, so a flushed redis does not bring revoked sessions
+// back. The answer from postgres is cached; a session found active is only
+// cached when no revocation was written in the meantime.
+func (dbr *RedisResource) IsSessionRevoked(sessionID string) (bool, error) {
+	ctx := context.Background()
+	val, err := dbr.rdb.Get(ctx, authClient.SessionDenylistKey(sessionID)).Result()
+	if err == nil {
+		return val == "1", nil
+	}
+	if err != redis.Nil {
+		log.Println(err)
+		return dbr.next.IsSessionRevoked(sessionID)
+	}
+
+	revoked, err := dbr.next.IsSessionRevoked(sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	if revoked {
+		err = dbr.rdb.Set(ctx, authClient.SessionDenylistKey(sessionID), "1", dbr.sessionDenylistTTL).Err()
+	} else {
+		err = dbr.rdb.SetNX(ctx, authClient.SessionDenylistKey(sessionID), "0", sessionActiveTTL).Err()
+	}
+	if err != nil {
+		log.Println(err)
+	}
+	return revoked, nil
+}
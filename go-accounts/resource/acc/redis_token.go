@@ -0,0 +1,108 @@
+package acc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+// familyRevokedTTL bounds how long a "not revoked" answer is cached. Revoking
+// a family overwrites the cached value, so this only limits memory usage.
+const familyRevokedTTL = 24 * time.Hour
+
+func (dbr *RedisResource) CreateRefreshToken(token model.RefreshToken) error {
+	return dbr.next.CreateRefreshToken(token)
+}
+
+func (dbr *RedisResource) GetRefreshToken(tokenHash string) (model.RefreshToken, error) {
+	key := fmt.Sprintf("refresh:%s", tokenHash)
+	val, err := dbr.rdb.Get(context.Background(), key).Result()
+	if err != nil {
+		token, err := dbr.next.GetRefreshToken(tokenHash)
+		if err != nil {
+			return model.RefreshToken{}, err
+		}
+
+		ttl := time.Until(token.ExpiresAt)
+		if ttl <= 0 {
+			return token, nil
+		}
+
+		tokenJSON, err := json.Marshal(token)
+		if err != nil {
+			log.Println(err)
+			return token, nil
+		}
+		err = dbr.rdb.Set(context.Background(), key, tokenJSON, ttl).Err()
+		if err != nil {
+			log.Println(err)
+		}
+
+		return token, nil
+	}
+
+	var token model.RefreshToken
+	err = json.Unmarshal([]byte(val), &token)
+	if err != nil {
+		return dbr.next.GetRefreshToken(tokenHash)
+	}
+
+	return token, nil
+}
+
+func (dbr *RedisResource) MarkRefreshTokenUsed(tokenHash string, usedAt time.Time) (bool, error) {
+	ok, err := dbr.next.MarkRefreshTokenUsed(tokenHash, usedAt)
+	if err != nil {
+		return ok, err
+	}
+
+	err = dbr.rdb.Del(context.Background(), fmt.Sprintf("refresh:%s", tokenHash)).Err()
+	if err != nil {
+		log.Println(err)
+	}
+
+	return ok, nil
+}
+
+func (dbr *RedisResource) RevokeTokenFamily(familyID string) error {
+	err := dbr.next.RevokeTokenFamily(familyID)
+	if err != nil {
+		return err
+	}
+
+	err = dbr.rdb.Set(context.Background(), fmt.Sprintf("refresh_family_revoked:%s", familyID), "1", familyRevokedTTL).Err()
+	if err != nil {
+		log.Println(err)
+	}
+
+	return nil
+}
+
+func (dbr *RedisResource) IsTokenFamilyRevoked(familyID string) (bool, error) {
+	key := fmt.Sprintf("refresh_family_revoked:%s", familyID)
+	val, err := dbr.rdb.Get(context.Background(), key).Result()
+	if err == nil {
+		return val == "1", nil
+	}
+
+	revoked, err := dbr.next.IsTokenFamilyRevoked(familyID)
+	if err != nil {
+		return false, err
+	}
+
+	val = "0"
+	if revoked {
+		val = "1"
+	}
+	// SetNX so a concurrent revocation is never overwritten by a stale read
+	err = dbr.rdb.SetNX(context.Background(), key, val, familyRevokedTTL).Err()
+	if err != nil {
+		log.Println(err)
+	}
+
+	return revoked, nil
+}
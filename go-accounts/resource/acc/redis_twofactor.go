@@ -0,0 +1,33 @@
+package acc
+
+import (
+	"github.com/lolmourne/go-accounts/model"
+)
+
+func (dbr *RedisResource) SaveTOTPSecret(userID int64, secret string) error {
+	return dbr.next.SaveTOTPSecret(userID, secret)
+}
+
+func (dbr *RedisResource) GetTOTP(userID int64) (model.TOTP, error) {
+	return dbr.next.GetTOTP(userID)
+}
+
+func (dbr *RedisResource) EnableTOTP(userID int64) error {
+	return dbr.next.EnableTOTP(userID)
+}
+
+func (dbr *RedisResource) DisableTOTP(userID int64) error {
+	return dbr.next.DisableTOTP(userID)
+}
+
+func (dbr *RedisResource) UseTOTPStep(userID int64, step int64) (bool, error) {
+	return dbr.next.UseTOTPStep(userID, step)
+}
+
+func (dbr *RedisResource) SaveRecoveryCodes(userID int64, codeHashes []string) error {
+	return dbr.next.SaveRecoveryCodes(userID, codeHashes)
+}
+
+func (dbr *RedisResource) UseRecoveryCode(userID int64, codeHash string) (bool, error) {
+	return dbr.next.UseRecoveryCode(userID, codeHash)
+}
@@ -0,0 +1,40 @@
+package audit
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lolmourne/go-accounts/model"
+)
+
+// AuditSink receives security events. Events are only ever appended.
+type AuditSink interface {
+	Record(event model.AuditEvent) error
+}
+
+type DBItf interface {
+	AuditSink
+	GetEvents(filter model.AuditFilter) ([]model.AuditEvent, error)
+}
+
+type DBResource struct {
+	db *sqlx.DB
+}
+
+type AuditEventDB struct {
+	EventID   int64          `db:"event_id"`
+	UserID    sql.NullInt64  `db:"user_id"`
+	ActorID   sql.NullInt64  `db:"actor_id"`
+	Action    string         `db:"action"`
+	IPAddress string         `db:"ip_address"`
+	UserAgent string         `db:"user_agent"`
+	Metadata  sql.NullString `db:"metadata"`
+	CreatedAt time.Time      `db:"created_at"`
+}
+
+func NewDBResource(dbParam *sqlx.DB) DBItf {
+	return &DBResource{
+		db: dbParam,
+	}
+}
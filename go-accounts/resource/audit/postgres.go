@@ -0,0 +1,139 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+func (dbr *DBResource) Record(event model.AuditEvent) error {
+	query := `
+		INSERT INTO
+			audit_log
+		(
+			user_id,
+			actor_id,
+			action,
+			ip_address,
+			user_agent,
+			metadata,
+			created_at
+		)
+		VALUES
+		(
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7
+		)
+	`
+
+	var metadata sql.NullString
+	if len(event.Metadata) > 0 {
+		raw, err := json.Marshal(event.Metadata)
+		if err != nil {
+			return err
+		}
+		metadata = sql.NullString{String: string(raw), Valid: true}
+	}
+
+	_, err := dbr.db.Exec(query, nullID(event.UserID), nullID(event.ActorID), event.Action, event.IPAddress, event.UserAgent, metadata, event.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (dbr *DBResource) GetEvents(filter model.AuditFilter) ([]model.AuditEvent, error) {
+	var conds []string
+	var args []interface{}
+	where := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, strings.Replace(cond, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+
+	if filter.UserID != 0 {
+		where("user_id = ?", filter.UserID)
+	}
+	if filter.ActorID != 0 {
+		where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		where("action = ?", filter.Action)
+	}
+	if filter.IPAddress != "" {
+		where("ip_address = ?", filter.IPAddress)
+	}
+	if filter.Since != nil {
+		where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		where("created_at < ?", *filter.Until)
+	}
+	if filter.BeforeID != 0 {
+		where("event_id < ?", filter.BeforeID)
+	}
+
+	query := `
+	SELECT
+		event_id,
+		user_id,
+		actor_id,
+		action,
+		ip_address,
+		user_agent,
+		metadata,
+		created_at
+	FROM
+		audit_log
+	`
+	if len(conds) > 0 {
+		query += `WHERE
+		` + strings.Join(conds, `
+	AND
+		`)
+	}
+	args = append(args, filter.Limit)
+	query += `
+	ORDER BY
+		event_id DESC
+	LIMIT $` + strconv.Itoa(len(args))
+
+	var rows []AuditEventDB
+	err := dbr.db.Select(&rows, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]model.AuditEvent, len(rows))
+	for i, row := range rows {
+		events[i] = model.AuditEvent{
+			EventID:   row.EventID,
+			UserID:    row.UserID.Int64,
+			ActorID:   row.ActorID.Int64,
+			Action:    row.Action,
+			IPAddress: row.IPAddress,
+			UserAgent: row.UserAgent,
+			CreatedAt: row.CreatedAt,
+		}
+		if row.Metadata.Valid {
+			err = json.Unmarshal([]byte(row.Metadata.String), &events[i].Metadata)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return events, nil
+}
+
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
@@ -0,0 +1,85 @@
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/lolmourne/go-accounts/model"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+const (
+	DriverS3    = "s3"
+	DriverLocal = "local"
+)
+
+// Store keeps uploaded files under slash separated keys such as
+// "image/profile/<uuid>.png".
+type Store interface {
+	Put(data []byte, key string, mime string) error
+	Get(key string) ([]byte, string, error)
+	Delete(key string) error
+	// URL returns the public link of key.
+	URL(key string) string
+}
+
+// LocalStore keeps files on disk under dir. go-accounts serves them itself
+// under /files, so uploads work without any cloud account.
+type LocalStore struct {
+	dir       string
+	publicURL string
+}
+
+type S3Store struct {
+	client     *s3.S3
+	bucketName string
+	publicURL  string
+}
+
+// NewStore returns the store selected by Driver.
+func NewStore(cfg model.Config) (Store, error) {
+	switch Driver(cfg) {
+	case DriverS3:
+		return NewS3Store(cfg.S3Cred, cfg.Storage.PublicURL)
+	case DriverLocal:
+		return NewLocalStore(cfg.Storage.Dir, cfg.Storage.PublicURL), nil
+	}
+	return nil, fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+}
+
+// Driver returns cfg.Storage.Driver. Without one, configs with an S3 bucket
+// keep storing in S3 as they did before drivers existed, the rest use the
+// local disk.
+func Driver(cfg model.Config) string {
+	if cfg.Storage.Driver != "" {
+		return cfg.Storage.Driver
+	}
+	if cfg.S3Cred.BucketName != "" {
+		return DriverS3
+	}
+	return DriverLocal
+}
+
+func NewLocalStore(dir, publicURL string) Store {
+	if dir == "" {
+		dir = "uploads"
+	}
+	if publicURL == "" {
+		publicURL = "http://localhost:7070/files/"
+	}
+
+	return &LocalStore{
+		dir:       dir,
+		publicURL: withTrailingSlash(publicURL),
+	}
+}
+
+func withTrailingSlash(url string) string {
+	if strings.HasSuffix(url, "/") {
+		return url
+	}
+	return url + "/"
+}
@@ -0,0 +1,68 @@
+package blob
+
+import (
+	"errors"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+func (ls *LocalStore) Put(data []byte, key string, mime string) error {
+	filename, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+func (ls *LocalStore) Get(key string) ([]byte, string, error) {
+	filename, err := ls.path(key)
+	if err != nil {
+		return nil, "", ErrNotFound
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, mime.TypeByExtension(path.Ext(key)), nil
+}
+
+func (ls *LocalStore) Delete(key string) error {
+	filename, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (ls *LocalStore) URL(key string) string {
+	return ls.publicURL + strings.TrimPrefix(key, "/")
+}
+
+// path maps key inside dir, refusing keys that would escape it.
+func (ls *LocalStore) path(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if cleaned == "/" || strings.Contains(key, "..") {
+		return "", errors.New("invalid blob key")
+	}
+	return filepath.Join(ls.dir, filepath.FromSlash(cleaned)), nil
+}
@@ -0,0 +1,100 @@
+package blob
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/lolmourne/go-accounts/model"
+)
+
+// NewS3Store connects to AWS S3, or to any S3 compatible service when
+// cred.Endpoint is set. Without publicURL objects are linked through the
+// bucket's own address.
+func NewS3Store(cred model.S3Credential, publicURL string) (Store, error) {
+	region := cred.Region
+	if region == "" {
+		region = "ap-southeast-1"
+	}
+
+	awsCfg := &aws.Config{
+		Region:           aws.String(region),
+		S3ForcePathStyle: aws.Bool(cred.ForcePathStyle),
+	}
+	if cred.Endpoint != "" {
+		awsCfg.Endpoint = aws.String(cred.Endpoint)
+	}
+	awsCfg.WithCredentials(credentials.NewCredentials(&credentials.StaticProvider{
+		Value: credentials.Value{
+			AccessKeyID:     cred.AccessID,
+			SecretAccessKey: cred.Secret,
+		},
+	}))
+
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if publicURL == "" {
+		if cred.Endpoint != "" {
+			publicURL = strings.TrimSuffix(cred.Endpoint, "/") + "/" + cred.BucketName
+		} else {
+			publicURL = "https://" + cred.BucketName + ".s3-" + region + ".amazonaws.com"
+		}
+	}
+
+	return &S3Store{
+		client:     s3.New(sess),
+		bucketName: cred.BucketName,
+		publicURL:  withTrailingSlash(publicURL),
+	}, nil
+}
+
+func (s *S3Store) Put(data []byte, key string, mime string) error {
+	_, err := s.client.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(s.bucketName),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(mime),
+	})
+	return err
+}
+
+func (s *S3Store) Get(key string) ([]byte, string, error) {
+	out, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	defer out.Body.Close()
+
+	data, err := ioutil.ReadAll(out.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, aws.StringValue(out.ContentType), nil
+}
+
+func (s *S3Store) Delete(key string) error {
+	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (s *S3Store) URL(key string) string {
+	return s.publicURL + strings.TrimPrefix(key, "/")
+}
@@ -0,0 +1,34 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Publisher broadcasts events to the other services.
+type Publisher interface {
+	Publish(channel string, event interface{}) error
+}
+
+type RedisPublisher struct {
+	rdb *redis.Client
+}
+
+func NewRedisPublisher(rdb *redis.Client) Publisher {
+	return &RedisPublisher{
+		rdb: rdb,
+	}
+}
+
+// Publish sends event as JSON over Redis pubsub. Delivery is at most once,
+// subscribers that are down miss it.
+func (p *RedisPublisher) Publish(channel string, event interface{}) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return p.rdb.Publish(context.Background(), channel, payload).Err()
+}
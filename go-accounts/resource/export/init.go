@@ -0,0 +1,73 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Source provides the data another service keeps about a user, as the JSON
+// returned by its GET /me/export endpoint.
+type Source interface {
+	Name() string
+	Export(accessToken string) (json.RawMessage, error)
+}
+
+// HTTPSource calls GET <host>/me/export with the access token of the user
+// asking for the export, so each service only hands out the caller's data.
+type HTTPSource struct {
+	name    string
+	host    string
+	timeout time.Duration
+}
+
+func NewHTTPSource(name, host string, timeout time.Duration) Source {
+	return &HTTPSource{
+		name:    name,
+		host:    host,
+		timeout: timeout,
+	}
+}
+
+func (s *HTTPSource) Name() string {
+	return s.name
+}
+
+func (s *HTTPSource) Export(accessToken string) (json.RawMessage, error) {
+	client := &http.Client{
+		Timeout: s.timeout,
+	}
+
+	req, err := http.NewRequest("GET", s.host+"/me/export", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Access-Token", accessToken)
+
+	respRaw, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer respRaw.Body.Close()
+
+	if respRaw.StatusCode != 200 {
+		return nil, fmt.Errorf("%s export returned status %d", s.name, respRaw.StatusCode)
+	}
+
+	respByte, err := ioutil.ReadAll(respRaw.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data json.RawMessage `json:"data"`
+	}
+	err = json.Unmarshal(respByte, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Data, nil
+}
@@ -0,0 +1,56 @@
+package mailer
+
+import (
+	"net/smtp"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(msg Message) error
+}
+
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// LogMailer prints messages to the log and, when dir is set, writes each one
+// to a file in dir. It is meant for local development and tests.
+type LogMailer struct {
+	dir string
+}
+
+// NewMailer returns the mailer selected by cfg.Driver, "smtp" or "log".
+func NewMailer(cfg model.MailCfg) Mailer {
+	if cfg.Driver == "smtp" {
+		return NewSMTPMailer(cfg)
+	}
+	return NewLogMailer(cfg.Dir)
+}
+
+func NewSMTPMailer(cfg model.MailCfg) Mailer {
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	return &SMTPMailer{
+		addr: cfg.Host + ":" + cfg.Port,
+		from: cfg.From,
+		auth: auth,
+	}
+}
+
+func NewLogMailer(dir string) Mailer {
+	return &LogMailer{
+		dir: dir,
+	}
+}
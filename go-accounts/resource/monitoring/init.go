@@ -5,10 +5,12 @@ import "github.com/prometheus/client_golang/prometheus"
 type PrometheusMonitoring struct {
 	httpMonitoringCounter   *prometheus.CounterVec
 	httpMonitoringHistogram *prometheus.HistogramVec
+	lockoutCounter          *prometheus.CounterVec
 }
 
 type IMonitoring interface {
 	CountLogin(endpointName string, statuscode int, errorMsg string, latency float64)
+	CountLockout(scope string)
 }
 
 func NewPrometheusMonitoring() IMonitoring {
@@ -27,11 +29,21 @@ func NewPrometheusMonitoring() IMonitoring {
 		[]string{"endpoint_name", "status_code", "errror"},
 	)
 
+	lockoutCounter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "account_lockouts_total",
+			Help: "Number of brute force lockouts by subject scope",
+		},
+		[]string{"scope"},
+	)
+
 	prometheus.MustRegister(httpMonitoringCounter)
 	prometheus.MustRegister(httpMonitoringHistogram)
+	prometheus.MustRegister(lockoutCounter)
 
 	return &PrometheusMonitoring{
 		httpMonitoringCounter:   httpMonitoringCounter,
 		httpMonitoringHistogram: httpMonitoringHistogram,
+		lockoutCounter:          lockoutCounter,
 	}
 }
@@ -6,3 +6,7 @@ func (pm *PrometheusMonitoring) CountLogin(endpointName string, statusCode int,
 	pm.httpMonitoringCounter.WithLabelValues(endpointName, fmt.Sprintf("%d", statusCode), errorMsg).Inc()
 	pm.httpMonitoringHistogram.WithLabelValues(endpointName, fmt.Sprintf("%d", statusCode), errorMsg).Observe(latency)
 }
+
+func (pm *PrometheusMonitoring) CountLockout(scope string) {
+	pm.lockoutCounter.WithLabelValues(scope).Inc()
+}
@@ -0,0 +1,62 @@
+package oidc
+
+import (
+	"crypto"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+// Provider runs the authorization code flow against one OpenID Connect
+// identity provider.
+type Provider interface {
+	Name() string
+	// AuthCodeURL is where the browser is sent to sign in. codeChallenge is
+	// the S256 PKCE challenge of the verifier later passed to Exchange.
+	AuthCodeURL(state, nonce, codeChallenge string) (string, error)
+	// Exchange redeems code and returns the claims of the verified ID
+	// token. The nonce is returned, not checked.
+	Exchange(code, codeVerifier string) (model.OIDCIdentity, error)
+}
+
+// HTTPProvider discovers the provider endpoints from its issuer on first use
+// and caches its signing keys.
+type HTTPProvider struct {
+	cfg        model.OIDCCfg
+	httpClient *http.Client
+
+	mu        sync.RWMutex
+	discovery *discovery
+	keys      map[string]crypto.PublicKey
+	algs      map[string]string
+	fetchedAt time.Time
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func NewProvider(cfg model.OIDCCfg) Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	cfg.Issuer = strings.TrimRight(cfg.Issuer, "/")
+
+	return &HTTPProvider{
+		cfg: cfg,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		keys: make(map[string]crypto.PublicKey),
+		algs: make(map[string]string),
+	}
+}
@@ -0,0 +1,284 @@
+package oidc
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	authClient "github.com/lolmourne/go-accounts/client/userauth"
+	"github.com/lolmourne/go-accounts/model"
+)
+
+// minRefetchInterval limits how often an unknown kid triggers a JWKS fetch.
+const minRefetchInterval = 30 * time.Second
+
+func (p *HTTPProvider) Name() string {
+	return p.cfg.Name
+}
+
+func (p *HTTPProvider) AuthCodeURL(state, nonce, codeChallenge string) (string, error) {
+	disc, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(disc.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.cfg.ClientID)
+	query.Set("redirect_uri", p.cfg.RedirectURL)
+	query.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+
+	return authURL.String(), nil
+}
+
+func (p *HTTPProvider) Exchange(code, codeVerifier string) (model.OIDCIdentity, error) {
+	disc, err := p.discover()
+	if err != nil {
+		return model.OIDCIdentity{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequest(http.MethodPost, disc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return model.OIDCIdentity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return model.OIDCIdentity{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return model.OIDCIdentity{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return model.OIDCIdentity{}, fmt.Errorf("token endpoint of %s: status %d: %s", p.cfg.Name, resp.StatusCode, body)
+	}
+
+	var tokenResp struct {
+		IDToken string `json:"id_token"`
+	}
+	err = json.Unmarshal(body, &tokenResp)
+	if err != nil {
+		return model.OIDCIdentity{}, err
+	}
+	if tokenResp.IDToken == "" {
+		return model.OIDCIdentity{}, fmt.Errorf("token endpoint of %s returned no id_token", p.cfg.Name)
+	}
+
+	return p.verify(tokenResp.IDToken, disc.Issuer)
+}
+
+// verify checks the signature, issuer, audience and expiry of an ID token.
+func (p *HTTPProvider) verify(idToken, issuer string) (model.OIDCIdentity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, alg, err := p.key(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != alg {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key, nil
+	})
+	if err != nil {
+		log.Println(err)
+		return model.OIDCIdentity{}, ErrInvalidIDToken
+	}
+
+	audiences := audience(claims["aud"])
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) || !claims.VerifyIssuer(issuer, true) || !contains(audiences, p.cfg.ClientID) {
+		return model.OIDCIdentity{}, ErrInvalidIDToken
+	}
+	// with several audiences the token must have been issued to us
+	azp, ok := claims["azp"].(string)
+	if (ok && azp != p.cfg.ClientID) || (!ok && len(audiences) > 1) {
+		return model.OIDCIdentity{}, ErrInvalidIDToken
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return model.OIDCIdentity{}, ErrInvalidIDToken
+	}
+
+	identity := model.OIDCIdentity{
+		Provider: p.cfg.Name,
+		Subject:  subject,
+	}
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	identity.PreferredUsername, _ = claims["preferred_username"].(string)
+	identity.Nonce, _ = claims["nonce"].(string)
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		// some providers send the flag as a string
+		identity.EmailVerified = verified == "true"
+	}
+
+	return identity, nil
+}
+
+// audience reads the aud claim, a single string or an array of them.
+// jwt-go's VerifyAudience only understands the string form.
+func audience(aud interface{}) []string {
+	switch aud := aud.(type) {
+	case string:
+		return []string{aud}
+	case []interface{}:
+		audiences := make([]string, 0, len(aud))
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+		return audiences
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *HTTPProvider) discover() (*discovery, error) {
+	p.mu.RLock()
+	disc := p.discovery
+	p.mu.RUnlock()
+	if disc != nil {
+		return disc, nil
+	}
+
+	disc = &discovery{}
+	err := p.getJSON(p.cfg.Issuer+"/.well-known/openid-configuration", disc)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimRight(disc.Issuer, "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("discovery of %s: issuer mismatch %q", p.cfg.Name, disc.Issuer)
+	}
+	if disc.AuthorizationEndpoint == "" || disc.TokenEndpoint == "" || disc.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery of %s: missing endpoints", p.cfg.Name)
+	}
+
+	p.mu.Lock()
+	p.discovery = disc
+	p.mu.Unlock()
+
+	return disc, nil
+}
+
+func (p *HTTPProvider) key(kid string) (crypto.PublicKey, string, error) {
+	p.mu.RLock()
+	key, ok := p.keys[kid]
+	alg := p.algs[kid]
+	recent := time.Since(p.fetchedAt) < minRefetchInterval
+	p.mu.RUnlock()
+
+	if ok {
+		return key, alg, nil
+	}
+	if recent {
+		return nil, "", fmt.Errorf("unknown kid %q", kid)
+	}
+
+	err := p.refreshKeys()
+	if err != nil {
+		return nil, "", err
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	key, ok = p.keys[kid]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown kid %q", kid)
+	}
+	return key, p.algs[kid], nil
+}
+
+func (p *HTTPProvider) refreshKeys() error {
+	disc, err := p.discover()
+	if err != nil {
+		return err
+	}
+
+	var jwks authClient.JWKS
+	err = p.getJSON(disc.JWKSURI, &jwks)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	algs := make(map[string]string, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		pub, err := jwk.PublicKey()
+		if err != nil {
+			// only RSA and Ed25519 keys are supported
+			continue
+		}
+		alg := jwk.Alg
+		if alg == "" && jwk.Kty == "RSA" {
+			alg = authClient.AlgRS256
+		}
+		keys[jwk.Kid] = pub
+		algs[jwk.Kid] = alg
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.algs = algs
+	p.fetchedAt = time.Now()
+	p.mu.Unlock()
+
+	return nil
+}
+
+func (p *HTTPProvider) getJSON(rawURL string, v interface{}) error {
+	resp, err := p.httpClient.Get(rawURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch %s: status %d", rawURL, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
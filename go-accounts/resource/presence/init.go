@@ -0,0 +1,35 @@
+package presence
+
+import (
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ConnectionTTL is how long a connection counts as online after its last
+// refresh, the websocket service refreshes them about every minute.
+const ConnectionTTL = time.Duration(2) * time.Minute
+
+// Source tells which users are connected to the chat right now.
+type Source interface {
+	Online(userIDs []int64) (map[int64]bool, error)
+}
+
+// RedisPresence keeps, for every user, a sorted set of their open chat
+// connections scored with the time each one expires. The websocket
+// instances write it and refresh their connections while they are alive,
+// so a crashed instance stops counting once its entries expire, and any
+// service sharing the Redis reads it.
+type RedisPresence struct {
+	rdb *redis.Client
+	ttl time.Duration
+}
+
+// NewRedisPresence returns a presence store whose connections expire ttl
+// after they were last refreshed.
+func NewRedisPresence(rdb *redis.Client, ttl time.Duration) *RedisPresence {
+	return &RedisPresence{
+		rdb: rdb,
+		ttl: ttl,
+	}
+}
@@ -0,0 +1,72 @@
+package presence
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func presenceKey(userID int64) string {
+	return fmt.Sprintf("presence:%d", userID)
+}
+
+// Connect records a new connection of userID and returns its id, to be
+// passed to Refresh and Disconnect.
+func (p *RedisPresence) Connect(ctx context.Context, userID int64) (string, error) {
+	raw := make([]byte, 16)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", err
+	}
+	connID := hex.EncodeToString(raw)
+
+	return connID, p.Refresh(ctx, userID, connID)
+}
+
+// Refresh keeps the connection alive for another ttl and drops the expired
+// connections of userID.
+func (p *RedisPresence) Refresh(ctx context.Context, userID int64, connID string) error {
+	now := time.Now()
+	key := presenceKey(userID)
+
+	pipe := p.rdb.TxPipeline()
+	pipe.ZAdd(ctx, key, &redis.Z{Score: float64(now.Add(p.ttl).Unix()), Member: connID})
+	pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.Unix(), 10))
+	pipe.Expire(ctx, key, p.ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (p *RedisPresence) Disconnect(ctx context.Context, userID int64, connID string) error {
+	return p.rdb.ZRem(ctx, presenceKey(userID), connID).Err()
+}
+
+// Online returns the users of userIDs with a live connection on any
+// instance, the others are left out.
+func (p *RedisPresence) Online(userIDs []int64) (map[int64]bool, error) {
+	ctx := context.Background()
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	pipe := p.rdb.Pipeline()
+	counts := make([]*redis.IntCmd, len(userIDs))
+	for i, userID := range userIDs {
+		counts[i] = pipe.ZCount(ctx, presenceKey(userID), "("+now, "+inf")
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	online := make(map[int64]bool)
+	for i, userID := range userIDs {
+		if counts[i].Val() > 0 {
+			online[userID] = true
+		}
+	}
+	return online, nil
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IPResolver finds the address of the client behind a request. Unlike
+// gin's ClientIP it only believes X-Forwarded-For when the connection comes
+// from a trusted proxy, clients cannot pick the address they are limited
+// and audited under.
+type IPResolver struct {
+	trusted []*net.IPNet
+}
+
+// NewIPResolver trusts the proxies in trustedProxies, given as IPs or CIDRs.
+// Without any the connection's address is always used.
+func NewIPResolver(trustedProxies []string) (*IPResolver, error) {
+	r := &IPResolver{}
+	for _, proxy := range trustedProxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			r.trusted = append(r.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %v", proxy, err)
+		}
+		r.trusted = append(r.trusted, ipNet)
+	}
+	return r, nil
+}
+
+// ClientIP walks X-Forwarded-For from the right, past the trusted proxies,
+// and returns the first address no trusted proxy vouches for.
+func (r *IPResolver) ClientIP(req *http.Request) string {
+	remote := remoteIP(req.RemoteAddr)
+	if !r.isTrusted(remote) {
+		return remote
+	}
+
+	var hops []string
+	for _, header := range req.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		if net.ParseIP(hops[i]) == nil {
+			break
+		}
+		client = hops[i]
+		if !r.isTrusted(client) {
+			break
+		}
+	}
+	return client
+}
+
+// Key limits per client address.
+func (r *IPResolver) Key(c *gin.Context) string {
+	return r.ClientIP(c.Request)
+}
+
+func (r *IPResolver) isTrusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range r.trusted {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return strings.TrimSpace(remoteAddr)
+	}
+	return host
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Limiter allows at most limit hits per key inside a sliding window.
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Result, error)
+	Reset(ctx context.Context, key string) error
+}
+
+// Subject is what failures are counted against, e.g. {"username", "bob"} or
+// {"ip", "10.0.0.1"}.
+type Subject struct {
+	Scope string
+	Value string
+}
+
+// Guard counts failed attempts per subject and locks a subject out once it
+// fails too often. Every consecutive lockout doubles the cooldown.
+type Guard interface {
+	// Check returns how long the most restricted subject is still locked.
+	Check(ctx context.Context, subjects ...Subject) (time.Duration, error)
+	// Fail records a failed attempt and returns the lockout it caused, if any.
+	Fail(ctx context.Context, subjects ...Subject) (time.Duration, error)
+	// Reset forgets the failures of the subjects after a success.
+	Reset(ctx context.Context, subjects ...Subject) error
+}
+
+type GuardCfg struct {
+	MaxFailures   int
+	FailureWindow time.Duration
+	BaseLockout   time.Duration
+	MaxLockout    time.Duration
+	// LevelTTL is how long past lockouts are remembered for escalation.
+	LevelTTL time.Duration
+}
+
+type RedisLimiter struct {
+	rdb    *redis.Client
+	prefix string
+	limit  int
+	window time.Duration
+}
+
+type RedisGuard struct {
+	rdb       *redis.Client
+	prefix    string
+	cfg       GuardCfg
+	onLockout func(scope string)
+}
+
+func NewRedisLimiter(rdb *redis.Client, prefix string, limit int, window time.Duration) Limiter {
+	return &RedisLimiter{
+		rdb:    rdb,
+		prefix: prefix,
+		limit:  limit,
+		window: window,
+	}
+}
+
+// NewRedisGuard creates a Guard. onLockout, if not nil, is called with the
+// subject scope every time a subject gets locked.
+func NewRedisGuard(rdb *redis.Client, prefix string, cfg GuardCfg, onLockout func(scope string)) Guard {
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = 5
+	}
+	if cfg.FailureWindow <= 0 {
+		cfg.FailureWindow = 15 * time.Minute
+	}
+	if cfg.BaseLockout <= 0 {
+		cfg.BaseLockout = time.Minute
+	}
+	if cfg.MaxLockout <= 0 {
+		cfg.MaxLockout = time.Hour
+	}
+	if cfg.LevelTTL <= 0 {
+		cfg.LevelTTL = 24 * time.Hour
+	}
+
+	return &RedisGuard{
+		rdb:       rdb,
+		prefix:    prefix,
+		cfg:       cfg,
+		onLockout: onLockout,
+	}
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyByIP limits per connection address, for services that are not behind
+// a proxy. Use IPResolver.Key otherwise.
+func KeyByIP(c *gin.Context) string {
+	return remoteIP(c.Request.RemoteAddr)
+}
+
+// Middleware rejects requests over the limit with 429 and a Retry-After
+// header. When redis is unavailable requests are let through.
+func Middleware(limiter Limiter, keyFunc func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res, err := limiter.Allow(c.Request.Context(), keyFunc(c))
+		if err != nil {
+			log.Println(err)
+			c.Next()
+			return
+		}
+
+		if !res.Allowed {
+			TooManyRequests(c, res.RetryAfter)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// TooManyRequests aborts c with 429 in the same shape as the services'
+// StandardAPIResponse.
+func TooManyRequests(c *gin.Context, retryAfter time.Duration) {
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", fmt.Sprintf("%d", seconds))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"err":     "Too Many Requests",
+		"message": fmt.Sprintf("retry after %d seconds", seconds),
+		"data":    nil,
+	})
+}
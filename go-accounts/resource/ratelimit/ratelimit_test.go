@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClientIP(t *testing.T) {
+	resolver, err := NewIPResolver([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"direct", "203.0.113.7:4242", nil, "203.0.113.7"},
+		{"direct spoofing", "203.0.113.7:4242", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"through proxy", "10.1.2.3:80", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"through exact proxy", "192.168.1.1:80", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"untrusted neighbour of proxy", "192.168.1.2:80", []string{"198.51.100.1"}, "192.168.1.2"},
+		{"proxy chain", "10.1.2.3:80", []string{"198.51.100.1, 10.9.9.9"}, "198.51.100.1"},
+		{"client prepends spoofed hop", "10.1.2.3:80", []string{"1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+		{"repeated header", "10.1.2.3:80", []string{"1.1.1.1", "198.51.100.1"}, "198.51.100.1"},
+		{"only proxies", "10.1.2.3:80", []string{"10.0.0.1"}, "10.0.0.1"},
+		{"proxy without header", "10.1.2.3:80", nil, "10.1.2.3"},
+		{"garbage hop", "10.1.2.3:80", []string{"not-an-ip"}, "10.1.2.3"},
+		{"ipv6 proxy", "[fd00::1]:80", []string{"2001:db8::1"}, "2001:db8::1"},
+		{"ipv6 direct", "[2001:db8::2]:80", []string{"2001:db8::1"}, "2001:db8::2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, hop := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", hop)
+			}
+			if got := resolver.ClientIP(req); got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPWithoutTrustedProxies(t *testing.T) {
+	resolver, err := NewIPResolver(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	req.Header.Set("X-Real-IP", "198.51.100.2")
+	if got := resolver.ClientIP(req); got != "127.0.0.1" {
+		t.Errorf("ClientIP = %q, want the connection address", got)
+	}
+}
+
+func TestNewIPResolverRejectsInvalid(t *testing.T) {
+	for _, proxy := range []string{"", "localhost", "10.0.0.0/33", "10.0.0.256"} {
+		if _, err := NewIPResolver([]string{proxy}); err == nil {
+			t.Errorf("NewIPResolver(%q) accepted", proxy)
+		}
+	}
+}
+
+func TestLockoutFor(t *testing.T) {
+	cfg := GuardCfg{BaseLockout: time.Minute, MaxLockout: 10 * time.Minute}
+
+	tests := []struct {
+		level int64
+		want  time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{4, 8 * time.Minute},
+		{5, 10 * time.Minute},
+		{100, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := lockoutFor(cfg, tt.level); got != tt.want {
+			t.Errorf("lockoutFor(%d) = %v, want %v", tt.level, got, tt.want)
+		}
+	}
+}
+
+type fakeLimiter struct {
+	res  Result
+	err  error
+	keys []string
+}
+
+func (l *fakeLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	l.keys = append(l.keys, key)
+	return l.res, l.err
+}
+
+func (l *fakeLimiter) Reset(ctx context.Context, key string) error {
+	return nil
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		limiter        *fakeLimiter
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{"allowed", &fakeLimiter{res: Result{Allowed: true}}, 200, ""},
+		{"limited", &fakeLimiter{res: Result{RetryAfter: 1500 * time.Millisecond}}, 429, "2"},
+		{"limited under a second", &fakeLimiter{res: Result{RetryAfter: time.Millisecond}}, 429, "1"},
+		{"redis down", &fakeLimiter{err: errors.New("connection refused")}, 200, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", Middleware(tt.limiter, KeyByIP), func(c *gin.Context) {
+				c.Status(200)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "203.0.113.7:4242"
+			req.Header.Set("X-Forwarded-For", "198.51.100.1")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+			if len(tt.limiter.keys) != 1 || tt.limiter.keys[0] != "203.0.113.7" {
+				t.Errorf("limited keys %v, want the connection address", tt.limiter.keys)
+			}
+		})
+	}
+}
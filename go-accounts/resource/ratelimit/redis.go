@@ -0,0 +1,179 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// allowScript implements a sliding window log on a sorted set scored by the
+// hit time in milliseconds. A hit is only recorded when it is allowed.
+var allowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+local count = redis.call('ZCARD', key)
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, window)
+	return {1, limit - count - 1, 0}
+end
+
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+return {0, 0, window - (now - tonumber(oldest[2]))}
+`)
+
+// recordScript always records a hit and returns the number of hits inside
+// the window.
+var recordScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+redis.call('ZADD', key, now, ARGV[3])
+redis.call('PEXPIRE', key, window)
+return redis.call('ZCARD', key)
+`)
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	now := time.Now()
+	res, err := allowScript.Run(ctx, l.rdb, []string{l.key(key)},
+		now.UnixNano()/int64(time.Millisecond),
+		l.window.Milliseconds(),
+		l.limit,
+		member(now),
+	).Result()
+	if err != nil {
+		return Result{Allowed: true}, err
+	}
+
+	values, ok := res.([]interface{})
+	if !ok || len(values) != 3 {
+		return Result{Allowed: true}, fmt.Errorf("unexpected limiter reply %v", res)
+	}
+	allowed, _ := values[0].(int64)
+	remaining, _ := values[1].(int64)
+	retryAfter, _ := values[2].(int64)
+
+	return Result{
+		Allowed:    allowed == 1,
+		Remaining:  int(remaining),
+		RetryAfter: time.Duration(retryAfter) * time.Millisecond,
+	}, nil
+}
+
+func (l *RedisLimiter) Reset(ctx context.Context, key string) error {
+	return l.rdb.Del(ctx, l.key(key)).Err()
+}
+
+func (l *RedisLimiter) key(key string) string {
+	return fmt.Sprintf("ratelimit:%s:%s", l.prefix, key)
+}
+
+func (g *RedisGuard) Check(ctx context.Context, subjects ...Subject) (time.Duration, error) {
+	var longest time.Duration
+	for _, subject := range subjects {
+		ttl, err := g.rdb.PTTL(ctx, g.key("locked", subject)).Result()
+		if err != nil {
+			return 0, err
+		}
+		if ttl > longest {
+			longest = ttl
+		}
+	}
+	return longest, nil
+}
+
+func (g *RedisGuard) Fail(ctx context.Context, subjects ...Subject) (time.Duration, error) {
+	var longest time.Duration
+	now := time.Now()
+	for _, subject := range subjects {
+		count, err := recordScript.Run(ctx, g.rdb, []string{g.key("failures", subject)},
+			now.UnixNano()/int64(time.Millisecond),
+			g.cfg.FailureWindow.Milliseconds(),
+			member(now),
+		).Int64()
+		if err != nil {
+			return longest, err
+		}
+
+		if int(count) < g.cfg.MaxFailures {
+			continue
+		}
+
+		lockout, err := g.lock(ctx, subject)
+		if err != nil {
+			return longest, err
+		}
+		if lockout > longest {
+			longest = lockout
+		}
+	}
+	return longest, nil
+}
+
+func (g *RedisGuard) Reset(ctx context.Context, subjects ...Subject) error {
+	for _, subject := range subjects {
+		err := g.rdb.Del(ctx, g.key("failures", subject), g.key("level", subject)).Err()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lock locks subject out for BaseLockout * 2^(n-1) where n counts the
+// lockouts seen within LevelTTL, and starts a fresh failure window for when
+// the cooldown ends.
+func (g *RedisGuard) lock(ctx context.Context, subject Subject) (time.Duration, error) {
+	levelKey := g.key("level", subject)
+	level, err := g.rdb.Incr(ctx, levelKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	g.rdb.Expire(ctx, levelKey, g.cfg.LevelTTL)
+
+	lockout := lockoutFor(g.cfg, level)
+
+	pipe := g.rdb.TxPipeline()
+	pipe.Set(ctx, g.key("locked", subject), "1", lockout)
+	pipe.Del(ctx, g.key("failures", subject))
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if g.onLockout != nil {
+		g.onLockout(subject.Scope)
+	}
+
+	return lockout, nil
+}
+
+// lockoutFor returns BaseLockout doubled for every lockout before the
+// level-th, capped at MaxLockout.
+func lockoutFor(cfg GuardCfg, level int64) time.Duration {
+	lockout := cfg.BaseLockout
+	for i := int64(1); i < level && lockout < cfg.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > cfg.MaxLockout {
+		lockout = cfg.MaxLockout
+	}
+	return lockout
+}
+
+func (g *RedisGuard) key(kind string, subject Subject) string {
+	return fmt.Sprintf("guard:%s:%s:%s:%s", g.prefix, kind, subject.Scope, subject.Value)
+}
+
+func member(now time.Time) string {
+	return fmt.Sprintf("%d-%d", now.UnixNano(), rand.Int63())
+}
@@ -0,0 +1,274 @@
+package contact
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	authClient "github.com/lolmourne/go-accounts/client/userauth"
+	"github.com/lolmourne/go-accounts/model"
+)
+
+const (
+	maxContacts        = 1000
+	maxPendingRequests = 100
+)
+
+var (
+	ErrContactSelf            = errors.New("you cannot add yourself as a contact")
+	ErrUserNotFound           = errors.New("user not found")
+	ErrContactUnavailable     = errors.New("this user cannot be added as a contact")
+	ErrAlreadyContact         = errors.New("already a contact")
+	ErrTooManyContacts        = errors.New("too many contacts, remove unused ones first")
+	ErrTooManyContactRequests = errors.New("too many pending contact requests")
+	ErrContactRequestNotFound = errors.New("contact request not found")
+	ErrContactNotFound        = errors.New("contact not found")
+)
+
+// SendRequest asks otherUserID to become a contact of userID. A pending
+// request the other way is accepted instead, accepted reports it. Sending
+// the same request twice is a no-op.
+func (u *Usecase) SendRequest(userID, otherUserID int64) (accepted bool, err error) {
+	if userID == otherUserID {
+		return false, ErrContactSelf
+	}
+
+	other, err := u.dbRsc.GetUserByUserID(otherUserID)
+	if err != nil {
+		log.Println(err)
+		return false, errors.New("Internal Server Error")
+	}
+	if other.UserID == 0 || other.Deleted || other.DeactivatedAt != nil {
+		return false, ErrUserNotFound
+	}
+	if other.Bot {
+		return false, ErrContactUnavailable
+	}
+
+	// do not tell the requester it is blocked
+	blocked, err := u.dbRsc.IsBlocked(userID, otherUserID)
+	if err != nil {
+		log.Println(err)
+		return false, errors.New("Internal Server Error")
+	}
+	if blocked {
+		return false, ErrContactUnavailable
+	}
+
+	isContact, err := u.dbRsc.IsContact(userID, otherUserID)
+	if err != nil {
+		log.Println(err)
+		return false, errors.New("Internal Server Error")
+	}
+	if isContact {
+		return false, ErrAlreadyContact
+	}
+
+	err = u.checkContactLimit(userID)
+	if err != nil {
+		return false, err
+	}
+
+	incoming, err := u.dbRsc.GetContactRequests(userID, false)
+	if err != nil {
+		log.Println(err)
+		return false, errors.New("Internal Server Error")
+	}
+	for _, request := range incoming {
+		if request.User.UserID == otherUserID {
+			return true, u.AcceptRequest(userID, otherUserID)
+		}
+	}
+
+	outgoing, err := u.dbRsc.GetContactRequests(userID, true)
+	if err != nil {
+		log.Println(err)
+		return false, errors.New("Internal Server Error")
+	}
+	if len(outgoing) >= maxPendingRequests {
+		return false, ErrTooManyContactRequests
+	}
+
+	created, err := u.dbRsc.CreateContactRequest(userID, otherUserID, time.Now())
+	if err != nil {
+		log.Println(err)
+		return false, errors.New("Internal Server Error")
+	}
+	if created {
+		u.publish(authClient.ContactRequested, userID, otherUserID)
+	}
+	return false, nil
+}
+
+// AcceptRequest makes requesterID and userID contacts of each other.
+func (u *Usecase) AcceptRequest(userID, requesterID int64) error {
+	err := u.checkContactLimit(userID)
+	if err != nil {
+		return err
+	}
+	err = u.checkContactLimit(requesterID)
+	if err != nil {
+		return err
+	}
+
+	accepted, err := u.dbRsc.AcceptContactRequest(requesterID, userID, time.Now())
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	if !accepted {
+		return ErrContactRequestNotFound
+	}
+
+	u.publish(authClient.ContactAccepted, userID, requesterID)
+	return nil
+}
+
+func (u *Usecase) DeclineRequest(userID, requesterID int64) error {
+	deleted, err := u.dbRsc.DeleteContactRequest(requesterID, userID)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	if !deleted {
+		return ErrContactRequestNotFound
+	}
+
+	u.publish(authClient.ContactDeclined, userID, requesterID)
+	return nil
+}
+
+func (u *Usecase) CancelRequest(userID, addresseeID int64) error {
+	deleted, err := u.dbRsc.DeleteContactRequest(userID, addresseeID)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	if !deleted {
+		return ErrContactRequestNotFound
+	}
+
+	u.publish(authClient.ContactCancelled, userID, addresseeID)
+	return nil
+}
+
+func (u *Usecase) RemoveContact(userID, contactUserID int64) error {
+	isContact, err := u.dbRsc.IsContact(userID, contactUserID)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	if !isContact {
+		return ErrContactNotFound
+	}
+
+	_, err = u.dbRsc.RemoveContact(userID, contactUserID)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+
+	u.publish(authClient.ContactRemoved, userID, contactUserID)
+	return nil
+}
+
+// GetContacts lists the contacts of userID with their presence in the
+// chat; when that cannot be loaded the list is returned without it.
+func (u *Usecase) GetContacts(userID int64) ([]model.Contact, error) {
+	contacts, err := u.dbRsc.GetContacts(userID)
+	if err != nil {
+		log.Println(err)
+		return nil, errors.New("Internal Server Error")
+	}
+
+	userIDs := make([]int64, len(contacts))
+	for i := range contacts {
+		contacts[i].User = contacts[i].User.Public()
+		userIDs[i] = contacts[i].User.UserID
+	}
+
+	if u.presence == nil || len(contacts) == 0 {
+		return contacts, nil
+	}
+
+	online, err := u.presence.Online(userIDs)
+	if err != nil {
+		log.Println("cannot load presence of contacts", userID, err)
+		return contacts, nil
+	}
+	for i := range contacts {
+		isOnline := online[contacts[i].User.UserID]
+		contacts[i].Online = &isOnline
+	}
+	return contacts, nil
+}
+
+// GetRequests lists the pending requests sent to userID, or sent by it when
+// outgoing.
+func (u *Usecase) GetRequests(userID int64, outgoing bool) ([]model.ContactRequest, error) {
+	requests, err := u.dbRsc.GetContactRequests(userID, outgoing)
+	if err != nil {
+		log.Println(err)
+		return nil, errors.New("Internal Server Error")
+	}
+
+	for i := range requests {
+		requests[i].User = requests[i].User.Public()
+	}
+	return requests, nil
+}
+
+// GetMutualContacts lists the contacts userID and otherUserID share.
+func (u *Usecase) GetMutualContacts(userID, otherUserID int64) ([]model.User, error) {
+	other, err := u.dbRsc.GetUserByUserID(otherUserID)
+	if err != nil {
+		log.Println(err)
+		return nil, errors.New("Internal Server Error")
+	}
+	if other.UserID == 0 || other.Deleted {
+		return nil, ErrUserNotFound
+	}
+
+	blocked, err := u.dbRsc.IsBlocked(userID, otherUserID)
+	if err != nil {
+		log.Println(err)
+		return nil, errors.New("Internal Server Error")
+	}
+	if blocked {
+		return nil, ErrUserNotFound
+	}
+
+	users, err := u.dbRsc.GetMutualContacts(userID, otherUserID)
+	if err != nil {
+		log.Println(err)
+		return nil, errors.New("Internal Server Error")
+	}
+
+	for i := range users {
+		users[i] = users[i].Public()
+	}
+	return users, nil
+}
+
+func (u *Usecase) checkContactLimit(userID int64) error {
+	contacts, err := u.dbRsc.GetContacts(userID)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	if len(contacts) >= maxContacts {
+		return ErrTooManyContacts
+	}
+	return nil
+}
+
+func (u *Usecase) publish(eventType string, userID, contactUserID int64) {
+	err := u.publisher.Publish(authClient.ContactChangedChannel, authClient.ContactChangedEvent{
+		Type:          eventType,
+		UserID:        userID,
+		ContactUserID: contactUserID,
+	})
+	if err != nil {
+		log.Println("cannot publish contact change of user", userID, err)
+	}
+}
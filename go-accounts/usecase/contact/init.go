@@ -0,0 +1,33 @@
+package contact
+
+import (
+	"github.com/lolmourne/go-accounts/model"
+	"github.com/lolmourne/go-accounts/resource/acc"
+	"github.com/lolmourne/go-accounts/resource/events"
+	"github.com/lolmourne/go-accounts/resource/presence"
+)
+
+type IUsecase interface {
+	SendRequest(userID, otherUserID int64) (bool, error)
+	AcceptRequest(userID, requesterID int64) error
+	DeclineRequest(userID, requesterID int64) error
+	CancelRequest(userID, addresseeID int64) error
+	RemoveContact(userID, contactUserID int64) error
+	GetContacts(userID int64) ([]model.Contact, error)
+	GetRequests(userID int64, outgoing bool) ([]model.ContactRequest, error)
+	GetMutualContacts(userID, otherUserID int64) ([]model.User, error)
+}
+
+type Usecase struct {
+	dbRsc     acc.DBItf
+	publisher events.Publisher
+	presence  presence.Source
+}
+
+func NewUsecase(dbRsc acc.DBItf, publisher events.Publisher, presence presence.Source) IUsecase {
+	return &Usecase{
+		dbRsc:     dbRsc,
+		publisher: publisher,
+		presence:  presence,
+	}
+}
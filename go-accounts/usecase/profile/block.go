@@ -0,0 +1,103 @@
+package profile
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	authClient "github.com/lolmourne/go-accounts/client/userauth"
+	"github.com/lolmourne/go-accounts/model"
+)
+
+var (
+	ErrBlockSelf    = errors.New("you cannot block yourself")
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// BlockUser stops blockedUserID from reaching userID. The other services
+// learn about it through authClient.BlockChangedChannel.
+func (u *Usecase) BlockUser(userID, blockedUserID int64) error {
+	if userID == blockedUserID {
+		return ErrBlockSelf
+	}
+
+	user, err := u.dbRsc.GetUserByUserID(blockedUserID)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	if user.UserID == 0 || user.Deleted {
+		return ErrUserNotFound
+	}
+
+	err = u.dbRsc.BlockUser(userID, blockedUserID, time.Now())
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+
+	u.publishBlock(userID, blockedUserID, true)
+
+	// blocking ends the contact and any pending request between the two
+	removed, err := u.dbRsc.RemoveContact(userID, blockedUserID)
+	if err != nil {
+		log.Println(err)
+	}
+	if removed {
+		err = u.publisher.Publish(authClient.ContactChangedChannel, authClient.ContactChangedEvent{
+			Type:          authClient.ContactRemoved,
+			UserID:        userID,
+			ContactUserID: blockedUserID,
+		})
+		if err != nil {
+			log.Println("cannot publish contact change of user", userID, err)
+		}
+	}
+	return nil
+}
+
+func (u *Usecase) UnblockUser(userID, blockedUserID int64) error {
+	err := u.dbRsc.UnblockUser(userID, blockedUserID)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+
+	u.publishBlock(userID, blockedUserID, false)
+	return nil
+}
+
+func (u *Usecase) GetBlockedUsers(userID int64) ([]model.BlockedUser, error) {
+	blocked, err := u.dbRsc.GetBlockedUsers(userID)
+	if err != nil {
+		log.Println(err)
+		return nil, errors.New("Internal Server Error")
+	}
+
+	for i := range blocked {
+		blocked[i].User = blocked[i].User.Public()
+	}
+
+	return blocked, nil
+}
+
+func (u *Usecase) IsBlocked(userID, otherUserID int64) (bool, error) {
+	blocked, err := u.dbRsc.IsBlocked(userID, otherUserID)
+	if err != nil {
+		log.Println(err)
+		return false, errors.New("Internal Server Error")
+	}
+
+	return blocked, nil
+}
+
+func (u *Usecase) publishBlock(userID, blockedUserID int64, blocked bool) {
+	err := u.publisher.Publish(authClient.BlockChangedChannel, authClient.BlockChangedEvent{
+		UserID:        userID,
+		BlockedUserID: blockedUserID,
+		Blocked:       blocked,
+	})
+	if err != nil {
+		log.Println("cannot publish block change of user", userID, err)
+	}
+}
@@ -0,0 +1,76 @@
+package profile
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+// ExportData writes a zip archive of everything kept about the user: the
+// account from go-accounts and one file per export source. Remote data is
+// gathered first so a failing service aborts the export before anything
+// is written.
+func (u *Usecase) ExportData(userID int64, accessToken string, w io.Writer) error {
+	user, err := u.dbRsc.GetUserByUserID(userID)
+	if err != nil || user.UserID == 0 {
+		return errors.New("user not found")
+	}
+	user.Password = ""
+	user.Salt = ""
+
+	sessions, err := u.dbRsc.GetSessionsByUserID(userID)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+
+	files := map[string]interface{}{
+		"account.json":  user,
+		"sessions.json": sessions,
+	}
+	for _, source := range u.exportSources {
+		data, err := source.Export(accessToken)
+		if err != nil {
+			log.Println(err)
+			return errors.New("cannot gather data from " + source.Name() + ", try again later")
+		}
+		files[source.Name()+".json"] = data
+	}
+
+	archive := zip.NewWriter(w)
+	for name, content := range files {
+		f, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// DeleteAvatar removes every rendition of an uploaded avatar. Pictures not
+// stored by UploadFile are left alone.
+func (u *Usecase) DeleteAvatar(profilePic string) {
+	for _, key := range model.AvatarKeys(profilePic) {
+		err := u.store.Delete(key)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
@@ -1,17 +1,39 @@
 package profile
 
-import "github.com/lolmourne/go-accounts/resource/s3"
+import (
+	"io"
+
+	"github.com/lolmourne/go-accounts/model"
+	"github.com/lolmourne/go-accounts/resource/acc"
+	"github.com/lolmourne/go-accounts/resource/blob"
+	"github.com/lolmourne/go-accounts/resource/events"
+	"github.com/lolmourne/go-accounts/resource/export"
+)
 
 type IUsecase interface {
-	UploadFile([]byte) (string, error)
+	UploadFile(file io.Reader) (string, error)
+	UpdateProfile(userID int64, update model.ProfileUpdate) (model.User, error)
+	SearchUsers(viewerID int64, query, cursor string, limit int) (model.UserPage, error)
+	ExportData(userID int64, accessToken string, w io.Writer) error
+	DeleteAvatar(profilePic string)
+	BlockUser(userID, blockedUserID int64) error
+	UnblockUser(userID, blockedUserID int64) error
+	GetBlockedUsers(userID int64) ([]model.BlockedUser, error)
+	IsBlocked(userID, otherUserID int64) (bool, error)
 }
 
 type Usecase struct {
-	res s3.IS3
+	store         blob.Store
+	dbRsc         acc.DBItf
+	exportSources []export.Source
+	publisher     events.Publisher
 }
 
-func NewUsecase(res s3.IS3) IUsecase {
+func NewUsecase(store blob.Store, dbRsc acc.DBItf, exportSources []export.Source, publisher events.Publisher) IUsecase {
 	return &Usecase{
-		res: res,
+		store:         store,
+		dbRsc:         dbRsc,
+		exportSources: exportSources,
+		publisher:     publisher,
 	}
 }
@@ -0,0 +1,148 @@
+package profile
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+const (
+	maxDisplayNameLen = 50
+	maxBioLen         = 280
+	maxStatusLen      = 100
+	maxStatusEmojiLen = 8
+)
+
+var (
+	ErrInvalidDisplayName = errors.New("display name must be at most 50 characters without control characters")
+	ErrInvalidBio         = errors.New("bio must be at most 280 characters")
+	ErrInvalidTimezone    = errors.New("timezone must be an IANA time zone such as Asia/Jakarta")
+	ErrInvalidStatus      = errors.New("status must be at most 100 characters")
+	ErrInvalidStatusEmoji = errors.New("status emoji must be a single emoji")
+	ErrStatusExpired      = errors.New("status expiry must be in the future")
+)
+
+// UpdateProfile applies the fields set in update and returns the updated
+// user.
+func (u *Usecase) UpdateProfile(userID int64, update model.ProfileUpdate) (model.User, error) {
+	err := normalizeProfileUpdate(&update, time.Now())
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if update.DisplayName != nil || update.Bio != nil || update.Timezone != nil {
+		err = u.dbRsc.UpdateUserProfile(userID, update)
+		if err != nil {
+			log.Println(err)
+			return model.User{}, errors.New("Internal Server Error")
+		}
+	}
+
+	if update.Status != nil {
+		status := update.Status
+		if status.Text == "" && status.Emoji == "" {
+			status = nil
+		}
+		err = u.dbRsc.UpdateUserStatus(userID, status)
+		if err != nil {
+			log.Println(err)
+			return model.User{}, errors.New("Internal Server Error")
+		}
+	}
+
+	user, err := u.dbRsc.GetUserByUserID(userID)
+	if err != nil || user.UserID == 0 {
+		return model.User{}, errors.New("user not found")
+	}
+
+	return user, nil
+}
+
+func normalizeProfileUpdate(update *model.ProfileUpdate, now time.Time) error {
+	if update.DisplayName != nil {
+		name := strings.TrimSpace(*update.DisplayName)
+		if utf8.RuneCountInString(name) > maxDisplayNameLen || hasControl(name, false) {
+			return ErrInvalidDisplayName
+		}
+		update.DisplayName = &name
+	}
+
+	if update.Bio != nil {
+		bio := strings.TrimSpace(*update.Bio)
+		if utf8.RuneCountInString(bio) > maxBioLen || hasControl(bio, true) {
+			return ErrInvalidBio
+		}
+		update.Bio = &bio
+	}
+
+	if update.Timezone != nil {
+		tz := strings.TrimSpace(*update.Timezone)
+		if tz != "" {
+			_, err := time.LoadLocation(tz)
+			if err != nil || tz == "Local" {
+				return ErrInvalidTimezone
+			}
+		}
+		update.Timezone = &tz
+	}
+
+	if update.Status != nil {
+		status := update.Status
+		status.Text = strings.TrimSpace(status.Text)
+		status.Emoji = strings.TrimSpace(status.Emoji)
+		if utf8.RuneCountInString(status.Text) > maxStatusLen || hasControl(status.Text, false) {
+			return ErrInvalidStatus
+		}
+		if status.Emoji != "" && !isEmoji(status.Emoji) {
+			return ErrInvalidStatusEmoji
+		}
+		if status.ExpiresAt != nil && !status.ExpiresAt.After(now) {
+			return ErrStatusExpired
+		}
+	}
+
+	return nil
+}
+
+func hasControl(s string, allowNewline bool) bool {
+	for _, r := range s {
+		if allowNewline && r == '\n' {
+			continue
+		}
+		if unicode.IsControl(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmoji accepts one emoji, including sequences joined with zero width
+// joiners, skin tone modifiers and flags, and rejects plain text.
+func isEmoji(s string) bool {
+	if utf8.RuneCountInString(s) > maxStatusEmojiLen {
+		return false
+	}
+
+	hasSymbol := false
+	for _, r := range s {
+		switch {
+		case r == '\u200d' || r == '\ufe0f':
+			// zero width joiner and emoji presentation selector
+		case r >= 0x1f1e6 && r <= 0x1f1ff:
+			// regional indicators making up flags
+			hasSymbol = true
+		case r >= 0x1f3fb && r <= 0x1f3ff:
+			// skin tone modifiers
+		case unicode.Is(unicode.So, r):
+			hasSymbol = true
+		default:
+			return false
+		}
+	}
+	return hasSymbol
+}
@@ -0,0 +1,97 @@
+package profile
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+	maxSearchQueryLen  = 50
+)
+
+var (
+	ErrInvalidSearchQuery = errors.New("search query must be 1 to 50 characters")
+	ErrInvalidCursor      = errors.New("invalid cursor")
+)
+
+// SearchUsers looks users up by username or display name. cursor is the
+// NextCursor of the previous page, empty for the first one. Users blocked in
+// either direction with viewerID are left out.
+func (u *Usecase) SearchUsers(viewerID int64, query, cursor string, limit int) (model.UserPage, error) {
+	query = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(query), "@"))
+	if query == "" || utf8.RuneCountInString(query) > maxSearchQueryLen {
+		return model.UserPage{}, ErrInvalidSearchQuery
+	}
+
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	var after model.UserSearchCursor
+	if cursor != "" {
+		var err error
+		after, err = decodeSearchCursor(cursor)
+		if err != nil {
+			return model.UserPage{}, ErrInvalidCursor
+		}
+	}
+
+	// one extra row tells whether there is a next page
+	hits, err := u.dbRsc.SearchUsers(viewerID, query, after, limit+1)
+	if err != nil {
+		log.Println(err)
+		return model.UserPage{}, errors.New("Internal Server Error")
+	}
+
+	page := model.UserPage{
+		Users: make([]model.User, 0, limit),
+	}
+	for i, hit := range hits {
+		if i == limit {
+			last := hits[i-1]
+			page.NextCursor = encodeSearchCursor(model.UserSearchCursor{
+				Rank:   last.Rank,
+				Score:  last.Score,
+				UserID: last.User.UserID,
+			})
+			break
+		}
+
+		page.Users = append(page.Users, hit.User.Public())
+	}
+
+	return page, nil
+}
+
+func encodeSearchCursor(cursor model.UserSearchCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeSearchCursor(cursor string) (model.UserSearchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return model.UserSearchCursor{}, err
+	}
+
+	var c model.UserSearchCursor
+	err = json.Unmarshal(raw, &c)
+	if err != nil {
+		return model.UserSearchCursor{}, err
+	}
+	if c.UserID < 1 {
+		return model.UserSearchCursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
@@ -1,31 +1,136 @@
 package profile
 
 import (
+	"bytes"
 	"errors"
-	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
 	"log"
-	"strings"
+	"strconv"
 
 	"github.com/gabriel-vasile/mimetype"
+	"github.com/lolmourne/go-accounts/model"
 	uuid "github.com/satori/go.uuid"
+	"golang.org/x/image/draw"
 )
 
-const AllowedExtensions = ".jpeg, .jpg, .png"
+const (
+	// MaxAvatarBytes is the largest avatar file accepted.
+	MaxAvatarBytes = 5 << 20
+	// maxAvatarSide bounds the decoded size, a small file can still declare
+	// huge dimensions.
+	maxAvatarSide = 4096
+)
+
+var (
+	ErrAvatarTooLarge  = errors.New("image is too large, the limit is 5 MB")
+	ErrInvalidImage    = errors.New("file is not a valid image")
+	ErrImageDimensions = errors.New("image dimensions are too large, the limit is 4096x4096")
+)
 
-func (u *Usecase) UploadFile(file []byte) (string, error) {
-	mime := mimetype.Detect(file)
-	if strings.Index(AllowedExtensions, mime.Extension()) == -1 {
+// UploadFile stores an avatar together with its square variants and returns
+// the URL of the original. The original is re-encoded so EXIF metadata such
+// as GPS position is not published.
+func (u *Usecase) UploadFile(file io.Reader) (string, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(file, MaxAvatarBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > MaxAvatarBytes {
+		return "", ErrAvatarTooLarge
+	}
+
+	mime := mimetype.Detect(data)
+	var ext string
+	switch {
+	case mime.Is("image/jpeg"):
+		ext = "jpg"
+	case mime.Is("image/png"):
+		ext = "png"
+	default:
 		return "", errors.New("File Type is not allowed, file type: " + mime.Extension())
 	}
-	log.Println(mime)
-	uid := uuid.NewV4()
 
-	fileName := fmt.Sprintf("image/profile/%s.%s", uid.String(), mime.Extension())
-	err := u.res.Put(file, fileName, mime.String())
+	imgCfg, _, err := image.DecodeConfig(bytes.NewReader(data))
 	if err != nil {
-		log.Print(err)
-		return "", err
+		return "", ErrInvalidImage
+	}
+	if imgCfg.Width > maxAvatarSide || imgCfg.Height > maxAvatarSide {
+		return "", ErrImageDimensions
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrInvalidImage
+	}
+	if ext == "jpg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	id := uuid.NewV4().String()
+	renditions := map[string]image.Image{
+		"original": img,
+	}
+	square := squareCrop(img.Bounds())
+	for _, size := range model.AvatarSizes {
+		renditions[strconv.Itoa(size)] = resize(img, square, size)
 	}
 
-	return "https://skilvul-course.s3-ap-southeast-1.amazonaws.com/" + fileName, nil
+	var stored []string
+	for name, rendition := range renditions {
+		key := model.AvatarKey(id, name, ext)
+		err = u.putImage(key, ext, mime.String(), rendition)
+		if err != nil {
+			log.Println(err)
+			u.deleteAll(stored)
+			return "", errors.New("failed to store image")
+		}
+		stored = append(stored, key)
+	}
+
+	return u.store.URL(model.AvatarKey(id, "original", ext)), nil
+}
+
+func (u *Usecase) putImage(key, ext, mime string, img image.Image) error {
+	var buf bytes.Buffer
+	var err error
+	if ext == "png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	}
+	if err != nil {
+		return err
+	}
+
+	return u.store.Put(buf.Bytes(), key, mime)
+}
+
+func (u *Usecase) deleteAll(keys []string) {
+	for _, key := range keys {
+		err := u.store.Delete(key)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// squareCrop returns the largest centered square inside bounds.
+func squareCrop(bounds image.Rectangle) image.Rectangle {
+	w, h := bounds.Dx(), bounds.Dy()
+	if w > h {
+		offset := (w - h) / 2
+		return image.Rect(bounds.Min.X+offset, bounds.Min.Y, bounds.Min.X+offset+h, bounds.Max.Y)
+	}
+	offset := (h - w) / 2
+	return image.Rect(bounds.Min.X, bounds.Min.Y+offset, bounds.Max.X, bounds.Min.Y+offset+w)
+}
+
+func resize(img image.Image, src image.Rectangle, size int) image.Image {
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, src, draw.Src, nil)
+	return dst
 }
@@ -0,0 +1,222 @@
+package userauth
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	authClient "github.com/lolmourne/go-accounts/client/userauth"
+	"github.com/lolmourne/go-accounts/model"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	maxAccessTokensPerUser = 50
+	maxAccessTokenTTL      = 365 * 24 * time.Hour
+	maxAccessTokenNameLen  = 100
+	// last use is recorded at most once per interval to spare the database
+	accessTokenTouchInterval = time.Minute
+)
+
+var (
+	ErrInvalidTokenName   = errors.New("token name must be 1 to 100 characters")
+	ErrInvalidScope       = errors.New("scopes must be one or more of read, write and chat")
+	ErrInvalidTokenExpiry = errors.New("token expiry must be between 1 and 365 days, or 0 for no expiry")
+	ErrTooManyTokens      = errors.New("too many active tokens, revoke unused ones first")
+	ErrTokenNotFound      = errors.New("token not found")
+)
+
+// CreateAccessToken creates a personal access token for userID, the actor
+// itself or one of its bots. expiresIn 0 means the token does not expire.
+// The token is only returned here.
+func (u *Usecase) CreateAccessToken(actorID, userID int64, name string, scopes []string, expiresIn time.Duration, client model.ClientInfo) (model.CreatedAccessToken, error) {
+	err := u.checkTokenOwner(actorID, userID)
+	if err != nil {
+		return model.CreatedAccessToken{}, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxAccessTokenNameLen || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return model.CreatedAccessToken{}, ErrInvalidTokenName
+	}
+
+	scopes, err = normalizeScopes(scopes)
+	if err != nil {
+		return model.CreatedAccessToken{}, err
+	}
+
+	if expiresIn < 0 || expiresIn > maxAccessTokenTTL {
+		return model.CreatedAccessToken{}, ErrInvalidTokenExpiry
+	}
+
+	active, err := u.dbRsc.GetAccessTokens(userID)
+	if err != nil {
+		log.Println(err)
+		return model.CreatedAccessToken{}, errors.New("Internal Server Error")
+	}
+	if len(active) >= maxAccessTokensPerUser {
+		return model.CreatedAccessToken{}, ErrTooManyTokens
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		log.Println(err)
+		return model.CreatedAccessToken{}, errors.New("Internal Server Error")
+	}
+	secret = authClient.PersonalAccessTokenPrefix + secret
+
+	now := time.Now()
+	token := model.PersonalAccessToken{
+		TokenID:   uuid.NewV4().String(),
+		UserID:    userID,
+		CreatedBy: actorID,
+		Name:      name,
+		TokenHash: hashToken(secret),
+		Scopes:    scopes,
+		CreatedAt: now,
+	}
+	if expiresIn > 0 {
+		expiresAt := now.Add(expiresIn)
+		token.ExpiresAt = &expiresAt
+	}
+
+	err = u.dbRsc.CreateAccessToken(token)
+	if err != nil {
+		log.Println(err)
+		return model.CreatedAccessToken{}, errors.New("Internal Server Error")
+	}
+
+	u.record(userID, actorID, model.AuditTokenCreate, client, map[string]string{
+		"token_id": token.TokenID,
+		"scopes":   strings.Join(scopes, " "),
+	})
+
+	return model.CreatedAccessToken{
+		PersonalAccessToken: token,
+		Token:               secret,
+	}, nil
+}
+
+// GetAccessTokens lists the active tokens of userID, the actor itself or
+// one of its bots.
+func (u *Usecase) GetAccessTokens(actorID, userID int64) ([]model.PersonalAccessToken, error) {
+	err := u.checkTokenOwner(actorID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := u.dbRsc.GetAccessTokens(userID)
+	if err != nil {
+		log.Println(err)
+		return nil, errors.New("Internal Server Error")
+	}
+	return tokens, nil
+}
+
+func (u *Usecase) RevokeAccessToken(actorID, userID int64, tokenID string, client model.ClientInfo) error {
+	err := u.checkTokenOwner(actorID, userID)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := u.dbRsc.RevokeAccessToken(userID, tokenID, time.Now())
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	if !revoked {
+		return ErrTokenNotFound
+	}
+
+	u.record(userID, actorID, model.AuditTokenRevoke, client, map[string]string{"token_id": tokenID})
+	return nil
+}
+
+// checkTokenOwner lets users manage their own tokens and those of their
+// bots.
+func (u *Usecase) checkTokenOwner(actorID, userID int64) error {
+	if actorID == userID {
+		return nil
+	}
+	_, err := u.ownedBot(actorID, userID)
+	return err
+}
+
+// validateAccessToken is ValidateSession for personal access tokens.
+func (u *Usecase) validateAccessToken(accessToken string) (model.AccessClaims, error) {
+	token, err := u.dbRsc.GetAccessTokenByHash(hashToken(accessToken))
+	if err != nil {
+		log.Println(err)
+		return model.AccessClaims{}, errors.New("Internal Server Error")
+	}
+
+	now := time.Now()
+	if token.TokenID == "" || token.RevokedAt != nil || (token.ExpiresAt != nil && !now.Before(*token.ExpiresAt)) {
+		return model.AccessClaims{}, ErrInvalidAccessToken
+	}
+
+	user, err := u.dbRsc.GetUserByUserID(token.UserID)
+	if err != nil {
+		log.Println(err)
+		return model.AccessClaims{}, errors.New("Internal Server Error")
+	}
+	if !accountActive(user) {
+		return model.AccessClaims{}, ErrInvalidAccessToken
+	}
+
+	// a bot stops working with the account of its owner
+	if user.Bot {
+		owner, err := u.dbRsc.GetUserByUserID(user.OwnerID)
+		if err != nil {
+			log.Println(err)
+			return model.AccessClaims{}, errors.New("Internal Server Error")
+		}
+		if !accountActive(owner) {
+			return model.AccessClaims{}, ErrInvalidAccessToken
+		}
+	}
+
+	err = u.dbRsc.TouchAccessToken(token.TokenID, now, accessTokenTouchInterval)
+	if err != nil {
+		log.Println(err)
+	}
+
+	claims := model.AccessClaims{
+		UserID:   user.UserID,
+		Role:     user.Role,
+		TokenID:  token.TokenID,
+		Scopes:   token.Scopes,
+		IssuedAt: token.CreatedAt,
+	}
+	if token.ExpiresAt != nil {
+		claims.ExpiresAt = *token.ExpiresAt
+	}
+	return claims, nil
+}
+
+// accountActive reports whether user may currently be signed in.
+func accountActive(user model.User) bool {
+	return user.UserID != 0 && !user.Deleted && user.DeactivatedAt == nil && checkAccountUsable(user) == nil
+}
+
+// normalizeScopes validates scopes and drops duplicates, a token needs at
+// least one.
+func normalizeScopes(scopes []string) ([]string, error) {
+	var normalized []string
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !model.ValidScope(scope) {
+			return nil, ErrInvalidScope
+		}
+		if !model.HasScope(normalized, scope) {
+			normalized = append(normalized, scope)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, ErrInvalidScope
+	}
+	return normalized, nil
+}
@@ -0,0 +1,162 @@
+package userauth
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	authClient "github.com/lolmourne/go-accounts/client/userauth"
+	"github.com/lolmourne/go-accounts/model"
+)
+
+const (
+	purgeBatchSize = 100
+	// purgeRetryAfter is how long a service has to confirm a deletion
+	// before it is published again.
+	purgeRetryAfter = time.Duration(10) * time.Minute
+)
+
+var (
+	ErrAccountDeactivated = errors.New("account is deactivated, reactivate it to sign in")
+	ErrUnknownService     = errors.New("unknown service")
+)
+
+// DeactivateAccount hides the account and signs it out everywhere until
+// the user reactivates it.
+func (u *Usecase) DeactivateAccount(userID int64, password, sessionID string) error {
+	return u.deactivate(userID, password, sessionID, nil)
+}
+
+// DeleteAccount deactivates the account and schedules its anonymization
+// after the grace period, during which reactivating cancels the deletion.
+func (u *Usecase) DeleteAccount(userID int64, password, sessionID string) (time.Time, error) {
+	deleteAfter := time.Now().Add(u.deletionGrace)
+	err := u.deactivate(userID, password, sessionID, &deleteAfter)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return deleteAfter, nil
+}
+
+func (u *Usecase) deactivate(userID int64, password, sessionID string, deleteAfter *time.Time) error {
+	user, err := u.dbRsc.GetUserByUserID(userID)
+	if err != nil || user.UserID == 0 {
+		return errors.New("user not found")
+	}
+
+	err = u.confirmIdentity(user, password, sessionID)
+	if err != nil {
+		return err
+	}
+
+	err = u.dbRsc.DeactivateUser(userID, time.Now(), deleteAfter)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+
+	return u.revokeAllSessions(userID)
+}
+
+// ReactivateAccount signs a deactivated user back in, cancelling a pending
+// deletion.
+func (u *Usecase) ReactivateAccount(username, password string, client model.ClientInfo) (model.UserToken, error) {
+	user, err := u.dbRsc.GetUserByUserName(username)
+	if err != nil || user.UserID == 0 || user.Deleted {
+		u.verifyDummy(password)
+		return model.UserToken{}, ErrInvalidCredentials
+	}
+
+	if !u.checkPassword(user, password) {
+		return model.UserToken{}, ErrInvalidCredentials
+	}
+
+	err = checkAccountUsable(user)
+	if err != nil {
+		return model.UserToken{}, err
+	}
+
+	if user.DeactivatedAt != nil {
+		err = u.dbRsc.ReactivateUser(user.UserID)
+		if err != nil {
+			log.Println(err)
+			return model.UserToken{}, errors.New("Internal Server Error")
+		}
+	}
+
+	return u.startSession(user, client)
+}
+
+// PurgeDeletedAccounts anonymizes the accounts whose deletion grace period
+// is over and tells the other services to scrub their data. It returns the
+// users as they were before anonymization so callers can clean up files.
+func (u *Usecase) PurgeDeletedAccounts() ([]model.User, error) {
+	userIDs, err := u.dbRsc.GetUsersDueForDeletion(time.Now(), purgeBatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	purged := make([]model.User, 0, len(userIDs))
+	for _, userID := range userIDs {
+		user, err := u.dbRsc.GetUserByUserID(userID)
+		if err != nil {
+			return purged, err
+		}
+
+		err = u.dbRsc.AnonymizeUser(userID, time.Now(), authClient.PurgeServices)
+		if err != nil {
+			return purged, err
+		}
+		purged = append(purged, user)
+
+		err = u.publisher.Publish(authClient.AccountDeletedChannel, authClient.AccountDeletedEvent{
+			UserID: userID,
+		})
+		if err != nil {
+			log.Println("cannot publish deletion of user", userID, err)
+		}
+	}
+
+	return purged, nil
+}
+
+// RetryPendingPurges publishes the deletion of accounts again when some
+// service has not confirmed it within purgeRetryAfter. Pubsub drops events
+// while a subscriber is down, the outbox makes sure it catches up.
+func (u *Usecase) RetryPendingPurges() (int, error) {
+	userIDs, err := u.dbRsc.GetPendingPurges(time.Now().Add(-purgeRetryAfter), purgeBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, userID := range userIDs {
+		err = u.publisher.Publish(authClient.AccountDeletedChannel, authClient.AccountDeletedEvent{
+			UserID: userID,
+		})
+		if err != nil {
+			return i, err
+		}
+
+		err = u.dbRsc.MarkPurgeAttempted(userID, time.Now())
+		if err != nil {
+			return i, err
+		}
+	}
+
+	return len(userIDs), nil
+}
+
+// ConfirmPurge records that service scrubbed the deleted account.
+func (u *Usecase) ConfirmPurge(userID int64, service string) error {
+	known := false
+	for _, s := range authClient.PurgeServices {
+		if s == service {
+			known = true
+		}
+	}
+	if !known {
+		return ErrUnknownService
+	}
+
+	return u.dbRsc.ConfirmPurge(userID, service, time.Now())
+}
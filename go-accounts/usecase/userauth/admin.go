@@ -0,0 +1,190 @@
+package userauth
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+const (
+	defaultListUsersLimit = 50
+	maxListUsersLimit     = 200
+)
+
+var (
+	ErrAccountSuspended      = errors.New("account is suspended")
+	ErrPasswordResetRequired = errors.New("password has to be reset, follow the link sent to your email")
+	ErrInvalidRole           = errors.New("role must be user, moderator or admin")
+	ErrSelfAdminAction       = errors.New("admins cannot change their own role or suspend themselves")
+	ErrUserNotFound          = errors.New("user not found")
+	ErrInvalidCursor         = errors.New("invalid cursor")
+)
+
+// checkAccountUsable refuses sign in to suspended accounts and to accounts
+// whose password has to be reset first.
+func checkAccountUsable(user model.User) error {
+	if user.SuspendedAt != nil {
+		return ErrAccountSuspended
+	}
+	if user.PasswordResetRequired {
+		return ErrPasswordResetRequired
+	}
+	return nil
+}
+
+// ListUsers pages through every account for admins. cursor is the
+// NextCursor of the previous page, empty for the first one.
+func (u *Usecase) ListUsers(role, cursor string, limit int) (model.UserPage, error) {
+	if role != "" && !model.ValidRole(role) {
+		return model.UserPage{}, ErrInvalidRole
+	}
+
+	var afterUserID int64
+	if cursor != "" {
+		var err error
+		afterUserID, err = strconv.ParseInt(cursor, 10, 64)
+		if err != nil || afterUserID < 1 {
+			return model.UserPage{}, ErrInvalidCursor
+		}
+	}
+
+	if limit <= 0 {
+		limit = defaultListUsersLimit
+	}
+	if limit > maxListUsersLimit {
+		limit = maxListUsersLimit
+	}
+
+	// one extra row tells whether there is a next page
+	users, err := u.dbRsc.ListUsers(role, afterUserID, limit+1)
+	if err != nil {
+		log.Println(err)
+		return model.UserPage{}, errors.New("Internal Server Error")
+	}
+
+	page := model.UserPage{
+		Users: make([]model.User, 0, limit),
+	}
+	for i, user := range users {
+		if i == limit {
+			page.NextCursor = strconv.FormatInt(users[i-1].UserID, 10)
+			break
+		}
+		page.Users = append(page.Users, user)
+	}
+
+	return page, nil
+}
+
+// ChangeRole gives userID a new role. A demoted user is signed out
+// everywhere so access tokens carrying the old role stop working.
+func (u *Usecase) ChangeRole(actorID, userID int64, role string, client model.ClientInfo) error {
+	if !model.ValidRole(role) {
+		return ErrInvalidRole
+	}
+	if actorID == userID {
+		return ErrSelfAdminAction
+	}
+
+	user, err := u.adminTarget(userID)
+	if err != nil {
+		return err
+	}
+
+	err = u.dbRsc.UpdateUserRole(userID, role)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	u.record(userID, actorID, model.AuditRoleChange, client, map[string]string{"from": user.Role, "to": role})
+
+	if !model.HasRole(role, user.Role) {
+		return u.revokeAllSessions(userID)
+	}
+	return nil
+}
+
+// SuspendUser blocks the account from signing in and ends its sessions
+// until the suspension is lifted.
+func (u *Usecase) SuspendUser(actorID, userID int64, client model.ClientInfo) error {
+	if actorID == userID {
+		return ErrSelfAdminAction
+	}
+
+	_, err := u.adminTarget(userID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = u.dbRsc.SuspendUser(userID, &now)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	u.record(userID, actorID, model.AuditSuspend, client, nil)
+
+	return u.revokeAllSessions(userID)
+}
+
+func (u *Usecase) UnsuspendUser(actorID, userID int64, client model.ClientInfo) error {
+	_, err := u.adminTarget(userID)
+	if err != nil {
+		return err
+	}
+
+	err = u.dbRsc.SuspendUser(userID, nil)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	u.record(userID, actorID, model.AuditUnsuspend, client, nil)
+
+	return nil
+}
+
+// ForcePasswordReset signs the user out and refuses sign in until the
+// password is reset through the link mailed to a verified address. Users
+// without one have to go through support.
+func (u *Usecase) ForcePasswordReset(actorID, userID int64, client model.ClientInfo) error {
+	user, err := u.adminTarget(userID)
+	if err != nil {
+		return err
+	}
+
+	err = u.dbRsc.SetPasswordResetRequired(userID, true)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	u.record(userID, actorID, model.AuditForcePasswordReset, client, nil)
+
+	err = u.revokeAllSessions(userID)
+	if err != nil {
+		return err
+	}
+
+	if user.Email != "" && user.EmailVerified {
+		err = u.sendPasswordReset(user)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	return nil
+}
+
+func (u *Usecase) adminTarget(userID int64) (model.User, error) {
+	user, err := u.dbRsc.GetUserByUserID(userID)
+	if err != nil {
+		log.Println(err)
+		return model.User{}, errors.New("Internal Server Error")
+	}
+	if user.UserID == 0 || user.Deleted {
+		return model.User{}, ErrUserNotFound
+	}
+	return user, nil
+}
@@ -0,0 +1,95 @@
+package userauth
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 200
+)
+
+// record appends an event to the audit log. A failing audit log does not
+// fail the action it describes.
+func (u *Usecase) record(userID, actorID int64, action string, client model.ClientInfo, metadata map[string]string) {
+	err := u.auditRsc.Record(model.AuditEvent{
+		UserID:    userID,
+		ActorID:   actorID,
+		Action:    action,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		Metadata:  metadata,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		log.Println("cannot record audit event", action, userID, err)
+	}
+}
+
+// GetAuditLog lets admins search the audit log. cursor is the NextCursor of
+// the previous page, empty for the first one.
+func (u *Usecase) GetAuditLog(filter model.AuditFilter, cursor string) (model.AuditPage, error) {
+	if cursor != "" {
+		beforeID, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || beforeID < 1 {
+			return model.AuditPage{}, ErrInvalidCursor
+		}
+		filter.BeforeID = beforeID
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	if filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+	limit := filter.Limit
+
+	// one extra row tells whether there is a next page
+	filter.Limit++
+	events, err := u.auditRsc.GetEvents(filter)
+	if err != nil {
+		log.Println(err)
+		return model.AuditPage{}, errors.New("Internal Server Error")
+	}
+
+	page := model.AuditPage{
+		Events: make([]model.AuditEvent, 0, limit),
+	}
+	for i, event := range events {
+		if i == limit {
+			page.NextCursor = strconv.FormatInt(events[i-1].EventID, 10)
+			break
+		}
+		page.Events = append(page.Events, event)
+	}
+
+	return page, nil
+}
+
+// GetActivity shows users the security events of their own account. Events
+// somebody else caused, such as an admin suspending the account, keep
+// who did it and from where to the audit log.
+func (u *Usecase) GetActivity(userID int64, cursor string, limit int) (model.AuditPage, error) {
+	page, err := u.GetAuditLog(model.AuditFilter{
+		UserID: userID,
+		Limit:  limit,
+	}, cursor)
+	if err != nil {
+		return page, err
+	}
+
+	for i, event := range page.Events {
+		if event.ActorID != 0 && event.ActorID != userID {
+			page.Events[i].ActorID = 0
+			page.Events[i].IPAddress = ""
+			page.Events[i].UserAgent = ""
+		}
+	}
+	return page, nil
+}
@@ -0,0 +1,118 @@
+package userauth
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lolmourne/go-accounts/model"
+	"github.com/lolmourne/go-accounts/resource/acc"
+)
+
+const (
+	maxBotsPerUser       = 10
+	maxBotDisplayNameLen = 50
+)
+
+var (
+	ErrTooManyBots           = errors.New("too many bots, delete unused ones first")
+	ErrBotNotFound           = errors.New("bot not found")
+	ErrInvalidBotDisplayName = errors.New("display name must be at most 50 characters")
+)
+
+// CreateBot creates a bot account managed by ownerID. Bots have no password,
+// they act through personal access tokens created by the owner.
+func (u *Usecase) CreateBot(ownerID int64, username, displayName string, client model.ClientInfo) (model.User, error) {
+	displayName = strings.TrimSpace(displayName)
+	if utf8.RuneCountInString(displayName) > maxBotDisplayNameLen || strings.IndexFunc(displayName, unicode.IsControl) >= 0 {
+		return model.User{}, ErrInvalidBotDisplayName
+	}
+
+	err := u.CheckUsername(username)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	bots, err := u.dbRsc.GetBots(ownerID)
+	if err != nil {
+		log.Println(err)
+		return model.User{}, errors.New("Internal Server Error")
+	}
+	if len(bots) >= maxBotsPerUser {
+		return model.User{}, ErrTooManyBots
+	}
+
+	botID, err := u.dbRsc.CreateBot(username, ownerID, time.Now())
+	if err == acc.ErrDuplicate {
+		return model.User{}, ErrUsernameTaken
+	}
+	if err != nil {
+		log.Println(err)
+		return model.User{}, errors.New("Internal Server Error")
+	}
+
+	if displayName != "" {
+		err = u.dbRsc.UpdateUserProfile(botID, model.ProfileUpdate{DisplayName: &displayName})
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	u.record(botID, ownerID, model.AuditBotCreate, client, map[string]string{"username": username})
+
+	bot, err := u.dbRsc.GetUserByUserID(botID)
+	if err != nil || bot.UserID == 0 {
+		log.Println("cannot load created bot", botID, err)
+		return model.User{}, errors.New("Internal Server Error")
+	}
+	return bot, nil
+}
+
+func (u *Usecase) GetBots(ownerID int64) ([]model.User, error) {
+	bots, err := u.dbRsc.GetBots(ownerID)
+	if err != nil {
+		log.Println(err)
+		return nil, errors.New("Internal Server Error")
+	}
+	return bots, nil
+}
+
+// DeleteBot revokes the tokens of the bot and schedules the account for
+// anonymization right away.
+func (u *Usecase) DeleteBot(ownerID, botID int64, client model.ClientInfo) error {
+	_, err := u.ownedBot(ownerID, botID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = u.dbRsc.RevokeAccessTokens(botID, now)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+
+	err = u.dbRsc.DeactivateUser(botID, now, &now)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+
+	u.record(botID, ownerID, model.AuditBotDelete, client, nil)
+	return nil
+}
+
+func (u *Usecase) ownedBot(ownerID, botID int64) (model.User, error) {
+	bot, err := u.dbRsc.GetUserByUserID(botID)
+	if err != nil {
+		log.Println(err)
+		return model.User{}, errors.New("Internal Server Error")
+	}
+	if bot.UserID == 0 || !bot.Bot || bot.OwnerID != ownerID || bot.Deleted || bot.DeactivatedAt != nil {
+		return model.User{}, ErrBotNotFound
+	}
+	return bot, nil
+}
@@ -0,0 +1,214 @@
+package userauth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+	"github.com/lolmourne/go-accounts/resource/mailer"
+)
+
+const (
+	verifyEmailTokenTTL   = 24 * time.Hour
+	resetPasswordTokenTTL = time.Hour
+)
+
+var (
+	ErrInvalidEmail = errors.New("invalid email address")
+	ErrEmailTaken   = errors.New("email is already used by another account")
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+// ChangeEmail asks for the password, or a fresh sign in for accounts
+// without one: the address receives password resets, redirecting it is as
+// good as taking the account over.
+func (u *Usecase) ChangeEmail(userID int64, password, sessionID, email string, client model.ClientInfo) error {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return err
+	}
+
+	user, err := u.dbRsc.GetUserByUserID(userID)
+	if err != nil || user.UserID == 0 {
+		return errors.New("user not found")
+	}
+
+	err = u.confirmIdentity(user, password, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if strings.EqualFold(user.Email, email) && user.EmailVerified {
+		return errors.New("email is already verified")
+	}
+
+	owner, err := u.dbRsc.GetUserByEmail(email)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	if owner.UserID != 0 && owner.UserID != userID {
+		return ErrEmailTaken
+	}
+
+	err = u.dbRsc.UpdateUserEmail(userID, email)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	u.record(userID, 0, model.AuditEmailChange, client, map[string]string{"from": user.Email, "to": email})
+
+	return u.sendVerification(user.UserID, user.Username, email)
+}
+
+func (u *Usecase) VerifyEmail(token string) error {
+	accountToken, err := u.dbRsc.UseAccountToken(hashToken(token), model.TokenPurposeVerifyEmail, time.Now())
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	err = u.dbRsc.MarkEmailVerified(accountToken.UserID, accountToken.Email)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+
+	return nil
+}
+
+// ForgotPassword mails a reset link when email belongs to an account with a
+// verified address. It reports success either way so it cannot be used to
+// find out which addresses are registered.
+func (u *Usecase) ForgotPassword(email string) error {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return err
+	}
+
+	user, err := u.dbRsc.GetUserByEmail(email)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	if user.UserID == 0 || !user.EmailVerified {
+		return nil
+	}
+
+	err = u.sendPasswordReset(user)
+	if err != nil {
+		log.Println(err)
+	}
+
+	return nil
+}
+
+func (u *Usecase) sendPasswordReset(user model.User) error {
+	token, err := u.createAccountToken(user.UserID, model.TokenPurposeResetPassword, user.Email, resetPasswordTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return u.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. Open the link below within an hour to choose a new one:\n\n%s\n\nIf it wasn't you, you can ignore this email.\n",
+			user.Username, u.link("/password/reset", token)),
+	})
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword and
+// signs the account out everywhere.
+func (u *Usecase) ResetPassword(token, newPassword, confirmPassword string, client model.ClientInfo) error {
+	if newPassword == "" {
+		return errors.New("new password cannot be empty")
+	}
+	if confirmPassword != newPassword {
+		return errors.New("confirm password is not matched")
+	}
+
+	accountToken, err := u.dbRsc.UseAccountToken(hashToken(token), model.TokenPurposeResetPassword, time.Now())
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	hashedPassword, err := u.hasher.Hash(newPassword)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+
+	err = u.dbRsc.UpdateUserPassword(accountToken.UserID, hashedPassword)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+
+	err = u.dbRsc.SetPasswordResetRequired(accountToken.UserID, false)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	u.record(accountToken.UserID, 0, model.AuditPasswordReset, client, nil)
+
+	return u.revokeAllSessions(accountToken.UserID)
+}
+
+func (u *Usecase) sendVerification(userID int64, username, email string) error {
+	token, err := u.createAccountToken(userID, model.TokenPurposeVerifyEmail, email, verifyEmailTokenTTL)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+
+	err = u.mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n",
+			username, u.link("/email/verify", token)),
+	})
+	if err != nil {
+		log.Println(err)
+		return errors.New("failed to send verification email")
+	}
+
+	return nil
+}
+
+func (u *Usecase) createAccountToken(userID int64, purpose, email string, ttl time.Duration) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = u.dbRsc.CreateAccountToken(model.AccountToken{
+		TokenHash: hashToken(token),
+		UserID:    userID,
+		Purpose:   purpose,
+		Email:     email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (u *Usecase) link(path, token string) string {
+	return u.linkBaseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func normalizeEmail(email string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || addr.Name != "" {
+		return "", ErrInvalidEmail
+	}
+	return addr.Address, nil
+}
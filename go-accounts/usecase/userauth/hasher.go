@@ -0,0 +1,284 @@
+package userauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lolmourne/go-accounts/model"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgoBcrypt   = "bcrypt"
+	AlgoArgon2id = "argon2id"
+	AlgoSHA256   = "sha256"
+)
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// PasswordHasher hashes passwords into a self describing encoded string.
+// The encoded string records the algorithm and its parameters so a hash
+// can be verified after the configured parameters change.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	NeedsRehash(encoded string) bool
+}
+
+// NewPasswordHasher returns the hasher configured in cfg. Hashes produced by
+// the other supported algorithms, including legacy salted sha256, can still
+// be verified and are reported as needing a rehash.
+func NewPasswordHasher(cfg model.PasswordCfg) PasswordHasher {
+	bcryptHasher := NewBcryptHasher(cfg.BcryptCost)
+	argonHasher := NewArgon2idHasher(Argon2Params{
+		Memory:      cfg.Argon2Memory,
+		Iterations:  cfg.Argon2Iterations,
+		Parallelism: cfg.Argon2Parallelism,
+	})
+
+	primary := argonHasher
+	if cfg.Algorithm == AlgoBcrypt {
+		primary = bcryptHasher
+	}
+
+	return &multiHasher{
+		primary: primary,
+		hashers: map[string]PasswordHasher{
+			AlgoBcrypt:   bcryptHasher,
+			AlgoArgon2id: argonHasher,
+			AlgoSHA256:   legacySHA256Hasher{},
+		},
+	}
+}
+
+// EncodeLegacySHA256 converts a password and salt pair stored by the old
+// sha256 scheme into the encoded format understood by PasswordHasher.
+func EncodeLegacySHA256(hexHash, salt string) string {
+	return fmt.Sprintf("$%s$%s$%s", AlgoSHA256, salt, hexHash)
+}
+
+func hashAlgorithm(encoded string) string {
+	switch {
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		return AlgoBcrypt
+	case strings.HasPrefix(encoded, "$"+AlgoArgon2id+"$"):
+		return AlgoArgon2id
+	case strings.HasPrefix(encoded, "$"+AlgoSHA256+"$"):
+		return AlgoSHA256
+	}
+	return ""
+}
+
+type multiHasher struct {
+	primary PasswordHasher
+	hashers map[string]PasswordHasher
+}
+
+func (m *multiHasher) Hash(password string) (string, error) {
+	return m.primary.Hash(password)
+}
+
+func (m *multiHasher) Verify(password, encoded string) (bool, error) {
+	h, ok := m.hashers[hashAlgorithm(encoded)]
+	if !ok {
+		return false, ErrUnknownHashFormat
+	}
+	return h.Verify(password, encoded)
+}
+
+func (m *multiHasher) NeedsRehash(encoded string) bool {
+	if m.hashers[hashAlgorithm(encoded)] != m.primary {
+		return true
+	}
+	return m.primary.NeedsRehash(encoded)
+}
+
+type BcryptHasher struct {
+	cost int
+}
+
+func NewBcryptHasher(cost int) PasswordHasher {
+	if cost < bcrypt.MinCost {
+		cost = bcrypt.DefaultCost
+	}
+	return &BcryptHasher{
+		cost: cost,
+	}
+}
+
+func (b *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (b *BcryptHasher) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (b *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return true
+	}
+	return cost != b.cost
+}
+
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+type Argon2idHasher struct {
+	params Argon2Params
+}
+
+func NewArgon2idHasher(params Argon2Params) PasswordHasher {
+	if params.Memory == 0 {
+		params.Memory = 64 * 1024
+	}
+	if params.Iterations == 0 {
+		params.Iterations = 3
+	}
+	if params.Parallelism == 0 {
+		params.Parallelism = 2
+	}
+	if params.SaltLength == 0 {
+		params.SaltLength = 16
+	}
+	if params.KeyLength == 0 {
+		params.KeyLength = 32
+	}
+	return &Argon2idHasher{
+		params: params,
+	}
+}
+
+// Hash returns a PHC formatted string:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func (a *Argon2idHasher) Hash(password string) (string, error) {
+	salt, err := randomBytes(int(a.params.SaltLength))
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.params.Iterations, a.params.Memory, a.params.Parallelism, a.params.KeyLength)
+
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		AlgoArgon2id,
+		argon2.Version,
+		a.params.Memory,
+		a.params.Iterations,
+		a.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (a *Argon2idHasher) Verify(password, encoded string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	otherKey := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+}
+
+func (a *Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != a.params.Memory ||
+		params.Iterations != a.params.Iterations ||
+		params.Parallelism != a.params.Parallelism ||
+		uint32(len(salt)) != a.params.SaltLength ||
+		uint32(len(key)) != a.params.KeyLength
+}
+
+func decodeArgon2id(encoded string) (Argon2Params, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != AlgoArgon2id {
+		return Argon2Params{}, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+	if version != argon2.Version {
+		return Argon2Params{}, nil, nil, errors.New("incompatible argon2 version")
+	}
+
+	var params Argon2Params
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+
+	return params, salt, key, nil
+}
+
+// legacySHA256Hasher only verifies hashes created before PasswordHasher was
+// introduced: hex(sha256(password + salt)). It never produces new hashes.
+type legacySHA256Hasher struct{}
+
+func (legacySHA256Hasher) Hash(password string) (string, error) {
+	return "", errors.New("sha256 is no longer supported for new passwords")
+}
+
+func (legacySHA256Hasher) Verify(password, encoded string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[1] != AlgoSHA256 {
+		return false, ErrUnknownHashFormat
+	}
+	salt, hexHash := parts[2], parts[3]
+
+	sum := sha256.Sum256([]byte(password + salt))
+	return subtle.ConstantTimeCompare([]byte(fmt.Sprintf("%x", sum)), []byte(hexHash)) == 1, nil
+}
+
+func (legacySHA256Hasher) NeedsRehash(encoded string) bool {
+	return true
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,220 @@
+package userauth
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lolmourne/go-accounts/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fast parameters, the tests check the encoding and not the cost
+var testArgon2Params = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}
+
+func legacyHex(password, salt string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(password+salt)))
+}
+
+func legacyHash(password, salt string) string {
+	return EncodeLegacySHA256(legacyHex(password, salt), salt)
+}
+
+func TestHasherRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher PasswordHasher
+		prefix string
+	}{
+		{"bcrypt", NewBcryptHasher(bcrypt.MinCost), "$2a$"},
+		{"argon2id", NewArgon2idHasher(testArgon2Params), "$argon2id$v=19$m=1024,t=1,p=1$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := tt.hasher.Hash("correct horse")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(encoded, tt.prefix) {
+				t.Fatalf("encoded %q, want prefix %q", encoded, tt.prefix)
+			}
+
+			for password, want := range map[string]bool{
+				"correct horse":  true,
+				"correct horse ": false,
+				"":               false,
+			} {
+				ok, err := tt.hasher.Verify(password, encoded)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ok != want {
+					t.Errorf("Verify(%q) = %v, want %v", password, ok, want)
+				}
+			}
+
+			if tt.hasher.NeedsRehash(encoded) {
+				t.Error("fresh hash needs a rehash")
+			}
+		})
+	}
+}
+
+func TestArgon2idSaltsEveryHash(t *testing.T) {
+	hasher := NewArgon2idHasher(testArgon2Params)
+	first, _ := hasher.Hash("password")
+	second, _ := hasher.Hash("password")
+	if first == second {
+		t.Fatal("two hashes of the same password are equal")
+	}
+}
+
+func TestArgon2idNeedsRehash(t *testing.T) {
+	encoded, err := NewArgon2idHasher(testArgon2Params).Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		params Argon2Params
+		want   bool
+	}{
+		{"same params", testArgon2Params, false},
+		{"more memory", Argon2Params{Memory: 2048, Iterations: 1, Parallelism: 1}, true},
+		{"more iterations", Argon2Params{Memory: 1024, Iterations: 2, Parallelism: 1}, true},
+		{"more parallelism", Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 2}, true},
+		{"longer key", Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, KeyLength: 64}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewArgon2idHasher(tt.params).NeedsRehash(encoded); got != tt.want {
+				t.Errorf("NeedsRehash = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArgon2idRejectsMalformed(t *testing.T) {
+	hasher := NewArgon2idHasher(testArgon2Params)
+	for _, encoded := range []string{
+		"",
+		"$argon2id$",
+		"$argon2id$v=18$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!$a2V5",
+		"$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$a2V5",
+	} {
+		ok, err := hasher.Verify("password", encoded)
+		if ok || err == nil {
+			t.Errorf("Verify(%q) = %v, %v; want an error", encoded, ok, err)
+		}
+		if !hasher.NeedsRehash(encoded) {
+			t.Errorf("NeedsRehash(%q) = false", encoded)
+		}
+	}
+}
+
+func TestLegacySHA256Verify(t *testing.T) {
+	encoded := legacyHash("password", "pepper")
+
+	tests := []struct {
+		name     string
+		password string
+		encoded  string
+		want     bool
+		wantErr  bool
+	}{
+		{"match", "password", encoded, true, false},
+		{"wrong password", "Password", encoded, false, false},
+		{"other salt", "password", EncodeLegacySHA256(legacyHex("password", "pepper"), "salt"), false, false},
+		{"empty salt", "passwordpepper", EncodeLegacySHA256(legacyHex("passwordpepper", ""), ""), true, false},
+		{"not legacy", "password", "$argon2id$v=19$m=1$a$b", false, true},
+		{"truncated", "password", "$sha256$pepper", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := legacySHA256Hasher{}.Verify(tt.password, tt.encoded)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ok != tt.want {
+				t.Errorf("Verify = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+
+	if _, err := (legacySHA256Hasher{}).Hash("password"); err == nil {
+		t.Error("legacy hasher produced a new hash")
+	}
+}
+
+func TestMultiHasher(t *testing.T) {
+	cfg := model.PasswordCfg{
+		Algorithm:         AlgoArgon2id,
+		Argon2Memory:      testArgon2Params.Memory,
+		Argon2Iterations:  testArgon2Params.Iterations,
+		Argon2Parallelism: testArgon2Params.Parallelism,
+		BcryptCost:        bcrypt.MinCost,
+	}
+	hasher := NewPasswordHasher(cfg)
+
+	argonHash, err := hasher.Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := NewBcryptHasher(bcrypt.MinCost).Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		encoded    string
+		wantOK     bool
+		wantErr    error
+		wantRehash bool
+		wantAlgo   string
+	}{
+		{"primary", argonHash, true, nil, false, AlgoArgon2id},
+		{"bcrypt", bcryptHash, true, nil, true, AlgoBcrypt},
+		{"legacy", legacyHash("password", "salt"), true, nil, true, AlgoSHA256},
+		{"unknown", "plaintext", false, ErrUnknownHashFormat, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hashAlgorithm(tt.encoded); got != tt.wantAlgo {
+				t.Errorf("hashAlgorithm = %q, want %q", got, tt.wantAlgo)
+			}
+			ok, err := hasher.Verify("password", tt.encoded)
+			if err != tt.wantErr || ok != tt.wantOK {
+				t.Errorf("Verify = %v, %v; want %v, %v", ok, err, tt.wantOK, tt.wantErr)
+			}
+			if got := hasher.NeedsRehash(tt.encoded); got != tt.wantRehash {
+				t.Errorf("NeedsRehash = %v, want %v", got, tt.wantRehash)
+			}
+		})
+	}
+}
+
+func TestMultiHasherBcryptPrimary(t *testing.T) {
+	hasher := NewPasswordHasher(model.PasswordCfg{Algorithm: AlgoBcrypt, BcryptCost: bcrypt.MinCost})
+
+	encoded, err := hasher.Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashAlgorithm(encoded) != AlgoBcrypt {
+		t.Fatalf("hashed with %q", hashAlgorithm(encoded))
+	}
+	if hasher.NeedsRehash(encoded) {
+		t.Error("primary hash needs a rehash")
+	}
+
+	costlier := NewPasswordHasher(model.PasswordCfg{Algorithm: AlgoBcrypt, BcryptCost: bcrypt.MinCost + 1})
+	if !costlier.NeedsRehash(encoded) {
+		t.Error("hash with a lower cost does not need a rehash")
+	}
+}
@@ -1,26 +1,155 @@
 package userauth
 
 import (
+	"log"
+	"strings"
+	"time"
+
+	authClient "github.com/lolmourne/go-accounts/client/userauth"
+	"github.com/lolmourne/go-accounts/model"
 	"github.com/lolmourne/go-accounts/resource/acc"
+	"github.com/lolmourne/go-accounts/resource/audit"
+	"github.com/lolmourne/go-accounts/resource/events"
+	"github.com/lolmourne/go-accounts/resource/mailer"
+	"github.com/lolmourne/go-accounts/resource/oidc"
+)
+
+const (
+	defaultAccessTokenTTL   = 15 * time.Minute
+	defaultRefreshTokenTTL  = 30 * 24 * time.Hour
+	defaultUsernameCooldown = 30 * 24 * time.Hour
+	defaultUsernameHold     = 90 * 24 * time.Hour
+	defaultDeletionGrace    = 14 * 24 * time.Hour
 )
 
 type Usecase struct {
-	dbRsc      acc.DBItf
-	signingKey []byte
+	dbRsc  acc.DBItf
+	hasher PasswordHasher
+	// dummyHash is verified against when there is no hash to check, so
+	// unknown usernames take as long to reject as wrong passwords.
+	dummyHash        string
+	mailer           mailer.Mailer
+	publisher        events.Publisher
+	auditRsc         audit.DBItf
+	keys             *KeySet
+	accessTokenTTL   time.Duration
+	refreshTokenTTL  time.Duration
+	totpIssuer       string
+	linkBaseURL      string
+	usernameCooldown time.Duration
+	usernameHold     time.Duration
+	deletionGrace    time.Duration
+	oidcProviders    map[string]oidc.Provider
+	oidcCfgs         map[string]model.OIDCCfg
 }
 
 type UsecaseItf interface {
-	Register(username, password, confirmPassword string) error
-	Login(username, password string) (string, error)
-	ValidateSession(accessToken string) (int64, error)
-	GenerateJWT(userID int64, profilePic string) (string, error)
-	ChangeUsername(userID int64, username string) error
-	ChangePassword(userID int64, oldPassword, newPassword, confirmPassword string) error
+	Register(username, password, confirmPassword, email string) error
+	Login(username, password string, client model.ClientInfo) (model.UserToken, error)
+	RefreshToken(refreshToken string, client model.ClientInfo) (model.UserToken, error)
+	Logout(sessionID string) error
+	GetSessions(userID int64, currentSessionID string) ([]model.Session, error)
+	RevokeSession(userID int64, sessionID string) error
+	EnrollTOTP(userID int64) (model.TOTPEnrollment, error)
+	ConfirmTOTP(userID int64, code string) ([]string, error)
+	DisableTOTP(userID int64, password, sessionID string, client model.ClientInfo) error
+	LoginTwoFactor(challengeToken, code string, client model.ClientInfo) (model.UserToken, error)
+	ChallengeUserID(challengeToken string) (int64, error)
+	ChangeEmail(userID int64, password, sessionID, email string, client model.ClientInfo) error
+	VerifyEmail(token string) error
+	ForgotPassword(email string) error
+	ResetPassword(token, newPassword, confirmPassword string, client model.ClientInfo) error
+	ValidateSession(accessToken string) (model.AccessClaims, error)
+	GenerateJWT(user model.User, sessionID string) (string, error)
+	JWKS() authClient.JWKS
+	ChangeUsername(userID int64, username string, client model.ClientInfo) error
+	ChangeProfilePic(userID int64, profilePic string, client model.ClientInfo) error
+	CheckUsername(username string) error
+	DeactivateAccount(userID int64, password, sessionID string) error
+	DeleteAccount(userID int64, password, sessionID string) (time.Time, error)
+	ReactivateAccount(username, password string, client model.ClientInfo) (model.UserToken, error)
+	PurgeDeletedAccounts() ([]model.User, error)
+	RetryPendingPurges() (int, error)
+	ConfirmPurge(userID int64, service string) error
+	ChangePassword(userID int64, oldPassword, newPassword, confirmPassword string, client model.ClientInfo) error
+	ListUsers(role, cursor string, limit int) (model.UserPage, error)
+	ChangeRole(actorID, userID int64, role string, client model.ClientInfo) error
+	SuspendUser(actorID, userID int64, client model.ClientInfo) error
+	UnsuspendUser(actorID, userID int64, client model.ClientInfo) error
+	ForcePasswordReset(actorID, userID int64, client model.ClientInfo) error
+	GetAuditLog(filter model.AuditFilter, cursor string) (model.AuditPage, error)
+	GetActivity(userID int64, cursor string, limit int) (model.AuditPage, error)
+	OIDCProviders() []string
+	StartOIDC(provider string, linkUserID int64) (string, string, error)
+	FinishOIDC(provider, code, state, flowToken string, client model.ClientInfo) (model.OIDCResult, error)
+	GetIdentities(userID int64) ([]model.Identity, error)
+	UnlinkIdentity(userID int64, provider string, client model.ClientInfo) error
+	CreateAccessToken(actorID, userID int64, name string, scopes []string, expiresIn time.Duration, client model.ClientInfo) (model.CreatedAccessToken, error)
+	GetAccessTokens(actorID, userID int64) ([]model.PersonalAccessToken, error)
+	RevokeAccessToken(actorID, userID int64, tokenID string, client model.ClientInfo) error
+	CreateBot(ownerID int64, username, displayName string, client model.ClientInfo) (model.User, error)
+	GetBots(ownerID int64) ([]model.User, error)
+	DeleteBot(ownerID, botID int64, client model.ClientInfo) error
 }
 
-func NewUsecase(dbRsc acc.DBItf, signingKey string) UsecaseItf {
+func NewUsecase(dbRsc acc.DBItf, hasher PasswordHasher, keys *KeySet, mailer mailer.Mailer, publisher events.Publisher, auditRsc audit.DBItf, cfg model.Config) UsecaseItf {
+	jwtCfg := cfg.JWT
+	accessTokenTTL := time.Duration(jwtCfg.AccessTokenTTL) * time.Second
+	if accessTokenTTL <= 0 {
+		accessTokenTTL = defaultAccessTokenTTL
+	}
+	refreshTokenTTL := time.Duration(jwtCfg.RefreshTokenTTL) * time.Second
+	if refreshTokenTTL <= 0 {
+		refreshTokenTTL = defaultRefreshTokenTTL
+	}
+
+	totpIssuer := cfg.TOTP.Issuer
+	if totpIssuer == "" {
+		totpIssuer = "go-chat"
+	}
+
+	usernameCooldown := time.Duration(cfg.Username.ChangeCooldown) * time.Second
+	if usernameCooldown <= 0 {
+		usernameCooldown = defaultUsernameCooldown
+	}
+	usernameHold := time.Duration(cfg.Username.HoldPeriod) * time.Second
+	if usernameHold <= 0 {
+		usernameHold = defaultUsernameHold
+	}
+
+	deletionGrace := time.Duration(cfg.Account.DeletionGracePeriod) * time.Second
+	if deletionGrace <= 0 {
+		deletionGrace = defaultDeletionGrace
+	}
+
+	oidcProviders := make(map[string]oidc.Provider, len(cfg.OIDC))
+	oidcCfgs := make(map[string]model.OIDCCfg, len(cfg.OIDC))
+	for _, providerCfg := range cfg.OIDC {
+		oidcProviders[providerCfg.Name] = oidc.NewProvider(providerCfg)
+		oidcCfgs[providerCfg.Name] = providerCfg
+	}
+
+	dummyHash, err := hasher.Hash("dummy password")
+	if err != nil {
+		log.Println("cannot hash the dummy password", err)
+	}
+
 	return &Usecase{
-		dbRsc:      dbRsc,
-		signingKey: []byte(signingKey),
+		dbRsc:            dbRsc,
+		hasher:           hasher,
+		dummyHash:        dummyHash,
+		mailer:           mailer,
+		publisher:        publisher,
+		auditRsc:         auditRsc,
+		keys:             keys,
+		accessTokenTTL:   accessTokenTTL,
+		refreshTokenTTL:  refreshTokenTTL,
+		totpIssuer:       totpIssuer,
+		linkBaseURL:      strings.TrimRight(cfg.Mail.LinkBaseURL, "/"),
+		usernameCooldown: usernameCooldown,
+		usernameHold:     usernameHold,
+		deletionGrace:    deletionGrace,
+		oidcProviders:    oidcProviders,
+		oidcCfgs:         oidcCfgs,
 	}
 }
@@ -0,0 +1,135 @@
+package userauth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	authClient "github.com/lolmourne/go-accounts/client/userauth"
+	"github.com/lolmourne/go-accounts/model"
+)
+
+type signingKey struct {
+	kid     string
+	private crypto.Signer
+	method  jwt.SigningMethod
+}
+
+// KeySet holds every key configured for access tokens. All keys are published
+// and accepted for verification, only the signing key issues new tokens, so
+// a key can be rotated in ahead of use and retired after its tokens expire.
+type KeySet struct {
+	signing *signingKey
+	keys    map[string]*signingKey
+}
+
+func NewKeySet(cfg model.JWTCfg) (*KeySet, error) {
+	ks := &KeySet{
+		keys: make(map[string]*signingKey),
+	}
+
+	for _, keyCfg := range cfg.Keys {
+		key, err := loadSigningKey(keyCfg)
+		if err != nil {
+			return nil, fmt.Errorf("load jwt key %s: %v", keyCfg.KID, err)
+		}
+		ks.keys[key.kid] = key
+	}
+
+	// a key generated at startup would sign out every user on restart and
+	// disagree between instances
+	if len(ks.keys) == 0 {
+		return nil, errors.New("no jwt keys configured, set jwt.keys and jwt.signing_kid")
+	}
+
+	signing, ok := ks.keys[cfg.SigningKID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q is not configured", cfg.SigningKID)
+	}
+	ks.signing = signing
+
+	return ks, nil
+}
+
+func loadSigningKey(cfg model.JWTKeyCfg) (*signingKey, error) {
+	pemBytes, err := ioutil.ReadFile(cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch priv := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &signingKey{
+			kid:     cfg.KID,
+			private: priv,
+			method:  jwt.SigningMethodRS256,
+		}, nil
+	case ed25519.PrivateKey:
+		return &signingKey{
+			kid:     cfg.KID,
+			private: priv,
+			method:  authClient.SigningMethodEdDSA,
+		}, nil
+	}
+
+	return nil, errors.New("unsupported key type, expected RSA or Ed25519")
+}
+
+func (ks *KeySet) JWKS() authClient.JWKS {
+	jwks := authClient.JWKS{
+		Keys: make([]authClient.JWK, 0, len(ks.keys)),
+	}
+
+	for _, key := range ks.keys {
+		jwk, err := authClient.NewJWK(key.kid, key.private.Public())
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
+
+func (ks *KeySet) sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.method, claims)
+	token.Header["kid"] = ks.signing.kid
+
+	return token.SignedString(ks.signing.private)
+}
+
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.private.Public(), nil
+}
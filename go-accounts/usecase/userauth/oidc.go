@@ -0,0 +1,443 @@
+package userauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/lolmourne/go-accounts/model"
+	"github.com/lolmourne/go-accounts/resource/acc"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	oidcFlowTokenTTL = 10 * time.Minute
+	// generated usernames leave room for a numeric suffix
+	maxGeneratedUsernameLen = 24
+	usernameAttempts        = 5
+	maxOIDCDisplayNameLen   = 50
+
+	tokenTypeOIDCFlow = "oidc_flow"
+)
+
+var (
+	ErrUnknownProvider     = errors.New("unknown identity provider")
+	ErrInvalidOIDCFlow     = errors.New("sign in with the identity provider expired or was tampered with, try again")
+	ErrOIDCExchange        = errors.New("identity provider rejected the sign in")
+	ErrOIDCSignupDisabled  = errors.New("no account is linked to this identity and sign up is disabled for this provider")
+	ErrOIDCEmailTaken      = errors.New("email is used by an existing account, sign in and link the provider from your settings")
+	ErrIdentityLinked      = errors.New("identity is already linked to another account")
+	ErrProviderLinked      = errors.New("account is already linked to this provider")
+	ErrIdentityNotFound    = errors.New("account is not linked to this provider")
+	ErrLastLoginMethod     = errors.New("cannot unlink the only way to sign in, set a password through the password reset first")
+	ErrUsernameUnavailable = errors.New("could not generate a free username")
+)
+
+type oidcFlow struct {
+	provider   string
+	state      string
+	nonce      string
+	verifier   string
+	linkUserID int64
+}
+
+// OIDCProviders returns the names of the configured identity providers.
+func (u *Usecase) OIDCProviders() []string {
+	names := make([]string, 0, len(u.oidcProviders))
+	for name := range u.oidcProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StartOIDC begins the authorization code flow with provider. The browser is
+// sent to authURL and has to bring flowToken back to the callback, it binds
+// state, nonce and the PKCE verifier to that browser. linkUserID is the
+// signed in user linking the provider, 0 to sign in.
+func (u *Usecase) StartOIDC(provider string, linkUserID int64) (string, string, error) {
+	p, ok := u.oidcProviders[provider]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+
+	flow := oidcFlow{
+		provider:   provider,
+		linkUserID: linkUserID,
+	}
+	for _, value := range []*string{&flow.state, &flow.nonce, &flow.verifier} {
+		token, err := randomToken()
+		if err != nil {
+			log.Println(err)
+			return "", "", errors.New("Internal Server Error")
+		}
+		*value = token
+	}
+
+	challenge := sha256.Sum256([]byte(flow.verifier))
+	authURL, err := p.AuthCodeURL(flow.state, flow.nonce, base64.RawURLEncoding.EncodeToString(challenge[:]))
+	if err != nil {
+		log.Println(err)
+		return "", "", errors.New("Internal Server Error")
+	}
+
+	flowToken, err := u.generateOIDCFlowToken(flow)
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, flowToken, nil
+}
+
+// FinishOIDC completes the flow started by StartOIDC. A sign in returns
+// tokens, signing up a new account when the identity is not linked yet; a
+// link flow returns the linked identity.
+func (u *Usecase) FinishOIDC(provider, code, state, flowToken string, client model.ClientInfo) (model.OIDCResult, error) {
+	p, ok := u.oidcProviders[provider]
+	if !ok {
+		return model.OIDCResult{}, ErrUnknownProvider
+	}
+
+	flow, err := u.parseOIDCFlowToken(flowToken)
+	if err != nil || flow.provider != provider || subtle.ConstantTimeCompare([]byte(flow.state), []byte(state)) != 1 {
+		return model.OIDCResult{}, ErrInvalidOIDCFlow
+	}
+
+	identity, err := p.Exchange(code, flow.verifier)
+	if err != nil {
+		log.Println(err)
+		return model.OIDCResult{}, ErrOIDCExchange
+	}
+	if subtle.ConstantTimeCompare([]byte(identity.Nonce), []byte(flow.nonce)) != 1 {
+		return model.OIDCResult{}, ErrInvalidOIDCFlow
+	}
+
+	if flow.linkUserID != 0 {
+		linked, err := u.linkIdentity(flow.linkUserID, identity, client)
+		if err != nil {
+			return model.OIDCResult{}, err
+		}
+		return model.OIDCResult{Identity: &linked}, nil
+	}
+
+	token, err := u.loginOIDC(identity, client)
+	if err != nil {
+		return model.OIDCResult{}, err
+	}
+	return model.OIDCResult{Token: &token}, nil
+}
+
+func (u *Usecase) loginOIDC(identity model.OIDCIdentity, client model.ClientInfo) (model.UserToken, error) {
+	cfg := u.oidcCfgs[identity.Provider]
+
+	userID, err := u.dbRsc.GetUserIDByIdentity(identity.Provider, identity.Subject)
+	if err != nil {
+		log.Println(err)
+		return model.UserToken{}, errors.New("Internal Server Error")
+	}
+
+	email := ""
+	if identity.EmailVerified {
+		email, _ = normalizeEmail(identity.Email)
+	}
+
+	if userID == 0 && email != "" {
+		owner, err := u.dbRsc.GetUserByEmail(email)
+		if err != nil {
+			log.Println(err)
+			return model.UserToken{}, errors.New("Internal Server Error")
+		}
+		if owner.UserID != 0 {
+			// only a verified address on both sides proves the same person
+			if !cfg.TrustEmail || !owner.EmailVerified {
+				return model.UserToken{}, ErrOIDCEmailTaken
+			}
+			_, err = u.linkIdentity(owner.UserID, identity, client)
+			if err != nil {
+				return model.UserToken{}, err
+			}
+			userID = owner.UserID
+		}
+	}
+
+	if userID == 0 {
+		if cfg.DisableSignup {
+			return model.UserToken{}, ErrOIDCSignupDisabled
+		}
+		userID, err = u.provisionOIDCUser(identity, email)
+		if err != nil {
+			return model.UserToken{}, err
+		}
+	}
+
+	user, err := u.dbRsc.GetUserByUserID(userID)
+	if err != nil || user.UserID == 0 || user.Deleted {
+		return model.UserToken{}, ErrInvalidCredentials
+	}
+	if user.DeactivatedAt != nil {
+		return model.UserToken{}, ErrAccountDeactivated
+	}
+	err = checkAccountUsable(user)
+	if err != nil {
+		return model.UserToken{}, err
+	}
+
+	return u.startSession(user, client)
+}
+
+// provisionOIDCUser signs up a user without a password for identity. email
+// is only set when the provider verified it.
+func (u *Usecase) provisionOIDCUser(identity model.OIDCIdentity, email string) (int64, error) {
+	var user model.User
+	for _, username := range u.usernameCandidates(identity) {
+		if u.CheckUsername(username) != nil {
+			continue
+		}
+
+		err := u.dbRsc.Register(username, "", "", email)
+		if err == acc.ErrDuplicate {
+			// taken since the check, or the email was registered meanwhile
+			continue
+		}
+		if err != nil {
+			log.Println(err)
+			return 0, errors.New("Internal Server Error")
+		}
+
+		user, err = u.dbRsc.GetUserByUserName(username)
+		if err != nil || user.UserID == 0 {
+			log.Println("cannot load provisioned user", username, err)
+			return 0, errors.New("Internal Server Error")
+		}
+		break
+	}
+	if user.UserID == 0 {
+		return 0, ErrUsernameUnavailable
+	}
+
+	err := u.dbRsc.CreateIdentity(model.Identity{
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		UserID:    user.UserID,
+		Email:     identity.Email,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		log.Println(err)
+		return 0, errors.New("Internal Server Error")
+	}
+
+	if email != "" {
+		err = u.dbRsc.MarkEmailVerified(user.UserID, email)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	displayName := strings.TrimSpace(identity.Name)
+	if displayName != "" {
+		if utf8.RuneCountInString(displayName) > maxOIDCDisplayNameLen {
+			displayName = string([]rune(displayName)[:maxOIDCDisplayNameLen])
+		}
+		err = u.dbRsc.UpdateUserProfile(user.UserID, model.ProfileUpdate{DisplayName: &displayName})
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	return user.UserID, nil
+}
+
+// usernameCandidates derives usernames from the preferred username, the
+// email or the name asserted by the provider, the plain one first and then
+// with random digits appended.
+func (u *Usecase) usernameCandidates(identity model.OIDCIdentity) []string {
+	base := ""
+	emailLocal := identity.Email
+	if at := strings.LastIndex(emailLocal, "@"); at >= 0 {
+		emailLocal = emailLocal[:at]
+	}
+	for _, source := range []string{identity.PreferredUsername, emailLocal, identity.Name} {
+		base = sanitizeUsername(source)
+		if len(base) >= minUsernameLen {
+			break
+		}
+	}
+	if len(base) < minUsernameLen {
+		base = "user"
+	}
+
+	candidates := []string{base}
+	for i := 0; i < usernameAttempts; i++ {
+		raw, err := randomBytes(4)
+		if err != nil {
+			log.Println(err)
+			break
+		}
+		candidates = append(candidates, fmt.Sprintf("%s%04d", base, binary.BigEndian.Uint32(raw)%10000))
+	}
+	return candidates
+}
+
+// sanitizeUsername maps s onto the username charset, runs of other
+// characters become a single underscore.
+func sanitizeUsername(s string) string {
+	var b strings.Builder
+	separator := false
+	for _, r := range s {
+		switch {
+		case r < utf8.RuneSelf && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'):
+			if separator && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+			separator = false
+		default:
+			separator = true
+		}
+		if b.Len() >= maxGeneratedUsernameLen {
+			break
+		}
+	}
+
+	name := b.String()
+	if len(name) > maxGeneratedUsernameLen {
+		name = name[:maxGeneratedUsernameLen]
+	}
+	return strings.TrimRight(name, "_")
+}
+
+// linkIdentity links identity to userID, linking it again to the same user
+// is a no-op.
+func (u *Usecase) linkIdentity(userID int64, identity model.OIDCIdentity, client model.ClientInfo) (model.Identity, error) {
+	linked := model.Identity{
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		UserID:    userID,
+		Email:     identity.Email,
+		CreatedAt: time.Now(),
+	}
+
+	owner, err := u.dbRsc.GetUserIDByIdentity(identity.Provider, identity.Subject)
+	if err != nil {
+		log.Println(err)
+		return model.Identity{}, errors.New("Internal Server Error")
+	}
+	if owner == userID {
+		return linked, nil
+	}
+	if owner != 0 {
+		return model.Identity{}, ErrIdentityLinked
+	}
+
+	err = u.dbRsc.CreateIdentity(linked)
+	if err == acc.ErrDuplicate {
+		return model.Identity{}, ErrProviderLinked
+	}
+	if err != nil {
+		log.Println(err)
+		return model.Identity{}, errors.New("Internal Server Error")
+	}
+
+	u.record(userID, userID, model.AuditIdentityLink, client, map[string]string{"provider": identity.Provider})
+	return linked, nil
+}
+
+func (u *Usecase) GetIdentities(userID int64) ([]model.Identity, error) {
+	identities, err := u.dbRsc.GetIdentities(userID)
+	if err != nil {
+		log.Println(err)
+		return nil, errors.New("Internal Server Error")
+	}
+	return identities, nil
+}
+
+// UnlinkIdentity removes the identity from provider, unless it is the only
+// way left for the user to sign in.
+func (u *Usecase) UnlinkIdentity(userID int64, provider string, client model.ClientInfo) error {
+	identities, err := u.GetIdentities(userID)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, identity := range identities {
+		if identity.Provider == provider {
+			found = true
+		}
+	}
+	if !found {
+		return ErrIdentityNotFound
+	}
+
+	user, err := u.dbRsc.GetUserByUserID(userID)
+	if err != nil || user.UserID == 0 {
+		return ErrUserNotFound
+	}
+	if user.Password == "" && len(identities) == 1 {
+		return ErrLastLoginMethod
+	}
+
+	err = u.dbRsc.DeleteIdentity(userID, provider)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+
+	u.record(userID, userID, model.AuditIdentityUnlink, client, map[string]string{"provider": provider})
+	return nil
+}
+
+func (u *Usecase) generateOIDCFlowToken(flow oidcFlow) (string, error) {
+	now := time.Now()
+	tokenClaim := jwt.MapClaims{}
+	tokenClaim["typ"] = tokenTypeOIDCFlow
+	tokenClaim["provider"] = flow.provider
+	tokenClaim["state"] = flow.state
+	tokenClaim["nonce"] = flow.nonce
+	tokenClaim["verifier"] = flow.verifier
+	tokenClaim["link_user_id"] = flow.linkUserID
+	tokenClaim["iat"] = now.Unix()
+	tokenClaim["exp"] = now.Add(oidcFlowTokenTTL).Unix()
+	tokenClaim["jti"] = uuid.NewV4().String()
+
+	tokenString, err := u.keys.sign(tokenClaim)
+	if err != nil {
+		log.Println(err)
+		return "", errors.New("Internal Server Error")
+	}
+	return tokenString, nil
+}
+
+func (u *Usecase) parseOIDCFlowToken(flowToken string) (oidcFlow, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(flowToken, claims, u.keys.keyFunc)
+	if err != nil {
+		return oidcFlow{}, err
+	}
+
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) || claims["typ"] != tokenTypeOIDCFlow {
+		return oidcFlow{}, ErrInvalidOIDCFlow
+	}
+
+	flow := oidcFlow{}
+	flow.provider, _ = claims["provider"].(string)
+	flow.state, _ = claims["state"].(string)
+	flow.nonce, _ = claims["nonce"].(string)
+	flow.verifier, _ = claims["verifier"].(string)
+	linkUserID, _ := claims["link_user_id"].(float64)
+	flow.linkUserID = int64(linkUserID)
+	if flow.state == "" || flow.nonce == "" || flow.verifier == "" {
+		return oidcFlow{}, ErrInvalidOIDCFlow
+	}
+	return flow, nil
+}
@@ -0,0 +1,111 @@
+package userauth
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/lolmourne/go-accounts/model"
+	uuid "github.com/satori/go.uuid"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+func (u *Usecase) GetSessions(userID int64, currentSessionID string) ([]model.Session, error) {
+	sessions, err := u.dbRsc.GetSessionsByUserID(userID)
+	if err != nil {
+		log.Println(err)
+		return nil, errors.New("Internal Server Error")
+	}
+
+	// a session whose last refresh token expired can no longer be used
+	activeSince := time.Now().Add(-u.refreshTokenTTL)
+	result := make([]model.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if session.LastSeenAt.Before(activeSince) {
+			continue
+		}
+		session.Current = session.SessionID == currentSessionID
+		result = append(result, session)
+	}
+
+	return result, nil
+}
+
+func (u *Usecase) Logout(sessionID string) error {
+	if sessionID == "" {
+		return ErrSessionNotFound
+	}
+
+	return u.revokeSession(sessionID)
+}
+
+func (u *Usecase) RevokeSession(userID int64, sessionID string) error {
+	session, err := u.dbRsc.GetSession(sessionID)
+	if err != nil || session.UserID != userID {
+		return ErrSessionNotFound
+	}
+
+	if session.RevokedAt != nil {
+		return nil
+	}
+
+	return u.revokeSession(sessionID)
+}
+
+// createSession records a new login and returns its id, which is also used
+// as the refresh token family id.
+func (u *Usecase) createSession(userID int64, client model.ClientInfo) (string, error) {
+	now := time.Now()
+	session := model.Session{
+		SessionID:  uuid.NewV4().String(),
+		UserID:     userID,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+
+	err := u.dbRsc.CreateSession(session)
+	if err != nil {
+		log.Println(err)
+		return "", errors.New("Internal Server Error")
+	}
+
+	return session.SessionID, nil
+}
+
+// revokeSession puts the session on the denylist so its access tokens are
+// rejected immediately and revokes its refresh tokens.
+func (u *Usecase) revokeSession(sessionID string) error {
+	err := u.dbRsc.RevokeSession(sessionID)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+
+	err = u.dbRsc.RevokeTokenFamily(sessionID)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+
+	return nil
+}
+
+func (u *Usecase) revokeAllSessions(userID int64) error {
+	sessions, err := u.dbRsc.GetSessionsByUserID(userID)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+
+	for _, session := range sessions {
+		err = u.revokeSession(session.SessionID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
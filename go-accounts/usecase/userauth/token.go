@@ -0,0 +1,143 @@
+package userauth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	authClient "github.com/lolmourne/go-accounts/client/userauth"
+	"github.com/lolmourne/go-accounts/model"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, session revoked")
+	// ErrInvalidAccessToken is returned for malformed, expired or revoked
+	// access tokens, other errors of ValidateSession are internal.
+	ErrInvalidAccessToken = errors.New("Invalid Token")
+)
+
+// RefreshToken exchanges a refresh token for a new access and refresh token
+// pair. The presented token is consumed; presenting it a second time means it
+// leaked, so the whole token family is revoked.
+func (u *Usecase) RefreshToken(refreshToken string, client model.ClientInfo) (model.UserToken, error) {
+	if refreshToken == "" {
+		return model.UserToken{}, ErrInvalidRefreshToken
+	}
+
+	tokenHash := hashToken(refreshToken)
+	stored, err := u.dbRsc.GetRefreshToken(tokenHash)
+	if err != nil {
+		return model.UserToken{}, ErrInvalidRefreshToken
+	}
+
+	if stored.RevokedAt != nil {
+		return model.UserToken{}, ErrInvalidRefreshToken
+	}
+
+	revoked, err := u.dbRsc.IsTokenFamilyRevoked(stored.FamilyID)
+	if err != nil {
+		log.Println(err)
+		return model.UserToken{}, errors.New("Internal Server Error")
+	}
+	if revoked {
+		return model.UserToken{}, ErrInvalidRefreshToken
+	}
+
+	if stored.UsedAt != nil {
+		u.revokeFamily(stored.FamilyID)
+		return model.UserToken{}, ErrRefreshTokenReused
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return model.UserToken{}, ErrInvalidRefreshToken
+	}
+
+	// the conditional update makes concurrent refreshes with the same token
+	// race for it; the loser is treated as a reuse
+	ok, err := u.dbRsc.MarkRefreshTokenUsed(tokenHash, time.Now())
+	if err != nil {
+		log.Println(err)
+		return model.UserToken{}, errors.New("Internal Server Error")
+	}
+	if !ok {
+		u.revokeFamily(stored.FamilyID)
+		return model.UserToken{}, ErrRefreshTokenReused
+	}
+
+	user, err := u.dbRsc.GetUserByUserID(stored.UserID)
+	if err != nil || user.UserID == 0 || checkAccountUsable(user) != nil {
+		return model.UserToken{}, ErrInvalidRefreshToken
+	}
+
+	err = u.dbRsc.TouchSession(stored.FamilyID, client.IPAddress, time.Now())
+	if err != nil {
+		log.Println(err)
+	}
+
+	return u.issueTokens(user, stored.FamilyID)
+}
+
+// issueTokens creates an access token and a new refresh token belonging to
+// familyID.
+func (u *Usecase) issueTokens(user model.User, familyID string) (model.UserToken, error) {
+	accessToken, err := u.GenerateJWT(user, familyID)
+	if err != nil {
+		return model.UserToken{}, err
+	}
+
+	refreshToken, err := randomToken()
+	if err != nil {
+		log.Println(err)
+		return model.UserToken{}, errors.New("Internal Server Error")
+	}
+
+	now := time.Now()
+	err = u.dbRsc.CreateRefreshToken(model.RefreshToken{
+		TokenHash: hashToken(refreshToken),
+		FamilyID:  familyID,
+		UserID:    user.UserID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(u.refreshTokenTTL),
+	})
+	if err != nil {
+		log.Println(err)
+		return model.UserToken{}, errors.New("Internal Server Error")
+	}
+
+	return model.UserToken{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(u.accessTokenTTL.Seconds()),
+	}, nil
+}
+
+func (u *Usecase) revokeFamily(familyID string) {
+	err := u.revokeSession(familyID)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// hashToken returns the value stored in place of a refresh or account token.
+// Both are 256 bit random values so a fast hash is sufficient.
+func hashToken(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
+
+func (u *Usecase) JWKS() authClient.JWKS {
+	return u.keys.JWKS()
+}
+
+// randomToken returns 256 random bits encoded for use in URLs.
+func randomToken() (string, error) {
+	raw, err := randomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
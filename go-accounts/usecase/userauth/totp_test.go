@@ -0,0 +1,219 @@
+package userauth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"regexp"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	authClient "github.com/lolmourne/go-accounts/client/userauth"
+	"github.com/lolmourne/go-accounts/resource/acc"
+)
+
+// rfc6238Secret is the SHA1 seed of the RFC 6238 test vectors,
+// "12345678901234567890" in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// the RFC lists 8 digit codes, 6 digit codes are their last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := totpCode(rfc6238Secret, totpStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("totpCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := totpStep(now)
+	code := func(offset int64) string {
+		c, err := totpCode(rfc6238Secret, step+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", rfc6238Secret, code(0), step, true},
+		{"previous step", rfc6238Secret, code(-1), step - 1, true},
+		{"next step", rfc6238Secret, code(1), step + 1, true},
+		{"surrounding spaces", rfc6238Secret, " " + code(0) + " ", step, true},
+		{"lower case secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", code(0), step, true},
+		{"two steps behind", rfc6238Secret, code(-2), 0, false},
+		{"two steps ahead", rfc6238Secret, code(2), 0, false},
+		{"too short", rfc6238Secret, code(0)[:5], 0, false},
+		{"too long", rfc6238Secret, code(0) + "0", 0, false},
+		{"empty", rfc6238Secret, "", 0, false},
+		{"invalid secret", "not base32!", "123456", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := matchTOTP(tt.secret, tt.code, now)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("matchTOTP = %d, %v; want %d, %v", gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != 20 {
+		t.Errorf("secret has %d bytes, want 20", len(raw))
+	}
+
+	other, _ := generateTOTPSecret()
+	if other == secret {
+		t.Error("two secrets are equal")
+	}
+}
+
+type recoveryCodeDB struct {
+	acc.DBItf
+	hashes []string
+}
+
+func (db *recoveryCodeDB) SaveRecoveryCodes(userID int64, hashes []string) error {
+	db.hashes = hashes
+	return nil
+}
+
+func TestRegenerateRecoveryCodes(t *testing.T) {
+	db := &recoveryCodeDB{}
+	u := &Usecase{dbRsc: db}
+
+	codes, err := u.regenerateRecoveryCodes(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount || len(db.hashes) != recoveryCodeCount {
+		t.Fatalf("got %d codes and %d hashes, want %d", len(codes), len(db.hashes), recoveryCodeCount)
+	}
+
+	format := regexp.MustCompile(`^[a-z2-7]{4}-[a-z2-7]{4}$`)
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q does not match %s", code, format)
+		}
+		if seen[code] {
+			t.Errorf("code %q generated twice", code)
+		}
+		seen[code] = true
+
+		if db.hashes[i] == code || db.hashes[i] != hashRecoveryCode(code) {
+			t.Errorf("code %q stored as %q", code, db.hashes[i])
+		}
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	want := hashRecoveryCode("abcd-efgh")
+	for _, code := range []string{"abcd-efgh", "ABCD-EFGH", " abcd-efgh\n"} {
+		if got := hashRecoveryCode(code); got != want {
+			t.Errorf("hashRecoveryCode(%q) differs from the canonical code", code)
+		}
+	}
+	if hashRecoveryCode("abcd-efgi") == want {
+		t.Error("different codes hash the same")
+	}
+}
+
+func testUsecase(t *testing.T) *Usecase {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &signingKey{kid: "test", private: priv, method: authClient.SigningMethodEdDSA}
+	return &Usecase{
+		keys: &KeySet{
+			signing: key,
+			keys:    map[string]*signingKey{key.kid: key},
+		},
+		accessTokenTTL: time.Minute,
+	}
+}
+
+func TestChallengeToken(t *testing.T) {
+	u := testUsecase(t)
+
+	challenge, err := u.generateChallengeToken(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID, err := u.parseChallengeToken(challenge)
+	if err != nil || userID != 42 {
+		t.Fatalf("parseChallengeToken = %d, %v; want 42", userID, err)
+	}
+
+	// a challenge must not pass as an access token and the other way round
+	_, err = u.ValidateSession(challenge)
+	if err == nil {
+		t.Error("challenge token accepted as an access token")
+	}
+
+	expired, err := u.keys.sign(jwt.MapClaims{
+		"user_id": 42,
+		"typ":     tokenTypeChallenge,
+		"exp":     time.Now().Add(-time.Second).Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := testUsecase(t)
+	foreign, err := other.generateChallengeToken(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, token := range map[string]string{
+		"expired":      expired,
+		"foreign key":  foreign,
+		"garbage":      "not-a-token",
+		"wrong typ":    mustSign(t, u, jwt.MapClaims{"user_id": 42, "typ": tokenTypeAccess, "exp": time.Now().Add(time.Minute).Unix()}),
+		"missing type": mustSign(t, u, jwt.MapClaims{"user_id": 42, "exp": time.Now().Add(time.Minute).Unix()}),
+	} {
+		if _, err := u.parseChallengeToken(token); err == nil {
+			t.Errorf("%s challenge token accepted", name)
+		}
+	}
+}
+
+func mustSign(t *testing.T, u *Usecase, claims jwt.MapClaims) string {
+	token, err := u.keys.sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
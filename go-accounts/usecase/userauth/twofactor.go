@@ -0,0 +1,260 @@
+package userauth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/lolmourne/go-accounts/model"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	challengeTokenTTL = 5 * time.Minute
+	recoveryCodeCount = 10
+
+	tokenTypeAccess    = "access"
+	tokenTypeChallenge = "2fa_challenge"
+)
+
+var (
+	ErrTOTPAlreadyEnabled = errors.New("two factor authentication is already enabled")
+	ErrTOTPNotEnrolled    = errors.New("two factor authentication is not enrolled")
+	ErrInvalidTOTPCode    = errors.New("invalid two factor code")
+	ErrInvalidChallenge   = errors.New("invalid or expired challenge token")
+)
+
+func (u *Usecase) EnrollTOTP(userID int64) (model.TOTPEnrollment, error) {
+	user, err := u.dbRsc.GetUserByUserID(userID)
+	if err != nil || user.UserID == 0 {
+		return model.TOTPEnrollment{}, errors.New("user not found")
+	}
+
+	totp, err := u.dbRsc.GetTOTP(userID)
+	if err != nil {
+		log.Println(err)
+		return model.TOTPEnrollment{}, errors.New("Internal Server Error")
+	}
+	if totp.Enabled {
+		return model.TOTPEnrollment{}, ErrTOTPAlreadyEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		log.Println(err)
+		return model.TOTPEnrollment{}, errors.New("Internal Server Error")
+	}
+
+	err = u.dbRsc.SaveTOTPSecret(userID, secret)
+	if err != nil {
+		log.Println(err)
+		return model.TOTPEnrollment{}, errors.New("Internal Server Error")
+	}
+
+	return model.TOTPEnrollment{
+		Secret: secret,
+		URI:    totpURI(u.totpIssuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables two factor authentication once the user proves the
+// authenticator app was set up, and returns one time recovery codes. The
+// codes are only shown here.
+func (u *Usecase) ConfirmTOTP(userID int64, code string) ([]string, error) {
+	totp, err := u.dbRsc.GetTOTP(userID)
+	if err != nil {
+		log.Println(err)
+		return nil, errors.New("Internal Server Error")
+	}
+	if totp.UserID == 0 {
+		return nil, ErrTOTPNotEnrolled
+	}
+	if totp.Enabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	err = u.verifyTOTP(totp, code)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := u.regenerateRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.dbRsc.EnableTOTP(userID)
+	if err != nil {
+		log.Println(err)
+		return nil, errors.New("Internal Server Error")
+	}
+
+	return codes, nil
+}
+
+func (u *Usecase) DisableTOTP(userID int64, password, sessionID string, client model.ClientInfo) error {
+	user, err := u.dbRsc.GetUserByUserID(userID)
+	if err != nil || user.UserID == 0 {
+		return errors.New("user not found")
+	}
+
+	err = u.confirmIdentity(user, password, sessionID)
+	if err != nil {
+		return err
+	}
+
+	err = u.dbRsc.DisableTOTP(userID)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	u.record(userID, 0, model.AuditTwoFactorDisable, client, nil)
+
+	return nil
+}
+
+// LoginTwoFactor completes a login started with Login. code is either the
+// current TOTP code or one of the recovery codes.
+func (u *Usecase) LoginTwoFactor(challengeToken, code string, client model.ClientInfo) (model.UserToken, error) {
+	userID, err := u.parseChallengeToken(challengeToken)
+	if err != nil {
+		return model.UserToken{}, ErrInvalidChallenge
+	}
+
+	totp, err := u.dbRsc.GetTOTP(userID)
+	if err != nil {
+		log.Println(err)
+		return model.UserToken{}, errors.New("Internal Server Error")
+	}
+	if !totp.Enabled {
+		return model.UserToken{}, ErrInvalidChallenge
+	}
+
+	if strings.Contains(code, "-") {
+		ok, err := u.dbRsc.UseRecoveryCode(userID, hashRecoveryCode(code))
+		if err != nil {
+			log.Println(err)
+			return model.UserToken{}, errors.New("Internal Server Error")
+		}
+		if !ok {
+			u.record(userID, 0, model.AuditLoginFailed, client, map[string]string{"reason": "wrong recovery code"})
+			return model.UserToken{}, ErrInvalidTOTPCode
+		}
+	} else {
+		err = u.verifyTOTP(totp, code)
+		if err != nil {
+			u.record(userID, 0, model.AuditLoginFailed, client, map[string]string{"reason": "wrong two factor code"})
+			return model.UserToken{}, err
+		}
+	}
+
+	user, err := u.dbRsc.GetUserByUserID(userID)
+	if err != nil || user.UserID == 0 {
+		return model.UserToken{}, ErrInvalidChallenge
+	}
+
+	err = checkAccountUsable(user)
+	if err != nil {
+		return model.UserToken{}, err
+	}
+
+	sessionID, err := u.createSession(user.UserID, client)
+	if err != nil {
+		return model.UserToken{}, err
+	}
+	u.record(user.UserID, 0, model.AuditLogin, client, map[string]string{"session_id": sessionID, "method": "2fa"})
+
+	return u.issueTokens(user, sessionID)
+}
+
+func (u *Usecase) verifyTOTP(totp model.TOTP, code string) error {
+	step, ok := matchTOTP(totp.Secret, code, time.Now())
+	if !ok {
+		return ErrInvalidTOTPCode
+	}
+
+	ok, err := u.dbRsc.UseTOTPStep(totp.UserID, step)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	if !ok {
+		return ErrInvalidTOTPCode
+	}
+
+	return nil
+}
+
+func (u *Usecase) regenerateRecoveryCodes(userID int64) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw, err := randomBytes(5)
+		if err != nil {
+			log.Println(err)
+			return nil, errors.New("Internal Server Error")
+		}
+		encoded := strings.ToLower(totpEncoding.EncodeToString(raw))
+		codes[i] = encoded[:4] + "-" + encoded[4:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	err := u.dbRsc.SaveRecoveryCodes(userID, hashes)
+	if err != nil {
+		log.Println(err)
+		return nil, errors.New("Internal Server Error")
+	}
+
+	return codes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(code)))
+}
+
+func (u *Usecase) generateChallengeToken(userID int64) (string, error) {
+	now := time.Now()
+	tokenClaim := jwt.MapClaims{}
+	tokenClaim["user_id"] = userID
+	tokenClaim["typ"] = tokenTypeChallenge
+	tokenClaim["iat"] = now.Unix()
+	tokenClaim["exp"] = now.Add(challengeTokenTTL).Unix()
+	tokenClaim["jti"] = uuid.NewV4().String()
+
+	tokenString, err := u.keys.sign(tokenClaim)
+	if err != nil {
+		log.Println(err)
+		return "", errors.New("Internal Server Error")
+	}
+	return tokenString, nil
+}
+
+// ChallengeUserID returns the user a valid challenge token was issued to, so
+// failed codes can be counted per user across challenges.
+func (u *Usecase) ChallengeUserID(challengeToken string) (int64, error) {
+	userID, err := u.parseChallengeToken(challengeToken)
+	if err != nil {
+		return 0, ErrInvalidChallenge
+	}
+	return userID, nil
+}
+
+func (u *Usecase) parseChallengeToken(challengeToken string) (int64, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(challengeToken, claims, u.keys.keyFunc)
+	if err != nil {
+		return 0, err
+	}
+
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) || claims["typ"] != tokenTypeChallenge {
+		return 0, ErrInvalidChallenge
+	}
+
+	userID, _ := claims["user_id"].(float64)
+	return int64(userID), nil
+}
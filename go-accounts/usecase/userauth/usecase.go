@@ -1,98 +1,235 @@
 package userauth
 
 import (
-	"crypto/sha256"
 	"errors"
-	"fmt"
 	"log"
-	"math/rand"
+	"strings"
+	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
+	authClient "github.com/lolmourne/go-accounts/client/userauth"
+	"github.com/lolmourne/go-accounts/model"
+	"github.com/lolmourne/go-accounts/resource/acc"
+	uuid "github.com/satori/go.uuid"
 )
 
-func (u *Usecase) Register(username, password, confirmPassword string) error {
+// reauthWindow is how recent the sign in of an account without a password
+// must be for sensitive changes.
+const reauthWindow = time.Duration(10) * time.Minute
+
+var (
+	ErrInvalidCredentials = errors.New("user not found or password is incorrect")
+	ErrPasswordMismatch   = errors.New("Confirm password is mismatched")
+	ErrWrongPassword      = errors.New("password is wrong")
+	ErrReauthRequired     = errors.New("sign in again to confirm it is you")
+	ErrNoSession          = errors.New("access tokens need a session")
+)
+
+func (u *Usecase) Register(username, password, confirmPassword, email string) error {
 	if confirmPassword != password {
-		return errors.New("Confirm password is mismatched")
+		return ErrPasswordMismatch
 	}
 
-	salt := RandStringBytes(32)
-	password += salt
+	err := u.CheckUsername(username)
+	if err != nil {
+		return err
+	}
 
-	h := sha256.New()
-	h.Write([]byte(password))
-	password = fmt.Sprintf("%x", h.Sum(nil))
+	if email != "" {
+		email, err = normalizeEmail(email)
+		if err != nil {
+			return err
+		}
 
-	err := u.dbRsc.Register(username, password, salt)
+		owner, err := u.dbRsc.GetUserByEmail(email)
+		if err != nil {
+			log.Println(err)
+			return errors.New("Internal Server Error")
+		}
+		if owner.UserID != 0 {
+			return ErrEmailTaken
+		}
+	}
+
+	hashedPassword, err := u.hasher.Hash(password)
 	if err != nil {
-		return err
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+
+	err = u.dbRsc.Register(username, hashedPassword, "", email)
+	if err == acc.ErrDuplicate {
+		return ErrUsernameTaken
+	}
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+
+	if email == "" {
+		return nil
+	}
+
+	user, err := u.dbRsc.GetUserByUserName(username)
+	if err != nil || user.UserID == 0 {
+		log.Println("cannot load registered user", username, err)
+		return nil
+	}
+
+	// the account exists at this point, a failed email can be resent later
+	err = u.sendVerification(user.UserID, user.Username, email)
+	if err != nil {
+		log.Println(err)
 	}
 
 	return nil
 }
 
-func (u *Usecase) Login(username, password string) (string, error) {
+func (u *Usecase) Login(username, password string, client model.ClientInfo) (model.UserToken, error) {
 	user, err := u.dbRsc.GetUserByUserName(username)
 	if err != nil {
-		return "", errors.New("user not found or password is incorrect")
+		u.verifyDummy(password)
+		return model.UserToken{}, ErrInvalidCredentials
 	}
 
-	password += user.Salt
-	h := sha256.New()
-	h.Write([]byte(password))
-	hashedPassword := fmt.Sprintf("%x", h.Sum(nil))
+	if user.UserID == 0 {
+		u.verifyDummy(password)
+		u.record(0, 0, model.AuditLoginFailed, client, map[string]string{"username": username, "reason": "unknown user"})
+		return model.UserToken{}, ErrInvalidCredentials
+	}
 
-	if user.Password != hashedPassword {
-		return "", errors.New("user not found or password is incorrect")
+	if !u.checkPassword(user, password) {
+		u.record(user.UserID, 0, model.AuditLoginFailed, client, map[string]string{"reason": "wrong password"})
+		return model.UserToken{}, ErrInvalidCredentials
 	}
 
-	user.Password = ""
-	user.Salt = ""
+	if user.DeactivatedAt != nil {
+		return model.UserToken{}, ErrAccountDeactivated
+	}
 
-	if user.ProfilePic == "" {
-		user.ProfilePic = "https://i.imgur.com/cINvch3.png"
+	err = checkAccountUsable(user)
+	if err != nil {
+		return model.UserToken{}, err
 	}
 
-	token := jwt.New(jwt.GetSigningMethod("HS256"))
-	tokenClaim := jwt.MapClaims{}
-	tokenClaim["user_id"] = user.UserID
-	tokenClaim["profile_pic"] = user.ProfilePic
-	token.Claims = tokenClaim
+	return u.startSession(user, client)
+}
 
-	tokenString, err := token.SignedString(u.signingKey)
+// startSession signs in a user whose password has been checked, asking for
+// the second factor first when it is enabled.
+func (u *Usecase) startSession(user model.User, client model.ClientInfo) (model.UserToken, error) {
+	totp, err := u.dbRsc.GetTOTP(user.UserID)
 	if err != nil {
 		log.Println(err)
-		return "", errors.New("Internal Server Error")
+		return model.UserToken{}, errors.New("Internal Server Error")
 	}
-	return tokenString, nil
+	if totp.Enabled {
+		challengeToken, err := u.generateChallengeToken(user.UserID)
+		if err != nil {
+			return model.UserToken{}, err
+		}
+		return model.UserToken{
+			TwoFactorRequired: true,
+			ChallengeToken:    challengeToken,
+		}, nil
+	}
+
+	sessionID, err := u.createSession(user.UserID, client)
+	if err != nil {
+		return model.UserToken{}, err
+	}
+	u.record(user.UserID, 0, model.AuditLogin, client, map[string]string{"session_id": sessionID})
+
+	return u.issueTokens(user, sessionID)
 }
 
-func (u *Usecase) ValidateSession(accessToken string) (int64, error) {
+// ValidateSession accepts the JWT access tokens of interactive sessions and
+// personal access tokens.
+func (u *Usecase) ValidateSession(accessToken string) (model.AccessClaims, error) {
+	if strings.HasPrefix(accessToken, authClient.PersonalAccessTokenPrefix) {
+		return u.validateAccessToken(accessToken)
+	}
+
 	claims := jwt.MapClaims{}
-	_, err := jwt.ParseWithClaims(accessToken, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return u.signingKey, nil
-	})
+	_, err := jwt.ParseWithClaims(accessToken, claims, u.keys.keyFunc)
+	if err != nil {
+		return model.AccessClaims{}, ErrInvalidAccessToken
+	}
+
+	// tokens issued before expiry was introduced never expire, reject them
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) || claims["typ"] != tokenTypeAccess {
+		return model.AccessClaims{}, ErrInvalidAccessToken
+	}
+
+	userID, _ := claims["user_id"].(float64)
+	exp, _ := claims["exp"].(float64)
+	iat, _ := claims["iat"].(float64)
+	jti, _ := claims["jti"].(string)
+	sid, _ := claims["sid"].(string)
+	// tokens issued before roles were introduced belong to plain users
+	role, _ := claims["role"].(string)
+	if role == "" {
+		role = model.RoleUser
+	}
+
+	// every access token belongs to a session, one without could not be
+	// revoked
+	if sid == "" {
+		return model.AccessClaims{}, ErrInvalidAccessToken
+	}
+
+	revoked, err := u.dbRsc.IsSessionRevoked(sid)
+	if err != nil {
+		log.Println(err)
+		return model.AccessClaims{}, errors.New("Internal Server Error")
+	}
+	if revoked {
+		return model.AccessClaims{}, ErrInvalidAccessToken
+	}
+
+	err = u.dbRsc.TouchSession(sid, "", time.Now())
 	if err != nil {
-		return 0, errors.New("Invalid Token")
+		log.Println(err)
 	}
 
-	userID := int64(claims["user_id"].(float64))
-	return userID, nil
+	return model.AccessClaims{
+		UserID:    int64(userID),
+		Role:      role,
+		SessionID: sid,
+		TokenID:   jti,
+		IssuedAt:  time.Unix(int64(iat), 0),
+		ExpiresAt: time.Unix(int64(exp), 0),
+	}, nil
 }
 
-func (u *Usecase) GenerateJWT(userID int64, profilePic string) (string, error) {
-	if profilePic == "" {
-		profilePic = "https://i.imgur.com/cINvch3.png"
+func (u *Usecase) GenerateJWT(user model.User, sessionID string) (string, error) {
+	if sessionID == "" {
+		return "", ErrNoSession
+	}
+
+	if user.ProfilePic == "" {
+		user.ProfilePic = "https://i.imgur.com/cINvch3.png"
 	}
-	token := jwt.New(jwt.GetSigningMethod("HS256"))
+
+	now := time.Now()
 	tokenClaim := jwt.MapClaims{}
-	tokenClaim["user_id"] = userID
-	tokenClaim["profile_pic"] = profilePic
-	token.Claims = tokenClaim
+	tokenClaim["user_id"] = user.UserID
+	tokenClaim["typ"] = tokenTypeAccess
+	tokenClaim["username"] = user.Username
+	tokenClaim["profile_pic"] = user.ProfilePic
+	tokenClaim["role"] = user.Role
+	if user.Role == "" {
+		tokenClaim["role"] = model.RoleUser
+	}
+	if user.DisplayName != "" {
+		tokenClaim["display_name"] = user.DisplayName
+	}
+	tokenClaim["iat"] = now.Unix()
+	tokenClaim["exp"] = now.Add(u.accessTokenTTL).Unix()
+	tokenClaim["jti"] = uuid.NewV4().String()
+	tokenClaim["sid"] = sessionID
 
-	tokenString, err := token.SignedString(u.signingKey)
+	tokenString, err := u.keys.sign(tokenClaim)
 	if err != nil {
 		log.Println(err)
 		return "", errors.New("Internal Server Error")
@@ -100,40 +237,60 @@ func (u *Usecase) GenerateJWT(userID int64, profilePic string) (string, error) {
 	return tokenString, nil
 }
 
-func (u *Usecase) ChangeUsername(userID int64, username string) error {
+func (u *Usecase) ChangeUsername(userID int64, username string, client model.ClientInfo) error {
 	userInfo, err := u.dbRsc.GetUserByUserID(userID)
 	if err != nil {
 		return err
 	}
 
-	if username == "" {
-		return errors.New("new username cannot be empty")
+	if username == userInfo.Username {
+		return ErrUsernameUnchanged
 	}
 
-	if username == userInfo.Username {
-		return errors.New("new username cannot be the same as old one")
+	err = validateUsername(username)
+	if err != nil {
+		return err
+	}
+
+	if userInfo.UsernameChangedAt != nil && time.Now().Before(userInfo.UsernameChangedAt.Add(u.usernameCooldown)) {
+		return ErrUsernameCooldown
+	}
+
+	err = u.usernameAvailable(username, userID)
+	if err != nil {
+		return err
 	}
 
 	err = u.dbRsc.UpdateUserName(userID, username)
+	if err == acc.ErrDuplicate {
+		return ErrUsernameTaken
+	}
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	u.record(userID, 0, model.AuditUsernameChange, client, map[string]string{"from": userInfo.Username, "to": username})
+
+	return nil
+}
+
+func (u *Usecase) ChangeProfilePic(userID int64, profilePic string, client model.ClientInfo) error {
+	err := u.dbRsc.UpdateUserProfpic(userID, profilePic)
 	if err != nil {
 		return err
 	}
+	u.record(userID, 0, model.AuditProfilePicChange, client, map[string]string{"profile_pic": profilePic})
 
 	return nil
 }
 
-func (u *Usecase) ChangePassword(userID int64, oldPassword, newPassword, confirmPassword string) error {
+func (u *Usecase) ChangePassword(userID int64, oldPassword, newPassword, confirmPassword string, client model.ClientInfo) error {
 	user, err := u.dbRsc.GetUserByUserID(userID)
 	if err != nil {
 		return err
 	}
 
-	oldPassword += user.Salt
-	h := sha256.New()
-	h.Write([]byte(oldPassword))
-	hashedOldPassword := fmt.Sprintf("%x", h.Sum(nil))
-
-	if user.Password != hashedOldPassword {
+	if !u.checkPassword(user, oldPassword) {
 		return errors.New("old password is wrong")
 	}
 
@@ -142,27 +299,84 @@ func (u *Usecase) ChangePassword(userID int64, oldPassword, newPassword, confirm
 	}
 
 	// change to new password
-	salt := RandStringBytes(32)
-	newPassword += salt
-
-	h = sha256.New()
-	h.Write([]byte(newPassword))
-	hashedNewPass := fmt.Sprintf("%x", h.Sum(nil))
+	hashedNewPass, err := u.hasher.Hash(newPassword)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
 
 	err = u.dbRsc.UpdateUserPassword(userID, hashedNewPass)
 	if err != nil {
 		return err
 	}
+	u.record(userID, 0, model.AuditPasswordChange, client, nil)
 
 	return nil
 }
 
-const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+// confirmIdentity checks the caller of a sensitive change is the owner of
+// user right now. Accounts with a password give it. Accounts signed up
+// through an identity provider have none, a session opened within
+// reauthWindow stands in for it, so they sign in with the provider again.
+func (u *Usecase) confirmIdentity(user model.User, password, sessionID string) error {
+	if user.Password != "" {
+		if !u.checkPassword(user, password) {
+			return ErrWrongPassword
+		}
+		return nil
+	}
 
-func RandStringBytes(n int) string {
-	b := make([]byte, n)
-	for i := range b {
-		b[i] = letterBytes[rand.Intn(len(letterBytes))]
+	session, err := u.dbRsc.GetSession(sessionID)
+	if err != nil || session.UserID != user.UserID || session.RevokedAt != nil {
+		return ErrReauthRequired
+	}
+	if time.Since(session.CreatedAt) > reauthWindow {
+		return ErrReauthRequired
 	}
-	return string(b)
+	return nil
+}
+
+// verifyDummy spends the time of a password check without one.
+func (u *Usecase) verifyDummy(password string) {
+	if u.dummyHash != "" {
+		u.hasher.Verify(password, u.dummyHash)
+	}
+}
+
+// checkPassword verifies password against the stored hash of user. Hashes
+// produced with outdated algorithms or parameters are upgraded in place.
+func (u *Usecase) checkPassword(user model.User, password string) bool {
+	// accounts signed up through an identity provider have no password
+	if user.Password == "" {
+		u.verifyDummy(password)
+		return false
+	}
+
+	encoded := user.Password
+	if !strings.HasPrefix(encoded, "$") {
+		encoded = EncodeLegacySHA256(user.Password, user.Salt)
+	}
+
+	ok, err := u.hasher.Verify(password, encoded)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	if u.hasher.NeedsRehash(encoded) {
+		newHash, err := u.hasher.Hash(password)
+		if err != nil {
+			log.Println(err)
+			return true
+		}
+		err = u.dbRsc.UpdateUserPassword(user.UserID, newHash)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	return true
 }
@@ -0,0 +1,101 @@
+package userauth
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	minUsernameLen = 3
+	maxUsernameLen = 30
+)
+
+var (
+	ErrUsernameInvalid   = errors.New("username must be 3 to 30 letters, digits, dots or underscores and start and end with a letter or digit")
+	ErrUsernameReserved  = errors.New("username is reserved")
+	ErrUsernameTaken     = errors.New("username is already taken")
+	ErrUsernameCooldown  = errors.New("username was changed recently, try again later")
+	ErrUsernameUnchanged = errors.New("new username cannot be the same as old one")
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9]|[._][a-zA-Z0-9])*$`)
+
+// reservedUsernames can't be registered by anyone, they would let a user
+// pass as staff or collide with routes of the web app. Compared case folded.
+var reservedUsernames = map[string]bool{
+	"admin":         true,
+	"administrator": true,
+	"root":          true,
+	"system":        true,
+	"support":       true,
+	"help":          true,
+	"moderator":     true,
+	"mod":           true,
+	"staff":         true,
+	"official":      true,
+	"security":      true,
+	"bot":           true,
+	"api":           true,
+	"www":           true,
+	"mail":          true,
+	"me":            true,
+	"settings":      true,
+	"profile":       true,
+	"login":         true,
+	"logout":        true,
+	"register":      true,
+	"signup":        true,
+	"null":          true,
+	"undefined":     true,
+	"anonymous":     true,
+	"deleted":       true,
+}
+
+// validateUsername checks the charset, length and reserved words, it does
+// not look at the database.
+func validateUsername(username string) error {
+	if len(username) < minUsernameLen || len(username) > maxUsernameLen || !usernamePattern.MatchString(username) {
+		return ErrUsernameInvalid
+	}
+	if reservedUsernames[strings.ToLower(username)] {
+		return ErrUsernameReserved
+	}
+	return nil
+}
+
+// CheckUsername reports whether username can be registered right now.
+func (u *Usecase) CheckUsername(username string) error {
+	err := validateUsername(username)
+	if err != nil {
+		return err
+	}
+	return u.usernameAvailable(username, 0)
+}
+
+// usernameAvailable checks that username is neither used by another account
+// nor held back after a rename. userID is the user asking, who may always
+// take back its own names.
+func (u *Usecase) usernameAvailable(username string, userID int64) error {
+	owner, err := u.dbRsc.GetUserByUserName(username)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	if owner.UserID != 0 && owner.UserID != userID {
+		return ErrUsernameTaken
+	}
+
+	holder, err := u.dbRsc.GetUsernameHolder(username, time.Now().Add(-u.usernameHold))
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	if holder != 0 && holder != userID {
+		return ErrUsernameTaken
+	}
+
+	return nil
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"log"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,6 +14,15 @@ var host = "http://skilvul-gc.com"
 var backendHost = "http://skilvul-gc.com:7070"
 var groupchatBackendHost = "http://skilvul-gc.com:8080"
 
+// refreshTokenCookie keeps the refresh token where scripts cannot read it,
+// only the /session endpoints below ever see it.
+const (
+	refreshTokenCookie = "refresh_token"
+	refreshTokenMaxAge = 30 * 24 * 60 * 60
+)
+
+var backendClient = &http.Client{Timeout: 10 * time.Second}
+
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
@@ -25,6 +37,9 @@ func main() {
 	r.GET("/profile/password", renderChangePassword)
 	r.GET("/profile/username", renderChangeUsername)
 	r.GET("/profile/profpic", renderChangeProfpic)
+	r.POST("/session", createSession)
+	r.POST("/session/refresh", refreshSession)
+	r.POST("/session/logout", deleteSession)
 	r.Static("/css", "./css")
 	r.Run(":80")
 }
@@ -113,3 +128,83 @@ func renderChangeProfpic(c *gin.Context) {
 		"gc_host":      groupchatBackendHost,
 	})
 }
+
+// createSession stores the refresh token of a fresh login in an HttpOnly
+// cookie; the page keeps only the short lived access token.
+func createSession(c *gin.Context) {
+	refreshToken := c.Request.FormValue("refresh_token")
+	if refreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+		return
+	}
+
+	setRefreshTokenCookie(c, refreshToken, refreshTokenMaxAge)
+	c.Status(http.StatusNoContent)
+}
+
+// refreshSession exchanges the refresh token cookie for a new token pair
+// and hands the access token back to the page.
+func refreshSession(c *gin.Context) {
+	refreshToken, err := c.Cookie(refreshTokenCookie)
+	if err != nil || refreshToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no session"})
+		return
+	}
+
+	resp, err := backendClient.PostForm(backendHost+"/token/refresh", url.Values{
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "cannot reach the account service"})
+		return
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Data struct {
+			AccessToken  string `json:"access_token"`
+			RefreshToken string `json:"refresh_token"`
+			ExpiresIn    int64  `json:"expires_in"`
+		} `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil || resp.StatusCode != http.StatusOK || body.Data.AccessToken == "" {
+		// the refresh token is spent or revoked, the user has to sign in again
+		setRefreshTokenCookie(c, "", -1)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session expired"})
+		return
+	}
+
+	setRefreshTokenCookie(c, body.Data.RefreshToken, refreshTokenMaxAge)
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": body.Data.AccessToken,
+		"expires_in":   body.Data.ExpiresIn,
+	})
+}
+
+// deleteSession signs the session out of the account service and drops the
+// refresh token cookie.
+func deleteSession(c *gin.Context) {
+	accessToken := c.GetHeader("X-Access-Token")
+	if accessToken != "" {
+		req, err := http.NewRequest(http.MethodPost, backendHost+"/logout", nil)
+		if err == nil {
+			req.Header.Set("X-Access-Token", accessToken)
+			resp, err := backendClient.Do(req)
+			if err != nil {
+				log.Println(err)
+			} else {
+				resp.Body.Close()
+			}
+		}
+	}
+
+	setRefreshTokenCookie(c, "", -1)
+	c.Status(http.StatusNoContent)
+}
+
+func setRefreshTokenCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(refreshTokenCookie, value, maxAge, "/session", "", c.Request.TLS != nil, true)
+}
@@ -2,9 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
 	"strconv"
 	"time"
@@ -16,6 +18,9 @@ import (
 	_ "github.com/lib/pq"
 	"github.com/lolmourne/go-accounts/client/userauth"
 	userAuth "github.com/lolmourne/go-accounts/client/userauth"
+	accModel "github.com/lolmourne/go-accounts/model"
+	"github.com/lolmourne/go-accounts/resource/events"
+	"github.com/lolmourne/go-accounts/resource/ratelimit"
 	"github.com/lolmourne/go-groupchat/model"
 	"github.com/lolmourne/go-groupchat/resource/groupchat"
 	groupchat2 "github.com/lolmourne/go-groupchat/usecase/groupchat"
@@ -28,6 +33,7 @@ import (
 var dbRoomResource groupchat.DBItf
 var userClient userAuth.ClientItf
 var groupChatUsecase groupchat2.UsecaseItf
+var publisher events.Publisher
 
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
@@ -63,8 +69,26 @@ func main() {
 
 	dbRoomResource = dbRoomRsc
 
-	userClient = userauth.NewClient("http://localhost:7070", time.Duration(30)*time.Second)
-	groupChatUsecase = groupchat2.NewUseCase(dbRoomRsc)
+	accountsHost := cfg.Accounts.Host
+	if accountsHost == "" {
+		accountsHost = "http://localhost:7070"
+	}
+	accountsCli, err := userauth.NewClientFromConfig(userauth.ClientConfig{
+		Transport:   cfg.Accounts.Transport,
+		Host:        accountsHost,
+		GRPCAddress: cfg.Accounts.GRPCAddress,
+		Timeout:     time.Duration(30) * time.Second,
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	userClient = userauth.NewLocalClient(
+		userauth.NewVerifier(accountsHost, time.Duration(5)*time.Second, time.Duration(10)*time.Minute),
+		userauth.NewRedisDenylist(rdb),
+		accountsCli,
+	)
+	publisher = events.NewRedisPublisher(rdb)
+	groupChatUsecase = groupchat2.NewUseCase(dbRoomRsc, userClient, publisher)
 
 	redisClient := redisCli.New(redisCli.SINGLE_MODE, cfg.Redis.Host, 10,
 		redigo.DialReadTimeout(time.Duration(30)*time.Second),
@@ -73,6 +97,7 @@ func main() {
 		redigo.DialPassword(cfg.Redis.Password))
 	pubsub := pubsub.NewRedisPubsub(redisClient)
 	pubsub.Subscribe("testsub", readPubsub, true)
+	pubsub.Subscribe(userauth.AccountDeletedChannel, removeDeletedUser, true)
 
 	corsOpts := cors.Config{
 		AllowAllOrigins:  true,
@@ -83,16 +108,21 @@ func main() {
 	cors := cors.New(corsOpts)
 	r := gin.Default()
 	r.Use(cors)
+	r.Use(ratelimit.Middleware(ratelimit.NewRedisLimiter(rdb, "groupchat", 120, time.Minute), ratelimit.KeyByIP))
 
 	// untuk PR
 	r.PUT("/groupchat", validateSession(joinRoom))
 	r.POST("/groupchat", validateSession(createRoom))
 	r.GET("/groupchat", validateSession(getRoomList))
 	r.GET("/joined", validateSession(getJoinedRoom))
-	r.GET("/groupchat/:room_id", getGroupchat)
-	r.GET("/participants/:room_id", getRoomParticipants)
+	r.GET("/groupchat/:room_id", optionalSession(getGroupchat))
+	r.PATCH("/groupchat/:room_id", validateSession(editRoom))
+	r.POST("/groupchat/:room_id/transfer", validateSession(transferRoom))
+	r.GET("/participants/:room_id", optionalSession(getRoomParticipants))
 	r.PUT("/groupchat/leave/:room_id", validateSession(leaveRoom))
 	r.PUT("/groupchat/delete/:room_id", validateSession(deleteRoom))
+	r.GET("/me/export", validateSession(exportUserData))
+	r.POST("/dm", validateSession(createDirectRoom))
 	r.Run()
 }
 
@@ -100,10 +130,73 @@ func readPubsub(msg string, err error) {
 	log.Println(msg)
 }
 
+// removeDeletedUser drops the memberships of accounts deleted in
+// go-accounts and confirms it. Deletions are delivered again until
+// confirmed, removing twice is harmless.
+func removeDeletedUser(msg string, err error) {
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	var event userauth.AccountDeletedEvent
+	err = json.Unmarshal([]byte(msg), &event)
+	if err != nil || event.UserID < 1 {
+		log.Println("invalid account deleted event", msg)
+		return
+	}
+
+	err = groupChatUsecase.RemoveUser(event.UserID)
+	if err != nil {
+		log.Println("cannot remove deleted user", event.UserID, err)
+		return
+	}
+
+	err = publisher.Publish(userauth.AccountPurgedChannel, userauth.AccountPurgedEvent{
+		UserID:  event.UserID,
+		Service: userauth.ServiceGroupchat,
+	})
+	if err != nil {
+		log.Println("cannot confirm purge of user", event.UserID, err)
+	}
+}
+
+func exportUserData(c *gin.Context) {
+	userID := c.GetInt64("uid")
+
+	export, err := groupChatUsecase.ExportUserData(userID)
+	if err != nil {
+		log.Println(err)
+		c.JSON(500, StandardAPIResponse{
+			Err: "Internal Server Error",
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: export,
+	})
+}
+
 func validateSession(handlerFunc gin.HandlerFunc) gin.HandlerFunc {
+	return authenticate(true, handlerFunc)
+}
+
+// optionalSession lets anonymous callers through with no "uid" set, a token
+// that is sent must still be valid.
+func optionalSession(handlerFunc gin.HandlerFunc) gin.HandlerFunc {
+	return authenticate(false, handlerFunc)
+}
+
+func authenticate(required bool, handlerFunc gin.HandlerFunc) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		accessToken := c.Request.Header["X-Access-Token"]
 
+		if len(accessToken) < 1 && !required {
+			handlerFunc(c)
+			return
+		}
 		if len(accessToken) < 1 {
 			c.JSON(403, StandardAPIResponse{
 				Err:     "No access token provided",
@@ -112,15 +205,32 @@ func validateSession(handlerFunc gin.HandlerFunc) gin.HandlerFunc {
 			return
 		}
 
-		user := userClient.GetUserInfo(accessToken[0])
-		if user == nil {
+		user, err := userClient.GetUserInfo(c.Request.Context(), accessToken[0])
+		if errors.Is(err, userauth.ErrUnauthorized) {
 			c.JSON(401, StandardAPIResponse{
 				Err: "Unauthorized",
 			})
 			return
 		}
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusServiceUnavailable, StandardAPIResponse{
+				Err: "Cannot validate session, try again later",
+			})
+			return
+		}
+		// personal access tokens need read for GET requests and write for
+		// the rest
+		scope := accModel.ScopeForMethod(c.Request.Method)
+		if !user.Allows(scope) {
+			c.JSON(403, StandardAPIResponse{
+				Err: "Token lacks the " + scope + " scope",
+			})
+			return
+		}
 		c.Set("uid", user.UserID)
 		c.Set("user", user)
+		c.Set("role", user.Role)
 		handlerFunc(c)
 	}
 }
@@ -136,9 +246,9 @@ func getGroupchat(c *gin.Context) {
 		return
 	}
 
-	room, err := groupChatUsecase.GetRoomByID(roomID)
+	room, err := groupChatUsecase.GetRoomByID(roomID, c.GetInt64("uid"))
 	if err != nil {
-		c.JSON(400, StandardAPIResponse{
+		c.JSON(roomErrStatus(err), StandardAPIResponse{
 			Err: err.Error(),
 		})
 		return
@@ -152,19 +262,19 @@ func getGroupchat(c *gin.Context) {
 func createRoom(c *gin.Context) {
 	name := c.Request.FormValue("name")
 	desc := c.Request.FormValue("desc")
-	categoryId := c.Request.FormValue("category_id")
 	adminId := c.GetInt64("uid") //by default the one who create will be group admin
 
-	catID, err := strconv.ParseInt(categoryId, 10, 64)
-	if err != nil {
-		catID = 0
+	catID, err := strconv.ParseInt(c.Request.FormValue("category_id"), 10, 64)
+	if err != nil || catID < 1 {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid category id",
+		})
+		return
 	}
 
-	//_, err = groupChatUsecase.CreateGroupchat(name, adminId, desc, catID)
-	log.Println(name, desc, categoryId, adminId, catID)
-	err = nil
+	room, err := groupChatUsecase.CreateGroupchat(name, adminId, desc, catID)
 	if err != nil {
-		c.JSON(400, StandardAPIResponse{
+		c.JSON(roomErrStatus(err), StandardAPIResponse{
 			Err: err.Error(),
 		})
 		return
@@ -173,6 +283,120 @@ func createRoom(c *gin.Context) {
 	c.JSON(201, StandardAPIResponse{
 		Err:     "null",
 		Message: "Success create new groupchat",
+		Data:    room,
+	})
+}
+
+// editRoom updates the fields present in the JSON body, for the room admin
+// only.
+func editRoom(c *gin.Context) {
+	roomID, err := strconv.ParseInt(c.Param("room_id"), 10, 64)
+	if err != nil {
+		c.JSON(400, StandardAPIResponse{
+			Err: "wrong room id",
+		})
+		return
+	}
+
+	var update model.RoomUpdate
+	err = c.ShouldBindJSON(&update)
+	if err != nil {
+		c.JSON(400, StandardAPIResponse{
+			Err: "invalid room update",
+		})
+		return
+	}
+
+	room, err := groupChatUsecase.EditGroupchat(roomID, c.GetInt64("uid"), update)
+	if err != nil {
+		c.JSON(roomErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: room,
+	})
+}
+
+// transferRoom makes the participant user_id the admin of the room.
+func transferRoom(c *gin.Context) {
+	roomID, err := strconv.ParseInt(c.Param("room_id"), 10, 64)
+	if err != nil {
+		c.JSON(400, StandardAPIResponse{
+			Err: "wrong room id",
+		})
+		return
+	}
+
+	newAdminID, err := strconv.ParseInt(c.Request.FormValue("user_id"), 10, 64)
+	if err != nil || newAdminID < 1 {
+		c.JSON(400, StandardAPIResponse{
+			Err: "wrong user id",
+		})
+		return
+	}
+
+	room, err := groupChatUsecase.TransferGroupchat(roomID, c.GetInt64("uid"), newAdminID)
+	if err != nil {
+		c.JSON(roomErrStatus(err), StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: room,
+	})
+}
+
+func roomErrStatus(err error) int {
+	switch err {
+	case groupchat2.ErrInvalidRoomName, groupchat2.ErrInvalidRoomDescription, groupchat2.ErrInvalidRoomAvatar,
+		groupchat2.ErrCategoryNotFound, groupchat2.ErrTransferSelf, groupchat2.ErrNotParticipant:
+		return 400
+	case groupchat2.ErrNotRoomAdmin, groupchat2.ErrDirectRoomEdit:
+		return 403
+	case groupchat2.ErrRoomNotFound:
+		return 404
+	case groupchat2.ErrTooManyRooms, groupchat2.ErrNewAdminTooManyRooms:
+		return http.StatusConflict
+	}
+	return 500
+}
+
+func createDirectRoom(c *gin.Context) {
+	peerID, err := strconv.ParseInt(c.Request.FormValue("user_id"), 10, 64)
+	if err != nil || peerID < 1 {
+		c.JSON(400, StandardAPIResponse{
+			Err: "wrong user id",
+		})
+		return
+	}
+
+	room, err := groupChatUsecase.CreateDirectRoom(c.Request.Context(), c.GetHeader("X-Access-Token"), c.GetInt64("uid"), peerID)
+	if err != nil {
+		status := 500
+		switch err {
+		case groupchat2.ErrDirectSelf:
+			status = 400
+		case groupchat2.ErrUserNotFound:
+			status = 404
+		case groupchat2.ErrBlocked:
+			status = 403
+		}
+		c.JSON(status, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, StandardAPIResponse{
+		Err:  "null",
+		Data: room,
 	})
 }
 
@@ -297,7 +521,13 @@ func getRoomParticipants(c *gin.Context) {
 		return
 	}
 
-	participants, err := dbRoomResource.GetRoomParticipants(roomID)
+	participants, err := groupChatUsecase.GetRoomParticipants(roomID, c.GetInt64("uid"))
+	if err == groupchat2.ErrRoomNotFound {
+		c.JSON(404, StandardAPIResponse{
+			Err: err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(400, StandardAPIResponse{
 			Err: "Unauthorized",
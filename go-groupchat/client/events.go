@@ -0,0 +1,17 @@
+package client
+
+// RoomChangedChannel carries a RoomChangedEvent whenever a room is edited or
+// handed to another admin, so connected clients can refresh the room header.
+const RoomChangedChannel = "groupchat:rooms"
+
+const (
+	RoomUpdated     = "updated"
+	RoomTransferred = "transferred"
+)
+
+// RoomChangedEvent holds the room as it is after the change, Type is one of
+// the Room* constants.
+type RoomChangedEvent struct {
+	Type string `json:"type"`
+	Room Room   `json:"room"`
+}
@@ -11,10 +11,13 @@ import (
 
 type Room struct {
 	RoomID      int64     `json:"room_id"`
+	Name        string    `json:"name"`
+	Avatar      string    `json:"avatar"`
 	AdminUserID int64     `json:"admin_user_id"`
 	Description string    `json:"description"`
 	CategoryID  int64     `json:"category_id"`
 	CreatedAt   time.Time `json:"created_at"`
+	Direct      bool      `json:"direct"`
 }
 
 type GroupchatClient struct {
@@ -23,7 +26,10 @@ type GroupchatClient struct {
 }
 
 type GroupchatClientItf interface {
-	GetGroupchatRoom(roomID int64) *Room
+	// GetGroupchatRoom returns the room as seen by the owner of
+	// accessToken, nil when it does not exist or is a direct room the
+	// owner is not part of.
+	GetGroupchatRoom(roomID int64, accessToken string) *Room
 }
 
 func NewClient(host string, timeout time.Duration) GroupchatClientItf {
@@ -33,7 +39,7 @@ func NewClient(host string, timeout time.Duration) GroupchatClientItf {
 	}
 }
 
-func (gc *GroupchatClient) GetGroupchatRoom(roomID int64) *Room {
+func (gc *GroupchatClient) GetGroupchatRoom(roomID int64, accessToken string) *Room {
 	client := &http.Client{
 		Timeout: gc.timeout,
 	}
@@ -42,6 +48,7 @@ func (gc *GroupchatClient) GetGroupchatRoom(roomID int64) *Room {
 	if err != nil {
 		return nil
 	}
+	req.Header.Set("X-Access-Token", accessToken)
 
 	respRaw, err := client.Do(req)
 	if err != nil {
@@ -14,4 +14,8 @@ require (
 	github.com/lolmourne/go-accounts v0.0.0-20210417080855-f908d16aea66
 	github.com/lolmourne/r-pipeline v0.0.0-20200925011721-c28050109e69
 	github.com/rs/cors v1.7.0 // indirect
+	google.golang.org/grpc v1.38.0 // indirect
+	google.golang.org/protobuf v1.26.0 // indirect
 )
+
+replace github.com/lolmourne/go-accounts => ../go-accounts
@@ -0,0 +1,4 @@
+-- direct rooms hold exactly two users, the key is "<lower user id>:<higher user id>"
+ALTER TABLE room ADD COLUMN IF NOT EXISTS direct_key VARCHAR(41) NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS room_direct_key_idx ON room (direct_key);
@@ -0,0 +1 @@
+ALTER TABLE room ADD COLUMN IF NOT EXISTS avatar TEXT NULL;
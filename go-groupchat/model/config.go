@@ -1,9 +1,10 @@
 package model
 
 type Config struct {
-	DB    PostgreCfg `json:"postgresql"`
-	Redis RedisCfg   `json:"redis"`
-	JWT   JWTCfg     `json:"jwt"`
+	DB       PostgreCfg  `json:"postgresql"`
+	Redis    RedisCfg    `json:"redis"`
+	JWT      JWTCfg      `json:"jwt"`
+	Accounts AccountsCfg `json:"accounts"`
 }
 
 type PostgreCfg struct {
@@ -22,3 +23,12 @@ type RedisCfg struct {
 type JWTCfg struct {
 	SignKey string `json:"signKey"`
 }
+
+// AccountsCfg locates go-accounts. Transport is "http", the default, or
+// "grpc" to reach the Accounts gRPC service at GRPCAddress. Access tokens are
+// verified against the keys published under Host either way.
+type AccountsCfg struct {
+	Host        string `json:"host"`
+	Transport   string `json:"transport"`
+	GRPCAddress string `json:"grpc_address"`
+}
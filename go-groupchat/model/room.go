@@ -9,4 +9,23 @@ type Room struct {
 	Description string    `json:"description"`
 	CategoryID  int64     `json:"category_id"`
 	CreatedAt   time.Time `json:"created_at"`
+	// Avatar is the URL of the room picture, empty when there is none.
+	Avatar string `json:"avatar"`
+	// Direct rooms are private conversations between two users.
+	Direct bool `json:"direct"`
+}
+
+// RoomUpdate carries a partial room update, nil fields are left untouched.
+// An empty Avatar removes the picture.
+type RoomUpdate struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	CategoryID  *int64  `json:"category_id"`
+	Avatar      *string `json:"avatar"`
+}
+
+// UserExport is the part of a personal data export kept by groupchat.
+type UserExport struct {
+	JoinedRooms  []Room `json:"joined_rooms"`
+	CreatedRooms []Room `json:"created_rooms"`
 }
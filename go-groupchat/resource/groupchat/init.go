@@ -1,11 +1,22 @@
 package groupchat
 
 import (
+	"errors"
+
 	"github.com/go-redis/redis/v8"
 	"github.com/jmoiron/sqlx"
 	"github.com/lolmourne/go-groupchat/model"
 )
 
+var (
+	// ErrRoomLimit is returned when a user already is the admin of as many
+	// group rooms as allowed.
+	ErrRoomLimit = errors.New("room limit reached")
+	// ErrAdminChanged is returned when the room has another admin than the
+	// one expected by the change.
+	ErrAdminChanged = errors.New("room admin changed")
+)
+
 type RedisResource struct {
 	rdb  *redis.Client
 	next DBItf
@@ -19,13 +30,29 @@ type DBItf interface {
 	GetJoinedRoom(userID int64) ([]model.Room, error)
 	GetRoomByID(roomID int64) (model.Room, error)
 	GetRooms(userID int64) ([]model.Room, error)
-	CreateRoom(roomName string, adminID int64, description string, categoryID int64) error
+	// CreateRoom opens a group room unless adminID already is the admin of
+	// maxRooms of them, then it returns ErrRoomLimit.
+	CreateRoom(roomName string, adminID int64, description string, categoryID int64, maxRooms int) (model.Room, error)
+	// UpdateRoom applies the non nil fields of update.
+	UpdateRoom(roomID int64, update model.RoomUpdate) error
+	// UpdateRoomAdmin hands roomID from oldAdminID to newAdminID. It returns
+	// ErrAdminChanged when oldAdminID is no longer the admin and ErrRoomLimit
+	// when newAdminID already is the admin of maxRooms group rooms.
+	UpdateRoomAdmin(roomID, oldAdminID, newAdminID int64, maxRooms int) error
 	AddRoomParticipant(roomID, userID int64) error
+	IsRoomParticipant(roomID, userID int64) (bool, error)
 	GetRoomByCategoryID(userID, categoryID int64) ([]model.Room, error)
 	GetCategory() ([]model.Category, error)
+	// GetCategoryByID returns an empty category when there is none.
+	GetCategoryByID(categoryID int64) (model.Category, error)
 	GetRoomParticipants(roomID int64) ([]model.User, error)
 	LeaveRoom(userID, roomID int64) error
 	DeleteRoom(roomID int64) error
+	RemoveUser(userID int64) error
+	// GetDirectRoom returns the direct room with directKey, or an empty room
+	// when there is none yet.
+	GetDirectRoom(directKey string) (model.Room, error)
+	CreateDirectRoom(directKey string, userID, peerID int64) (model.Room, error)
 }
 
 func NewRedisResource(rdb *redis.Client, next DBItf) DBItf {
@@ -12,6 +12,8 @@ type RoomDB struct {
 	Description sql.NullString `db:"description"`
 	CategoryID  sql.NullInt64  `db:"category_id"`
 	CreatedAt   time.Time      `db:"created_at"`
+	DirectKey   sql.NullString `db:"direct_key"`
+	Avatar      sql.NullString `db:"avatar"`
 }
 type UserDB struct {
 	UserID   sql.NullInt64  `db:"user_id"`
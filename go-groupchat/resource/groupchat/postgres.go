@@ -1,9 +1,11 @@
 package groupchat
 
 import (
+	"database/sql"
 	"log"
 	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/lolmourne/go-groupchat/model"
 )
 
@@ -15,7 +17,8 @@ func (dbr *DBResource) GetJoinedRoom(userID int64) ([]model.Room, error) {
 		    admin_user_id,
 		    description,
 		    category_id,
-		    created_at
+		    created_at,
+		    avatar
 		FROM
 			room r
 		INNER JOIN
@@ -40,6 +43,7 @@ func (dbr *DBResource) GetJoinedRoom(userID int64) ([]model.Room, error) {
 				Description: r.Description.String,
 				CategoryID:  r.CategoryID.Int64,
 				CreatedAt:   r.CreatedAt,
+				Avatar:      r.Avatar.String,
 			})
 		}
 	}
@@ -48,28 +52,91 @@ func (dbr *DBResource) GetJoinedRoom(userID int64) ([]model.Room, error) {
 	return resultRooms, err
 }
 
-func (dbr *DBResource) CreateRoom(roomName string, adminID int64, description string, categoryID int64) error {
-	queryToCreate := `
-		WITH
-			ins_to_rp
-		AS (
-			INSERT INTO
-				room
-				(name, admin_user_id, description, category_id, created_at)
-			VALUES
-				($1, $2, $3, $4, $5)
-			RETURNING
-				room_id
-		)
+// CreateRoom opens a room with adminID as its admin and first participant.
+// Counting and inserting happen under a transaction lock on adminID, so
+// concurrent requests cannot both pass the limit.
+func (dbr *DBResource) CreateRoom(roomName string, adminID int64, description string, categoryID int64, maxRooms int) (model.Room, error) {
+	tx, err := dbr.db.Beginx()
+	if err != nil {
+		return model.Room{}, err
+	}
+	defer tx.Rollback()
+
+	err = lockAdmin(tx, adminID, maxRooms)
+	if err != nil {
+		return model.Room{}, err
+	}
+
+	query := `
 		INSERT INTO
-			room_participant
-		SELECT
-			room_id, $2
-		FROM
-			ins_to_rp
+			room
+			(name, admin_user_id, description, category_id, created_at)
+		VALUES
+			($1, $2, $3, $4, $5)
+		RETURNING
+			room_id
+	`
+
+	room := model.Room{
+		Name:        roomName,
+		AdminUserID: adminID,
+		Description: description,
+		CategoryID:  categoryID,
+		CreatedAt:   time.Now(),
+	}
+	err = tx.Get(&room.RoomID, query, roomName, adminID, description, categoryID, room.CreatedAt)
+	if err != nil {
+		return model.Room{}, err
+	}
+
+	_, err = tx.Exec(`INSERT INTO room_participant (room_id, user_id) VALUES ($1, $2)`, room.RoomID, adminID)
+	if err != nil {
+		return model.Room{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return model.Room{}, err
+	}
+
+	return room, nil
+}
+
+// lockAdmin holds a lock on userID until tx ends and checks userID is the
+// admin of fewer than maxRooms group rooms. The lock is an advisory one as
+// rows that do not exist yet cannot be locked.
+func lockAdmin(tx *sqlx.Tx, userID int64, maxRooms int) error {
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, userID)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	err = tx.Get(&count, `SELECT COUNT(*) FROM room WHERE admin_user_id = $1 AND direct_key IS NULL`, userID)
+	if err != nil {
+		return err
+	}
+	if count >= maxRooms {
+		return ErrRoomLimit
+	}
+
+	return nil
+}
+
+func (dbr *DBResource) UpdateRoom(roomID int64, update model.RoomUpdate) error {
+	query := `
+		UPDATE
+			room
+		SET
+			name = COALESCE($1, name),
+			description = COALESCE($2, description),
+			category_id = COALESCE($3, category_id),
+			avatar = COALESCE($4, avatar)
+		WHERE
+			room_id = $5
 	`
 
-	_, err := dbr.db.Exec(queryToCreate, roomName, adminID, description, categoryID, time.Now())
+	_, err := dbr.db.Exec(query, update.Name, update.Description, update.CategoryID, update.Avatar, roomID)
 	if err != nil {
 		return err
 	}
@@ -77,6 +144,70 @@ func (dbr *DBResource) CreateRoom(roomName string, adminID int64, description st
 	return nil
 }
 
+// UpdateRoomAdmin checks the limit of newAdminID under the same lock as
+// CreateRoom, and only moves the room while oldAdminID still holds it so two
+// concurrent transfers cannot both succeed.
+func (dbr *DBResource) UpdateRoomAdmin(roomID, oldAdminID, newAdminID int64, maxRooms int) error {
+	tx, err := dbr.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = lockAdmin(tx, newAdminID, maxRooms)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE
+			room
+		SET
+			admin_user_id = $1
+		WHERE
+			room_id = $2
+		AND
+			admin_user_id = $3
+	`
+
+	res, err := tx.Exec(query, newAdminID, roomID, oldAdminID)
+	if err != nil {
+		return err
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrAdminChanged
+	}
+
+	return tx.Commit()
+}
+
+func (dbr *DBResource) IsRoomParticipant(roomID, userID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT
+				1
+			FROM
+				room_participant
+			WHERE
+				room_id = $1
+			AND
+				user_id = $2
+		)
+	`
+
+	var isParticipant bool
+	err := dbr.db.Get(&isParticipant, query, roomID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return isParticipant, nil
+}
+
 func (dbr *DBResource) AddRoomParticipant(roomID, userID int64) error {
 	query := `
 		INSERT INTO
@@ -108,7 +239,9 @@ func (dbr *DBResource) GetRoomByID(roomID int64) (model.Room, error) {
 		    admin_user_id,
 		    description,
 		    category_id,
-		    created_at
+		    created_at,
+		    direct_key,
+		    avatar
 		FROM
 			room
 		WHERE
@@ -129,6 +262,8 @@ func (dbr *DBResource) GetRoomByID(roomID int64) (model.Room, error) {
 		Description: r.Description.String,
 		CategoryID:  r.CategoryID.Int64,
 		CreatedAt:   r.CreatedAt,
+		Avatar:      r.Avatar.String,
+		Direct:      r.DirectKey.Valid,
 	}, err
 }
 
@@ -139,6 +274,8 @@ func (dbr *DBResource) GetRooms(userID int64) ([]model.Room, error) {
 			r."name",
 			r.description 
 		FROM room r 
+		WHERE
+			r.direct_key IS NULL
 		EXCEPT
 			SELECT
 				r.room_id,
@@ -187,6 +324,8 @@ func (dbr *DBResource) GetRoomByCategoryID(userID, categoryID int64) ([]model.Ro
 			room r
 		WHERE
 			r.category_id = $1
+		AND
+			r.direct_key IS NULL
 		EXCEPT
 			SELECT
 				r.room_id,
@@ -253,6 +392,32 @@ func (dbr *DBResource) GetCategory() ([]model.Category, error) {
 	return resultCategories, err
 }
 
+func (dbr *DBResource) GetCategoryByID(categoryID int64) (model.Category, error) {
+	query := `
+		SELECT
+			room_category_id,
+			name
+		FROM
+			room_category
+		WHERE
+			room_category_id = $1
+	`
+
+	var c CategoryDB
+	err := dbr.db.Get(&c, query, categoryID)
+	if err == sql.ErrNoRows {
+		return model.Category{}, nil
+	}
+	if err != nil {
+		return model.Category{}, err
+	}
+
+	return model.Category{
+		CategoryID: c.CategoryID.Int64,
+		Name:       c.Name.String,
+	}, nil
+}
+
 func (dbr *DBResource) GetRoomParticipants(roomID int64) ([]model.User, error) {
 	query := `
 		SELECT
@@ -319,3 +484,111 @@ func (dbr *DBResource) DeleteRoom(roomID int64) error {
 
 	return nil
 }
+
+// RemoveUser drops every room membership of userID, used once the account
+// has been deleted in go-accounts.
+func (dbr *DBResource) RemoveUser(userID int64) error {
+	query := `
+		DELETE
+		FROM
+			room_participant
+		WHERE
+			user_id = $1
+	`
+
+	_, err := dbr.db.Exec(query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (dbr *DBResource) GetDirectRoom(directKey string) (model.Room, error) {
+	query := `
+		SELECT
+			room_id,
+			name,
+		    admin_user_id,
+		    description,
+		    category_id,
+		    created_at,
+		    direct_key,
+		    avatar
+		FROM
+			room
+		WHERE
+			direct_key = $1
+	`
+
+	var r RoomDB
+	err := dbr.db.Get(&r, query, directKey)
+	if err == sql.ErrNoRows {
+		return model.Room{}, nil
+	}
+	if err != nil {
+		return model.Room{}, err
+	}
+
+	return model.Room{
+		RoomID:      r.RoomID.Int64,
+		Name:        r.Name.String,
+		AdminUserID: r.AdminUserID.Int64,
+		Description: r.Description.String,
+		CategoryID:  r.CategoryID.Int64,
+		CreatedAt:   r.CreatedAt,
+		Avatar:      r.Avatar.String,
+		Direct:      true,
+	}, nil
+}
+
+// CreateDirectRoom opens a room with userID and peerID as its only
+// participants. userID, who started the conversation, is its admin. When
+// the peer opened the same room concurrently that room is returned.
+func (dbr *DBResource) CreateDirectRoom(directKey string, userID, peerID int64) (model.Room, error) {
+	tx, err := dbr.db.Beginx()
+	if err != nil {
+		return model.Room{}, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO
+			room
+			(name, admin_user_id, description, created_at, direct_key)
+		VALUES
+			('', $1, '', $2, $3)
+		ON CONFLICT (direct_key) DO NOTHING
+		RETURNING
+			room_id
+	`
+
+	room := model.Room{
+		AdminUserID: userID,
+		CreatedAt:   time.Now(),
+		Direct:      true,
+	}
+	err = tx.Get(&room.RoomID, query, userID, room.CreatedAt, directKey)
+	// the peer opened the same room meanwhile, the insert waited for it
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return dbr.GetDirectRoom(directKey)
+	}
+	if err != nil {
+		return model.Room{}, err
+	}
+
+	for _, participant := range []int64{userID, peerID} {
+		_, err = tx.Exec(`INSERT INTO room_participant (room_id, user_id) VALUES ($1, $2)`, room.RoomID, participant)
+		if err != nil {
+			return model.Room{}, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return model.Room{}, err
+	}
+
+	return room, nil
+}
@@ -2,45 +2,41 @@ package groupchat
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
-	"time"
 
 	"github.com/lolmourne/go-groupchat/model"
 )
 
 func (dbr *RedisResource) GetJoinedRoom(userID int64) ([]model.Room, error) {
 	return dbr.next.GetJoinedRoom(userID)
+}
 
-	val, err := dbr.rdb.Get(context.Background(), fmt.Sprintf("roomJoined:%d", userID)).Result()
+func (dbr *RedisResource) CreateRoom(roomName string, adminID int64, description string, categoryID int64, maxRooms int) (model.Room, error) {
+	room, err := dbr.next.CreateRoom(roomName, adminID, description, categoryID, maxRooms)
 	if err != nil {
-		rooms, err := dbr.next.GetJoinedRoom(userID)
-		if err != nil {
-			return rooms, errors.New("no rooms found")
-		}
-		roomJSON, err := json.Marshal(rooms)
-		if err != nil {
-			return rooms, errors.New("fail to marshall")
-		}
-		stats := dbr.rdb.Set(context.Background(), fmt.Sprintf("roomJoined:%d", userID), roomJSON, time.Duration(0))
-		log.Println(stats.Result())
+		return room, err
+	}
 
-		return rooms, err
+	// the admin joins the room it creates
+	err = dbr.rdb.Del(context.Background(), fmt.Sprintf("roomJoined:%d", adminID)).Err()
+	if err != nil {
+		log.Println(err)
 	}
 
-	//return dbr.next.GetJoinedRoom(userID)
+	return room, nil
+}
 
-	var rooms []model.Room
-	json.Unmarshal([]byte(val), &rooms)
+func (dbr *RedisResource) UpdateRoom(roomID int64, update model.RoomUpdate) error {
+	return dbr.next.UpdateRoom(roomID, update)
+}
 
-	return rooms, nil
+func (dbr *RedisResource) UpdateRoomAdmin(roomID, oldAdminID, newAdminID int64, maxRooms int) error {
+	return dbr.next.UpdateRoomAdmin(roomID, oldAdminID, newAdminID, maxRooms)
 }
 
-func (dbr *RedisResource) CreateRoom(roomName string, adminID int64, description string, categoryID int64) error {
-	//no need redis for DML (insert)
-	return dbr.next.CreateRoom(roomName, adminID, description, categoryID)
+func (dbr *RedisResource) IsRoomParticipant(roomID, userID int64) (bool, error) {
+	return dbr.next.IsRoomParticipant(roomID, userID)
 }
 
 func (dbr *RedisResource) AddRoomParticipant(roomID, userID int64) error {
@@ -72,6 +68,10 @@ func (dbr *RedisResource) GetCategory() ([]model.Category, error) {
 	return dbr.next.GetCategory()
 }
 
+func (dbr *RedisResource) GetCategoryByID(categoryID int64) (model.Category, error) {
+	return dbr.next.GetCategoryByID(categoryID)
+}
+
 func (dbr *RedisResource) GetRoomParticipants(roomID int64) ([]model.User, error) {
 	return dbr.next.GetRoomParticipants(roomID)
 }
@@ -83,3 +83,30 @@ func (dbr *RedisResource) LeaveRoom(userID, roomID int64) error {
 func (dbr *RedisResource) DeleteRoom(roomID int64) error {
 	return dbr.next.DeleteRoom(roomID)
 }
+
+func (dbr *RedisResource) RemoveUser(userID int64) error {
+	err := dbr.next.RemoveUser(userID)
+	if err != nil {
+		return err
+	}
+
+	return dbr.rdb.Del(context.Background(), fmt.Sprintf("roomJoined:%d", userID)).Err()
+}
+
+func (dbr *RedisResource) GetDirectRoom(directKey string) (model.Room, error) {
+	return dbr.next.GetDirectRoom(directKey)
+}
+
+func (dbr *RedisResource) CreateDirectRoom(directKey string, userID, peerID int64) (model.Room, error) {
+	room, err := dbr.next.CreateDirectRoom(directKey, userID, peerID)
+	if err != nil {
+		return room, err
+	}
+
+	err = dbr.rdb.Del(context.Background(), fmt.Sprintf("roomJoined:%d", userID), fmt.Sprintf("roomJoined:%d", peerID)).Err()
+	if err != nil {
+		log.Println(err)
+	}
+
+	return room, nil
+}
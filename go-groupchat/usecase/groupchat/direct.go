@@ -0,0 +1,70 @@
+package groupchat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/lolmourne/go-accounts/client/userauth"
+	"github.com/lolmourne/go-groupchat/model"
+)
+
+var (
+	ErrDirectSelf     = errors.New("cannot open a direct room with yourself")
+	ErrUserNotFound   = errors.New("user not found")
+	ErrBlocked        = errors.New("cannot open a direct room with this user")
+	ErrDirectRoomJoin = errors.New("direct rooms cannot be joined")
+)
+
+// CreateDirectRoom returns the direct room between userID and peerID,
+// opening it on first contact. It is refused when either user blocked the
+// other, and when the block status cannot be checked.
+func (u UseCase) CreateDirectRoom(ctx context.Context, accessToken string, userID, peerID int64) (model.Room, error) {
+	if userID == peerID {
+		return model.Room{}, ErrDirectSelf
+	}
+
+	_, err := u.userClient.GetUserByID(ctx, peerID)
+	if errors.Is(err, userauth.ErrNotFound) {
+		return model.Room{}, ErrUserNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return model.Room{}, errors.New("Internal Server Error")
+	}
+
+	blocked, err := u.userClient.IsBlocked(ctx, accessToken, peerID)
+	if err != nil {
+		log.Println(err)
+		return model.Room{}, errors.New("Internal Server Error")
+	}
+	if blocked {
+		return model.Room{}, ErrBlocked
+	}
+
+	key := directKey(userID, peerID)
+	room, err := u.dbRoomRsc.GetDirectRoom(key)
+	if err != nil {
+		log.Println(err)
+		return model.Room{}, errors.New("Internal Server Error")
+	}
+	if room.RoomID != 0 {
+		return room, nil
+	}
+
+	room, err = u.dbRoomRsc.CreateDirectRoom(key, userID, peerID)
+	if err != nil {
+		log.Println(err)
+		return model.Room{}, errors.New("Internal Server Error")
+	}
+
+	return room, nil
+}
+
+func directKey(userID, peerID int64) string {
+	if userID > peerID {
+		userID, peerID = peerID, userID
+	}
+	return fmt.Sprintf("%d:%d", userID, peerID)
+}
@@ -1,27 +1,40 @@
 package groupchat
 
 import (
+	"context"
+
+	"github.com/lolmourne/go-accounts/client/userauth"
+	"github.com/lolmourne/go-accounts/resource/events"
 	"github.com/lolmourne/go-groupchat/model"
 	"github.com/lolmourne/go-groupchat/resource/groupchat"
 )
 
 type UseCase struct {
-	dbRoomRsc groupchat.DBItf
+	dbRoomRsc  groupchat.DBItf
+	userClient userauth.ClientItf
+	publisher  events.Publisher
 }
 
 type UsecaseItf interface {
 	CreateGroupchat(name string, adminID int64, desc string, categoryID int64) (model.Room, error)
-	EditGroupchat(name, desc, categoryID string) (model.Room, error)
-	GetRoomByID(roomID int64) (model.Room, error)
+	EditGroupchat(roomID, userID int64, update model.RoomUpdate) (model.Room, error)
+	TransferGroupchat(roomID, userID, newAdminID int64) (model.Room, error)
+	GetRoomByID(roomID, userID int64) (model.Room, error)
+	GetRoomParticipants(roomID, userID int64) ([]model.User, error)
 	GetRoomList(userID int64) ([]model.Room, error)
 	GetRoomByCategoryID(userID, categoryID int64) ([]model.Room, error)
 	JoinRoom(roomID, userID int64) error
 	DeleteRoom(roomID, userID int64) error
+	ExportUserData(userID int64) (model.UserExport, error)
+	RemoveUser(userID int64) error
+	CreateDirectRoom(ctx context.Context, accessToken string, userID, peerID int64) (model.Room, error)
 }
 
-func NewUseCase(dbRsc groupchat.DBItf) UsecaseItf {
+func NewUseCase(dbRsc groupchat.DBItf, userClient userauth.ClientItf, publisher events.Publisher) UsecaseItf {
 	return UseCase{
-		dbRoomRsc: dbRsc,
+		dbRoomRsc:  dbRsc,
+		userClient: userClient,
+		publisher:  publisher,
 	}
 
 }
@@ -1,39 +1,304 @@
 package groupchat
 
 import (
+	"database/sql"
 	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/lolmourne/go-groupchat/client"
 	"github.com/lolmourne/go-groupchat/model"
+	"github.com/lolmourne/go-groupchat/resource/groupchat"
 )
 
+const (
+	maxRoomNameLen        = 50
+	maxRoomDescriptionLen = 500
+	maxRoomAvatarLen      = 500
+	// maxRoomsPerUser bounds the group rooms a user can be the admin of
+	maxRoomsPerUser = 10
+)
+
+var (
+	ErrInvalidRoomName        = errors.New("room name must be 1 to 50 characters")
+	ErrInvalidRoomDescription = errors.New("room description must be at most 500 characters")
+	ErrCategoryNotFound       = errors.New("category not found")
+	ErrTooManyRooms           = errors.New("too many rooms, delete unused ones first")
+	ErrInvalidRoomAvatar      = errors.New("room avatar must be an http or https URL of at most 500 characters")
+	ErrRoomNotFound           = errors.New("room not found")
+	ErrNotRoomAdmin           = errors.New("user is not an admin")
+	ErrDirectRoomEdit         = errors.New("direct rooms cannot be edited")
+	ErrTransferSelf           = errors.New("you are already the admin of this room")
+	ErrNotParticipant         = errors.New("the new admin must be a participant of the room")
+	ErrNewAdminTooManyRooms   = errors.New("the new admin already has too many rooms")
+)
+
+// CreateGroupchat opens a group room in an existing category with adminID
+// as its admin and first participant.
 func (u UseCase) CreateGroupchat(name string, adminID int64, desc string, categoryID int64) (model.Room, error) {
+	name, err := validRoomName(name)
+	if err != nil {
+		return model.Room{}, err
+	}
+
+	desc, err = validRoomDescription(desc)
+	if err != nil {
+		return model.Room{}, err
+	}
+
+	err = u.checkCategory(categoryID)
+	if err != nil {
+		return model.Room{}, err
+	}
+
+	room, err := u.dbRoomRsc.CreateRoom(name, adminID, desc, categoryID, maxRoomsPerUser)
+	if err == groupchat.ErrRoomLimit {
+		return model.Room{}, ErrTooManyRooms
+	}
+	if err != nil {
+		log.Println(err)
+		return model.Room{}, errors.New("Internal Server Error")
+	}
+
+	return room, nil
+}
+
+// EditGroupchat applies update to a group room userID is the admin of and
+// tells the connected clients about it.
+func (u UseCase) EditGroupchat(roomID, userID int64, update model.RoomUpdate) (model.Room, error) {
+	room, err := u.adminRoom(roomID, userID)
+	if err != nil {
+		return model.Room{}, err
+	}
+
+	if update.Name != nil {
+		name, err := validRoomName(*update.Name)
+		if err != nil {
+			return model.Room{}, err
+		}
+		update.Name = &name
+	}
+	if update.Description != nil {
+		desc, err := validRoomDescription(*update.Description)
+		if err != nil {
+			return model.Room{}, err
+		}
+		update.Description = &desc
+	}
+	if update.CategoryID != nil {
+		err = u.checkCategory(*update.CategoryID)
+		if err != nil {
+			return model.Room{}, err
+		}
+	}
+	if update.Avatar != nil {
+		avatar, err := validRoomAvatar(*update.Avatar)
+		if err != nil {
+			return model.Room{}, err
+		}
+		update.Avatar = &avatar
+	}
+
+	if update.Name == nil && update.Description == nil && update.CategoryID == nil && update.Avatar == nil {
+		return room, nil
+	}
+
+	err = u.dbRoomRsc.UpdateRoom(roomID, update)
+	if err != nil {
+		log.Println(err)
+		return model.Room{}, errors.New("Internal Server Error")
+	}
 
-	err := u.dbRoomRsc.CreateRoom(name, adminID, desc, categoryID)
+	room, err = u.dbRoomRsc.GetRoomByID(roomID)
+	if err != nil {
+		log.Println(err)
+		return model.Room{}, errors.New("Internal Server Error")
+	}
+
+	u.publishRoom(client.RoomUpdated, room)
+	return room, nil
+}
+
+// TransferGroupchat hands the admin rights of a group room from userID to
+// newAdminID, who must already take part in it.
+func (u UseCase) TransferGroupchat(roomID, userID, newAdminID int64) (model.Room, error) {
+	room, err := u.adminRoom(roomID, userID)
 	if err != nil {
 		return model.Room{}, err
 	}
+	if newAdminID == userID {
+		return model.Room{}, ErrTransferSelf
+	}
+
+	isParticipant, err := u.dbRoomRsc.IsRoomParticipant(roomID, newAdminID)
+	if err != nil {
+		log.Println(err)
+		return model.Room{}, errors.New("Internal Server Error")
+	}
+	if !isParticipant {
+		return model.Room{}, ErrNotParticipant
+	}
+
+	err = u.dbRoomRsc.UpdateRoomAdmin(roomID, userID, newAdminID, maxRoomsPerUser)
+	if err == groupchat.ErrRoomLimit {
+		return model.Room{}, ErrNewAdminTooManyRooms
+	}
+	// another request moved the room first
+	if err == groupchat.ErrAdminChanged {
+		return model.Room{}, ErrNotRoomAdmin
+	}
+	if err != nil {
+		log.Println(err)
+		return model.Room{}, errors.New("Internal Server Error")
+	}
+
+	room.AdminUserID = newAdminID
+	u.publishRoom(client.RoomTransferred, room)
+	return room, nil
+}
+
+// adminRoom loads a group room and checks userID is its admin.
+func (u UseCase) adminRoom(roomID, userID int64) (model.Room, error) {
+	room, err := u.dbRoomRsc.GetRoomByID(roomID)
+	if err == sql.ErrNoRows {
+		return model.Room{}, ErrRoomNotFound
+	}
+	if err != nil {
+		return model.Room{}, errors.New("Internal Server Error")
+	}
+
+	if room.Direct {
+		return model.Room{}, ErrDirectRoomEdit
+	}
+	if room.AdminUserID != userID {
+		return model.Room{}, ErrNotRoomAdmin
+	}
+	return room, nil
+}
+
+func (u UseCase) checkCategory(categoryID int64) error {
+	if categoryID < 1 {
+		return ErrCategoryNotFound
+	}
+
+	category, err := u.dbRoomRsc.GetCategoryByID(categoryID)
+	if err != nil {
+		log.Println(err)
+		return errors.New("Internal Server Error")
+	}
+	if category.CategoryID == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+func (u UseCase) publishRoom(eventType string, room model.Room) {
+	err := u.publisher.Publish(client.RoomChangedChannel, client.RoomChangedEvent{
+		Type: eventType,
+		Room: client.Room{
+			RoomID:      room.RoomID,
+			Name:        room.Name,
+			Avatar:      room.Avatar,
+			AdminUserID: room.AdminUserID,
+			Description: room.Description,
+			CategoryID:  room.CategoryID,
+			CreatedAt:   room.CreatedAt,
+		},
+	})
+	if err != nil {
+		log.Println("cannot publish change of room", room.RoomID, err)
+	}
+}
 
-	return model.Room{
-		Name:        name,
-		AdminUserID: adminID,
-		Description: desc,
-		CategoryID:  categoryID,
-	}, nil
+func validRoomName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxRoomNameLen || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return "", ErrInvalidRoomName
+	}
+	return name, nil
+}
 
+func validRoomDescription(desc string) (string, error) {
+	desc = strings.TrimSpace(desc)
+	if utf8.RuneCountInString(desc) > maxRoomDescriptionLen {
+		return "", ErrInvalidRoomDescription
+	}
+	return desc, nil
 }
 
-func (u UseCase) EditGroupchat(name, desc, categoryID string) (model.Room, error) {
-	panic("TBC")
+// validRoomAvatar accepts an absolute http or https URL, or an empty string
+// to remove the avatar.
+func validRoomAvatar(avatar string) (string, error) {
+	avatar = strings.TrimSpace(avatar)
+	if avatar == "" {
+		return "", nil
+	}
+	if len(avatar) > maxRoomAvatarLen {
+		return "", ErrInvalidRoomAvatar
+	}
+
+	parsed, err := url.Parse(avatar)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", ErrInvalidRoomAvatar
+	}
+	return avatar, nil
 }
 
 func (u UseCase) JoinRoom(roomID, userID int64) error {
-	err := u.dbRoomRsc.AddRoomParticipant(roomID, userID)
+	room, err := u.dbRoomRsc.GetRoomByID(roomID)
+	if err != nil {
+		return err
+	}
+
+	if room.Direct {
+		return ErrDirectRoomJoin
+	}
+
+	err = u.dbRoomRsc.AddRoomParticipant(roomID, userID)
 
 	return err
 }
 
-func (u UseCase) GetRoomByID(roomID int64) (model.Room, error) {
-	return u.dbRoomRsc.GetRoomByID(roomID)
+// GetRoomByID returns the room as seen by userID, 0 for anonymous callers.
+// Direct rooms are private, everyone but their two participants gets
+// ErrRoomNotFound.
+func (u UseCase) GetRoomByID(roomID, userID int64) (model.Room, error) {
+	room, err := u.dbRoomRsc.GetRoomByID(roomID)
+	if err == sql.ErrNoRows {
+		return model.Room{}, ErrRoomNotFound
+	}
+	if err != nil {
+		return model.Room{}, errors.New("Internal Server Error")
+	}
+
+	if !room.Direct {
+		return room, nil
+	}
+	if userID < 1 {
+		return model.Room{}, ErrRoomNotFound
+	}
+	participant, err := u.dbRoomRsc.IsRoomParticipant(roomID, userID)
+	if err != nil {
+		log.Println(err)
+		return model.Room{}, errors.New("Internal Server Error")
+	}
+	if !participant {
+		return model.Room{}, ErrRoomNotFound
+	}
+	return room, nil
+}
+
+// GetRoomParticipants lists the participants of a room userID can see.
+func (u UseCase) GetRoomParticipants(roomID, userID int64) ([]model.User, error) {
+	_, err := u.GetRoomByID(roomID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.dbRoomRsc.GetRoomParticipants(roomID)
 }
 
 func (u UseCase) GetRoomList(userID int64) ([]model.Room, error) {
@@ -61,3 +326,29 @@ func (u UseCase) DeleteRoom(roomID, userID int64) error {
 
 	return nil
 }
+
+// ExportUserData gathers what groupchat keeps about userID for the account
+// data export of go-accounts.
+func (u UseCase) ExportUserData(userID int64) (model.UserExport, error) {
+	rooms, err := u.dbRoomRsc.GetJoinedRoom(userID)
+	if err != nil {
+		return model.UserExport{}, err
+	}
+
+	export := model.UserExport{
+		JoinedRooms:  []model.Room{},
+		CreatedRooms: []model.Room{},
+	}
+	for _, room := range rooms {
+		export.JoinedRooms = append(export.JoinedRooms, room)
+		if room.AdminUserID == userID {
+			export.CreatedRooms = append(export.CreatedRooms, room)
+		}
+	}
+
+	return export, nil
+}
+
+func (u UseCase) RemoveUser(userID int64) error {
+	return u.dbRoomRsc.RemoveUser(userID)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	authClient "github.com/lolmourne/go-accounts/client/userauth"
+	accModel "github.com/lolmourne/go-accounts/model"
+	"github.com/lolmourne/go-accounts/resource/events"
+	"github.com/lolmourne/go-groupchat/client"
+	"github.com/lolmourne/go-websocket/model"
+	"github.com/lolmourne/go-websocket/resource/chat"
+)
+
+type exportResponse struct {
+	Err  string      `json:"err"`
+	Data interface{} `json:"data"`
+}
+
+// serveExport returns every message the caller wrote, for the accounts
+// service to bundle into the user's data export.
+func serveExport(chatRsc chat.IResource, auCli authClient.ClientItf, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInfo, err := auCli.GetUserInfo(r.Context(), r.Header.Get("X-Access-Token"))
+	if errors.Is(err, authClient.ErrUnauthorized) {
+		writeJSON(w, http.StatusUnauthorized, exportResponse{Err: "Unauthorized"})
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		writeJSON(w, http.StatusServiceUnavailable, exportResponse{Err: "Service Unavailable"})
+		return
+	}
+	if !userInfo.Allows(accModel.ScopeRead) {
+		writeJSON(w, http.StatusForbidden, exportResponse{Err: "Token lacks the " + accModel.ScopeRead + " scope"})
+		return
+	}
+
+	chats, err := chatRsc.GetChatsByUser(userInfo.UserID)
+	if err != nil {
+		log.Println(err)
+		writeJSON(w, http.StatusInternalServerError, exportResponse{Err: "Internal Server Error"})
+		return
+	}
+	if chats == nil {
+		chats = []model.Chat{}
+	}
+
+	writeJSON(w, http.StatusOK, exportResponse{
+		Err: "null",
+		Data: map[string]interface{}{
+			"messages": chats,
+		},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// anonymizeDeletedUser blanks the messages of accounts purged by the
+// accounts service and confirms it. Deletions are delivered again until
+// confirmed, anonymizing twice is harmless.
+func anonymizeDeletedUser(chatRsc chat.IResource, publisher events.Publisher) func(string, error) {
+	return func(msg string, err error) {
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		var event authClient.AccountDeletedEvent
+		err = json.Unmarshal([]byte(msg), &event)
+		if err != nil || event.UserID < 1 {
+			log.Println("invalid account deleted event", msg)
+			return
+		}
+
+		err = chatRsc.AnonymizeUserChats(event.UserID)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		err = publisher.Publish(authClient.AccountPurgedChannel, authClient.AccountPurgedEvent{
+			UserID:  event.UserID,
+			Service: authClient.ServiceWebsocket,
+		})
+		if err != nil {
+			log.Println("cannot confirm purge of user", event.UserID, err)
+		}
+	}
+}
+
+// updateBlocks applies blocks made in go-accounts to the open connections of
+// the blocker.
+func updateBlocks(roomMgr RoomManagerItf) func(string, error) {
+	return func(msg string, err error) {
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		var event authClient.BlockChangedEvent
+		err = json.Unmarshal([]byte(msg), &event)
+		if err != nil || event.UserID < 1 {
+			log.Println("invalid block changed event", msg)
+			return
+		}
+
+		roomMgr.SetBlocked(event.UserID, event.BlockedUserID, event.Blocked)
+	}
+}
+
+// roomMessage is sent to the connections of a room when it changes.
+type roomMessage struct {
+	Room client.RoomChangedEvent `json:"room"`
+}
+
+// updateRooms forwards room edits and admin transfers made in go-groupchat
+// to the connections open on the room, so clients refresh its header.
+func updateRooms(roomMgr RoomManagerItf) func(string, error) {
+	return func(msg string, err error) {
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		var event client.RoomChangedEvent
+		err = json.Unmarshal([]byte(msg), &event)
+		if err != nil || event.Room.RoomID < 1 {
+			log.Println("invalid room changed event", msg)
+			return
+		}
+
+		payload, err := json.Marshal(roomMessage{Room: event})
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		roomMgr.SendToRoom(event.Room.RoomID, payload)
+	}
+}
@@ -19,6 +19,8 @@ import (
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	authClient "github.com/lolmourne/go-accounts/client/userauth"
+	"github.com/lolmourne/go-accounts/resource/events"
+	"github.com/lolmourne/go-accounts/resource/presence"
 	"github.com/lolmourne/go-groupchat/client"
 	"github.com/lolmourne/go-websocket/model"
 	"github.com/lolmourne/go-websocket/resource/chat"
@@ -30,6 +32,7 @@ import (
 var addr = flag.String("addr", ":90", "http service address")
 var sub pubsub.RedisPubsub
 var rdb *redis.Client
+var userPresence *presence.RedisPresence
 
 func serveHome(w http.ResponseWriter, r *http.Request) {
 	log.Println(r.URL)
@@ -67,6 +70,7 @@ func main() {
 		Password: cfg.Redis.Password, // no password set
 		DB:       0,                  // use default DB
 	})
+	userPresence = presence.NewRedisPresence(rdb, presence.ConnectionTTL)
 
 	redisClient := redisCli.New(redisCli.SINGLE_MODE, cfg.Redis.Host, 10,
 		redigo.DialReadTimeout(time.Duration(30)*time.Second),
@@ -87,7 +91,24 @@ func main() {
 	}(chString)
 
 	gcClient := client.NewClient("http://localhost:8080", time.Duration(30)*time.Second)
-	auCli := authClient.NewClient("http://localhost:7070", time.Duration(30)*time.Second)
+	accountsHost := cfg.Accounts.Host
+	if accountsHost == "" {
+		accountsHost = "http://localhost:7070"
+	}
+	accountsCli, err := authClient.NewClientFromConfig(authClient.ClientConfig{
+		Transport:   cfg.Accounts.Transport,
+		Host:        accountsHost,
+		GRPCAddress: cfg.Accounts.GRPCAddress,
+		Timeout:     time.Duration(30) * time.Second,
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	auCli := authClient.NewLocalClient(
+		authClient.NewVerifier(accountsHost, time.Duration(5)*time.Second, time.Duration(10)*time.Minute),
+		authClient.NewRedisDenylist(rdb),
+		accountsCli,
+	)
 	userRsc := user.NewAuthCliRsc(auCli, time.Duration(60), time.Duration(30))
 
 	dbConStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", cfg.DB.Address, cfg.DB.Port, cfg.DB.User, cfg.DB.Password, cfg.DB.DBName)
@@ -99,6 +120,10 @@ func main() {
 
 	dbRsc := chat.NewDBResource(dbInit)
 	roomMgr := NewRoomManager(gcClient, dbRsc, userRsc)
+	sub.Subscribe(authClient.AccountDeletedChannel, anonymizeDeletedUser(dbRsc, events.NewRedisPublisher(rdb)), true)
+	sub.Subscribe(authClient.BlockChangedChannel, updateBlocks(roomMgr), true)
+	sub.Subscribe(authClient.ContactChangedChannel, notifyContacts(roomMgr), true)
+	sub.Subscribe(client.RoomChangedChannel, updateRooms(roomMgr), true)
 
 	http.HandleFunc("/", serveHome)
 	http.HandleFunc("/ch_test", func(w http.ResponseWriter, r *http.Request) {
@@ -113,6 +138,9 @@ func main() {
 	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		ServeWs(roomMgr, auCli, w, r)
 	})
+	http.HandleFunc("/me/export", func(w http.ResponseWriter, r *http.Request) {
+		serveExport(dbRsc, auCli, w, r)
+	})
 	log.Println("RUNNING----")
 	err = http.ListenAndServe(*addr, nil)
 	if err != nil {
@@ -6,14 +6,18 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	authClient "github.com/lolmourne/go-accounts/client/userauth"
+	accModel "github.com/lolmourne/go-accounts/model"
 	"github.com/lolmourne/go-websocket/model"
 )
 
@@ -55,12 +59,51 @@ type Client struct {
 	send chan []byte
 
 	usr User
+
+	// blocked holds the users whose messages are not delivered to this
+	// client. It is updated from the accounts pubsub while the hub reads it.
+	blocked   map[int64]bool
+	blockedMu sync.RWMutex
+
+	// connID identifies the connection in the shared presence store, empty
+	// when it could not be recorded.
+	connID string
 }
 
 type User struct {
-	UserID     int64
-	ProfilePic string
-	Username   string
+	UserID      int64
+	ProfilePic  string
+	Username    string
+	DisplayName string
+}
+
+func (c *Client) isBlocked(userID int64) bool {
+	c.blockedMu.RLock()
+	defer c.blockedMu.RUnlock()
+	return c.blocked[userID]
+}
+
+func (c *Client) setBlocked(userID int64, blocked bool) {
+	c.blockedMu.Lock()
+	defer c.blockedMu.Unlock()
+	if blocked {
+		c.blocked[userID] = true
+	} else {
+		delete(c.blocked, userID)
+	}
+}
+
+// trySend queues msg without waiting. A client that is this far behind is
+// too slow to keep up, it misses msg instead of stalling the sender. The
+// caller must hold the hub's lock so send is not closed meanwhile.
+func (c *Client) trySend(msg []byte) bool {
+	select {
+	case c.send <- msg:
+		return true
+	default:
+		log.Println("dropped message for slow client", c.usr.UserID)
+		return false
+	}
 }
 
 // readPump pumps messages from the websocket connection to the hub.
@@ -72,6 +115,12 @@ func (c *Client) readPump() {
 	defer func() {
 		c.hub.unregister <- c
 		c.conn.Close()
+		if c.connID != "" {
+			err := userPresence.Disconnect(context.Background(), c.usr.UserID, c.connID)
+			if err != nil {
+				log.Println("cannot clear presence of", c.usr.UserID, err)
+			}
+		}
 	}()
 	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -93,10 +142,11 @@ func (c *Client) readPump() {
 		c.hub.chatRsc.AddChat(c.hub.roomID, c.usr.UserID, string(message))
 
 		msgObj := model.Message{
-			UserID:     c.usr.UserID,
-			ProfilePic: c.usr.ProfilePic,
-			UserName:   c.usr.Username,
-			Msg:        string(message),
+			UserID:      c.usr.UserID,
+			ProfilePic:  c.usr.ProfilePic,
+			UserName:    c.usr.Username,
+			DisplayName: c.usr.DisplayName,
+			Msg:         string(message),
 		}
 
 		msg, err := json.Marshal(msgObj)
@@ -150,12 +200,18 @@ func (c *Client) writePump() {
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
+			if c.connID != "" {
+				err := userPresence.Refresh(context.Background(), c.usr.UserID, c.connID)
+				if err != nil {
+					log.Println("cannot refresh presence of", c.usr.UserID, err)
+				}
+			}
 		}
 	}
 }
 
 // serveWs handles websocket requests from the peer.
-func ServeWs(room RoomManagerItf, authClient authClient.ClientItf, w http.ResponseWriter, r *http.Request) {
+func ServeWs(room RoomManagerItf, auCli authClient.ClientItf, w http.ResponseWriter, r *http.Request) {
 	roomIDStr := r.URL.Query().Get("room_id")
 	roomID, err := strconv.ParseInt(roomIDStr, 10, 64)
 	if err != nil {
@@ -169,14 +225,27 @@ func ServeWs(room RoomManagerItf, authClient authClient.ClientItf, w http.Respon
 		return
 	}
 
-	userInfo := authClient.GetUserInfo(authToken)
-	if userInfo == nil {
+	userInfo, err := auCli.GetUserInfo(r.Context(), authToken)
+	if errors.Is(err, authClient.ErrUnauthorized) {
 		log.Println("auth failed")
 		w.Write([]byte("User not found"))
 		return
 	}
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("Cannot validate session, try again later"))
+		return
+	}
+	// personal access tokens of bots and scripts need the chat scope
+	if !userInfo.Allows(accModel.ScopeChat) {
+		log.Println("token lacks the chat scope", userInfo.UserID)
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("Token lacks the chat scope"))
+		return
+	}
 
-	hub := room.JoinRoom(roomID)
+	hub := room.JoinRoom(roomID, authToken)
 	if hub == nil {
 		w.Write([]byte("Room not found"))
 		return
@@ -189,11 +258,30 @@ func ServeWs(room RoomManagerItf, authClient authClient.ClientItf, w http.Respon
 	}
 
 	user := User{
-		UserID:     userInfo.UserID,
-		Username:   userInfo.Username,
-		ProfilePic: userInfo.ProfilePic,
+		UserID:      userInfo.UserID,
+		Username:    userInfo.Username,
+		DisplayName: userInfo.DisplayName,
+		ProfilePic:  userInfo.ProfilePic,
+	}
+	if small, ok := userInfo.ProfilePicVariants["64"]; ok {
+		user.ProfilePic = small
+	}
+	blocked := make(map[int64]bool)
+	blockedUsers, err := auCli.GetBlockedUsers(r.Context(), authToken)
+	if err != nil {
+		// blocked users are still hidden through block events from now on
+		log.Println("cannot load blocked users", user.UserID, err)
+	}
+	for _, b := range blockedUsers {
+		blocked[b.User.UserID] = true
+	}
+
+	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256), usr: user, blocked: blocked}
+	// contacts see the user online on every instance
+	client.connID, err = userPresence.Connect(r.Context(), user.UserID)
+	if err != nil {
+		log.Println("cannot record presence of", user.UserID, err)
 	}
-	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256), usr: user}
 	client.hub.register <- client
 
 	go client.writePump()
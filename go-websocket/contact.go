@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+
+	authClient "github.com/lolmourne/go-accounts/client/userauth"
+)
+
+// contactMessage is sent to the connections of both users of a contact
+// change.
+type contactMessage struct {
+	Contact authClient.ContactChangedEvent `json:"contact"`
+}
+
+// notifyContacts forwards contact changes made in go-accounts to the open
+// connections of both users.
+func notifyContacts(roomMgr RoomManagerItf) func(string, error) {
+	return func(msg string, err error) {
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		var event authClient.ContactChangedEvent
+		err = json.Unmarshal([]byte(msg), &event)
+		if err != nil || event.UserID < 1 || event.ContactUserID < 1 {
+			log.Println("invalid contact changed event", msg)
+			return
+		}
+
+		payload, err := json.Marshal(contactMessage{Contact: event})
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		roomMgr.SendToUser(event.UserID, payload)
+		roomMgr.SendToUser(event.ContactUserID, payload)
+	}
+}
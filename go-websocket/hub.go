@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -22,8 +23,11 @@ import (
 // clients.
 type Hub struct {
 
-	// Registered clients.
+	// Registered clients. run writes the map and closes the send channels
+	// of removed clients under mu, everything else reads it under mu.RLock
+	// so nothing sends on a closed channel.
 	clients map[*Client]bool
+	mu      sync.RWMutex
 
 	// Inbound messages from the clients.
 	broadcast chan []byte
@@ -64,16 +68,43 @@ func (h *Hub) readRoomPubsub(msg string, err error) {
 		return
 	}
 
+	var chatMsg model.Message
+	err = json.Unmarshal([]byte(msg), &chatMsg)
+	if err != nil {
+		log.Println(err)
+	}
+
+	h.sendTo(func(client *Client) bool {
+		return !client.isBlocked(chatMsg.UserID)
+	}, []byte(msg))
+}
+
+// forEach calls fn with every registered client, fn must not block.
+func (h *Hub) forEach(fn func(client *Client)) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 	for client := range h.clients {
-		client.send <- []byte(msg)
+		fn(client)
 	}
 }
 
+// sendTo queues msg for the registered clients accepted by filter, all of
+// them when filter is nil.
+func (h *Hub) sendTo(filter func(client *Client) bool, msg []byte) {
+	h.forEach(func(client *Client) {
+		if filter == nil || filter(client) {
+			client.trySend(msg)
+		}
+	})
+}
+
 func (h *Hub) run() {
 	for {
 		select {
 		case client := <-h.register:
+			h.mu.Lock()
 			h.clients[client] = true
+			h.mu.Unlock()
 
 			year, month, day := time.Now().Date()
 			startTime := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
@@ -88,24 +119,32 @@ func (h *Hub) run() {
 				continue
 			}
 
-			msgChats := make([]model.Message, len(chats))
-			for id, chat := range chats {
-				userChat := h.userRsc.GetUserByID(chat.UserID)
-				if userChat == nil {
+			userIDs := make([]int64, len(chats))
+			for i, chat := range chats {
+				userIDs[i] = chat.UserID
+			}
+			users := h.userRsc.GetUsersByIDs(userIDs)
+
+			msgChats := make([]model.Message, 0, len(chats))
+			for _, chat := range chats {
+				userChat, ok := users[chat.UserID]
+				if !ok || client.isBlocked(chat.UserID) {
 					continue
 				}
 
-				if userChat.ProfilePic == "" {
-					userChat.ProfilePic = "https://i.imgur.com/cINvch3.png"
+				profilePic := userChat.ChatAvatar()
+				if profilePic == "" {
+					profilePic = "https://i.imgur.com/cINvch3.png"
 				}
 
 				msgChat := model.Message{
-					UserID:     chat.UserID,
-					ProfilePic: userChat.ProfilePic,
-					UserName:   userChat.Username,
-					Msg:        chat.Message,
+					UserID:      chat.UserID,
+					ProfilePic:  profilePic,
+					UserName:    userChat.Username,
+					DisplayName: userChat.DisplayName,
+					Msg:         chat.Message,
 				}
-				msgChats[id] = msgChat
+				msgChats = append(msgChats, msgChat)
 
 			}
 
@@ -117,13 +156,17 @@ func (h *Hub) run() {
 				log.Println(err)
 				continue
 			}
-			client.send <- msgJson
+			h.sendTo(func(c *Client) bool {
+				return c == client
+			}, msgJson)
 
 		case client := <-h.unregister:
+			h.mu.Lock()
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
 				close(client.send)
 			}
+			h.mu.Unlock()
 		case message := <-h.broadcast:
 			h.redisClient.Publish(context.Background(), fmt.Sprintf("pubsub:chat:%d", h.roomID), message)
 		}
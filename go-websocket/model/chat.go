@@ -10,16 +10,31 @@ type Chat struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// DeletedMessage replaces the text of messages written by deleted accounts.
+const DeletedMessage = "[deleted]"
+
 type Message struct {
-	UserID     int64  `json:"user_id"`
-	UserName   string `json:"username"`
-	ProfilePic string `json:"profile_pic"`
-	Msg        string `json:"msg"`
+	UserID      int64  `json:"user_id"`
+	UserName    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	ProfilePic  string `json:"profile_pic"`
+	Msg         string `json:"msg"`
 }
 
 type User struct {
-	UserID     int64     `json:"user_id"`
-	Username   string    `json:"username"`
-	CreatedAt  time.Time `json:"created_at"`
-	ProfilePic string    `json:"profile_pic"`
+	UserID             int64             `json:"user_id"`
+	Username           string            `json:"username"`
+	DisplayName        string            `json:"display_name"`
+	CreatedAt          time.Time         `json:"created_at"`
+	ProfilePic         string            `json:"profile_pic"`
+	ProfilePicVariants map[string]string `json:"profile_pic_variants"`
+}
+
+// ChatAvatar returns the avatar size shown next to chat messages, falling
+// back to the original for pictures uploaded before variants existed.
+func (u *User) ChatAvatar() string {
+	if small, ok := u.ProfilePicVariants["64"]; ok {
+		return small
+	}
+	return u.ProfilePic
 }
@@ -1,10 +1,11 @@
 package model
 
 type Config struct {
-	DB     PostgreCfg   `json:"postgresql"`
-	Redis  RedisCfg     `json:"redis"`
-	JWT    JWTCfg       `json:"jwt"`
-	S3Cred S3Credential `json:"s3"`
+	DB       PostgreCfg   `json:"postgresql"`
+	Redis    RedisCfg     `json:"redis"`
+	JWT      JWTCfg       `json:"jwt"`
+	S3Cred   S3Credential `json:"s3"`
+	Accounts AccountsCfg  `json:"accounts"`
 }
 
 type PostgreCfg struct {
@@ -29,3 +30,12 @@ type S3Credential struct {
 	Secret     string `json:"secret"`
 	BucketName string `json:"bucket_name"`
 }
+
+// AccountsCfg locates go-accounts. Transport is "http", the default, or
+// "grpc" to reach the Accounts gRPC service at GRPCAddress. Access tokens are
+// verified against the keys published under Host either way.
+type AccountsCfg struct {
+	Host        string `json:"host"`
+	Transport   string `json:"transport"`
+	GRPCAddress string `json:"grpc_address"`
+}
@@ -11,6 +11,8 @@ import (
 type IResource interface {
 	AddChat(roomID, userID int64, message string) error
 	GetChatsByRoomByDate(roomID int64, startDate time.Time, endDate time.Time) []model.Chat
+	GetChatsByUser(userID int64) ([]model.Chat, error)
+	AnonymizeUserChats(userID int64) error
 }
 
 type DBResource struct {
@@ -77,3 +77,60 @@ func (dbr *DBResource) GetChatsByRoomByDate(roomID int64, startDate time.Time, e
 
 	return resultChats
 }
+
+// GetChatsByUser returns every message userID wrote, oldest first.
+func (dbr *DBResource) GetChatsByUser(userID int64) ([]model.Chat, error) {
+	query := `
+		SELECT
+			chat_id,
+			room_id,
+			user_id,
+			message,
+			created_at
+		FROM
+			chat
+		WHERE
+			user_id = $1
+		ORDER BY
+			created_at
+	`
+
+	var chatsDB []ChatDB
+	err := dbr.db.Select(&chatsDB, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	chats := make([]model.Chat, len(chatsDB))
+	for i, r := range chatsDB {
+		chats[i] = model.Chat{
+			ChatID:    r.ChatID.Int64,
+			Message:   r.Message.String,
+			CreatedAt: r.CreatedAt,
+			RoomID:    r.RoomID.Int64,
+			UserID:    r.UserID.Int64,
+		}
+	}
+
+	return chats, nil
+}
+
+// AnonymizeUserChats blanks the messages of a deleted account. The rows
+// stay so the conversations around them keep their shape.
+func (dbr *DBResource) AnonymizeUserChats(userID int64) error {
+	query := `
+		UPDATE
+			chat
+		SET
+			message = $1
+		WHERE
+			user_id = $2
+	`
+
+	_, err := dbr.db.Exec(query, model.DeletedMessage, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
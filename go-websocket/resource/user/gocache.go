@@ -1,8 +1,12 @@
 package user
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 
+	authClient "github.com/lolmourne/go-accounts/client/userauth"
 	"github.com/lolmourne/go-websocket/model"
 	"github.com/patrickmn/go-cache"
 )
@@ -11,15 +15,20 @@ func (acr *AuthCliResource) GetUserByID(userID int64) *model.User {
 	usr, ok := acr.goc.Get(fmt.Sprintf("usr:%d", userID))
 
 	if !ok {
-		userCli := acr.authClient.GetUserByID(userID)
-		if userCli == nil {
+		userCli, err := acr.authClient.GetUserByID(context.Background(), userID)
+		if err != nil {
+			if !errors.Is(err, authClient.ErrNotFound) {
+				log.Println(err)
+			}
 			return nil
 		}
 		user := &model.User{
-			UserID:     userCli.UserID,
-			ProfilePic: userCli.ProfilePic,
-			Username:   userCli.Username,
-			CreatedAt:  userCli.CreatedAt,
+			UserID:             userCli.UserID,
+			ProfilePic:         userCli.ProfilePic,
+			ProfilePicVariants: userCli.ProfilePicVariants,
+			Username:           userCli.Username,
+			DisplayName:        userCli.DisplayName,
+			CreatedAt:          userCli.CreatedAt,
 		}
 		acr.goc.Set(fmt.Sprintf("usr:%d", userID), user, cache.DefaultExpiration)
 
@@ -28,3 +37,59 @@ func (acr *AuthCliResource) GetUserByID(userID int64) *model.User {
 
 	return usr.(*model.User)
 }
+
+// batchSize is the most users accounts resolves in one batch request.
+const batchSize = 100
+
+// GetUsersByIDs resolves userIDs from the cache and fetches the rest from
+// accounts in as few requests as possible. Unknown users are left out.
+func (acr *AuthCliResource) GetUsersByIDs(userIDs []int64) map[int64]*model.User {
+	users := make(map[int64]*model.User, len(userIDs))
+	var missing []int64
+	for _, userID := range userIDs {
+		if _, ok := users[userID]; ok {
+			continue
+		}
+
+		usr, ok := acr.goc.Get(fmt.Sprintf("usr:%d", userID))
+		if !ok {
+			users[userID] = nil
+			missing = append(missing, userID)
+			continue
+		}
+		users[userID] = usr.(*model.User)
+	}
+
+	for start := 0; start < len(missing); start += batchSize {
+		end := start + batchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+
+		fetched, err := acr.authClient.GetUsersByIDs(context.Background(), missing[start:end])
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		for _, userCli := range fetched {
+			user := &model.User{
+				UserID:             userCli.UserID,
+				ProfilePic:         userCli.ProfilePic,
+				ProfilePicVariants: userCli.ProfilePicVariants,
+				Username:           userCli.Username,
+				DisplayName:        userCli.DisplayName,
+				CreatedAt:          userCli.CreatedAt,
+			}
+			acr.goc.Set(fmt.Sprintf("usr:%d", user.UserID), user, cache.DefaultExpiration)
+			users[user.UserID] = user
+		}
+	}
+
+	for userID, user := range users {
+		if user == nil {
+			delete(users, userID)
+		}
+	}
+
+	return users
+}
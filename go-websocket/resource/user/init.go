@@ -10,6 +10,7 @@ import (
 
 type IResource interface {
 	GetUserByID(userID int64) *model.User
+	GetUsersByIDs(userIDs []int64) map[int64]*model.User
 }
 
 type AuthCliResource struct {
@@ -1,20 +1,31 @@
 package main
 
 import (
+	"sync"
+
 	"github.com/lolmourne/go-groupchat/client"
 	"github.com/lolmourne/go-websocket/resource/chat"
 	"github.com/lolmourne/go-websocket/resource/user"
 )
 
 type RoomManager struct {
+	// hubs is read by the pubsub handlers while connections add rooms
 	hubs    map[int64]*Hub
+	hubsMu  sync.RWMutex
 	cli     client.GroupchatClientItf
 	userRsc user.IResource
 	chatRsc chat.IResource
 }
 
 type RoomManagerItf interface {
-	JoinRoom(roomID int64) *Hub
+	// JoinRoom returns the hub of roomID, nil when the owner of
+	// accessToken cannot see the room.
+	JoinRoom(roomID int64, accessToken string) *Hub
+	SetBlocked(userID, blockedUserID int64, blocked bool)
+	// SendToUser sends msg to every connection userID has open.
+	SendToUser(userID int64, msg []byte)
+	// SendToRoom sends msg to every connection open on roomID.
+	SendToRoom(roomID int64, msg []byte)
 }
 
 func NewRoomManager(cli client.GroupchatClientItf, chatRsc chat.IResource, userRsc user.IResource) RoomManagerItf {
@@ -26,18 +37,57 @@ func NewRoomManager(cli client.GroupchatClientItf, chatRsc chat.IResource, userR
 	}
 }
 
-func (r *RoomManager) JoinRoom(roomID int64) *Hub {
-	room := r.cli.GetGroupchatRoom(roomID)
+func (r *RoomManager) JoinRoom(roomID int64, accessToken string) *Hub {
+	room := r.cli.GetGroupchatRoom(roomID, accessToken)
 	if room == nil {
 		return nil
 	}
 
+	r.hubsMu.Lock()
+	defer r.hubsMu.Unlock()
 	hub, ok := r.hubs[roomID]
 	if !ok {
 		hub = NewHub(roomID, sub, rdb, r.chatRsc, r.userRsc)
 		r.hubs[roomID] = hub
-		go r.hubs[roomID].run()
-		return hub
+		go hub.run()
 	}
 	return hub
 }
+
+// eachHub calls fn with every hub, fn must not block.
+func (r *RoomManager) eachHub(fn func(hub *Hub)) {
+	r.hubsMu.RLock()
+	defer r.hubsMu.RUnlock()
+	for _, hub := range r.hubs {
+		fn(hub)
+	}
+}
+
+// SetBlocked updates the block list of every connection userID has open.
+func (r *RoomManager) SetBlocked(userID, blockedUserID int64, blocked bool) {
+	r.eachHub(func(hub *Hub) {
+		hub.forEach(func(client *Client) {
+			if client.usr.UserID == userID {
+				client.setBlocked(blockedUserID, blocked)
+			}
+		})
+	})
+}
+
+func (r *RoomManager) SendToUser(userID int64, msg []byte) {
+	r.eachHub(func(hub *Hub) {
+		hub.sendTo(func(client *Client) bool {
+			return client.usr.UserID == userID
+		}, msg)
+	})
+}
+
+func (r *RoomManager) SendToRoom(roomID int64, msg []byte) {
+	r.hubsMu.RLock()
+	hub, ok := r.hubs[roomID]
+	r.hubsMu.RUnlock()
+	if !ok {
+		return
+	}
+	hub.sendTo(nil, msg)
+}